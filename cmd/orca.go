@@ -1,25 +1,34 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
 
 	"github.com/nuvo/orca/pkg/orca"
+	"github.com/nuvo/orca/pkg/utils"
 
 	"github.com/spf13/cobra"
 )
 
 func main() {
-	cmd := NewRootCmd(os.Args[1:])
+	ctx, cancel := utils.NewSignalContext(context.Background())
+	defer cancel()
+
+	cmd := NewRootCmd(ctx, os.Args[1:])
 	if err := cmd.Execute(); err != nil {
-		log.Fatal("Failed to execute command")
+		// Commands exit on their own failures, errors returned here are invalid arguments or flags
+		code := orca.ExitCode(err)
+		if code == orca.ExitCodeError {
+			code = orca.ExitCodeInvalidInput
+		}
+		os.Exit(code)
 	}
 }
 
 // NewRootCmd represents the base command when called without any subcommands
-func NewRootCmd(args []string) *cobra.Command {
+func NewRootCmd(ctx context.Context, args []string) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "orca",
 		Short: "CI\\CD simplifier",
@@ -29,26 +38,33 @@ Instead of writing scripts on top of scripts, Orca holds all the logic.
 	}
 
 	out := cmd.OutOrStdout()
+	clients := utils.NewClients(ctx)
 
 	cmd.AddCommand(
-		NewDeleteCmd(out),
-		NewDeployCmd(out),
+		NewDeleteCmd(out, clients),
+		NewDeployCmd(out, clients),
 		NewDetermineCmd(out),
-		NewGetCmd(out),
-		NewPushCmd(out),
+		NewGetCmd(out, clients),
+		NewPushCmd(out, clients),
+		NewLintCmd(out),
 		NewCreateCmd(out),
 		NewVersionCmd(out),
-		NewLockCmd(out),
-		NewUnlockCmd(out),
-		NewDiffCmd(out),
-		NewValidateCmd(out),
+		NewLockCmd(out, clients),
+		NewUnlockCmd(out, clients),
+		NewProtectCmd(out, clients),
+		NewUnprotectCmd(out, clients),
+		NewDiffCmd(out, clients),
+		NewValidateCmd(out, clients),
+		NewConfigCmd(out),
+		orca.NewEnvVarsCmd(out),
 	)
+	orca.UseConfig(cmd)
 
 	return cmd
 }
 
 // NewDeleteCmd represents the get command
-func NewDeleteCmd(out io.Writer) *cobra.Command {
+func NewDeleteCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Deletion functions",
@@ -56,7 +72,8 @@ func NewDeleteCmd(out io.Writer) *cobra.Command {
 	}
 
 	cmd.AddCommand(
-		orca.NewDeleteEnvCmd(out),
+		orca.NewDeleteEnvCmd(out, clients),
+		orca.NewDeleteReleaseCmd(out, clients),
 		orca.NewDeleteResourceCmd(out),
 	)
 
@@ -64,7 +81,7 @@ func NewDeleteCmd(out io.Writer) *cobra.Command {
 }
 
 // NewDeployCmd represents the get command
-func NewDeployCmd(out io.Writer) *cobra.Command {
+func NewDeployCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "deploy",
 		Short: "Deployment functions",
@@ -72,8 +89,8 @@ func NewDeployCmd(out io.Writer) *cobra.Command {
 	}
 
 	cmd.AddCommand(
-		orca.NewDeployChartCmd(out),
-		orca.NewDeployEnvCmd(out),
+		orca.NewDeployChartCmd(out, clients),
+		orca.NewDeployEnvCmd(out, clients),
 		orca.NewDeployArtifactCmd(out),
 	)
 
@@ -88,13 +105,30 @@ func NewDetermineCmd(out io.Writer) *cobra.Command {
 		Long:  ``,
 	}
 
-	cmd.AddCommand(orca.NewDetermineBuildtype(out))
+	cmd.AddCommand(
+		orca.NewDetermineBuildtype(out),
+		orca.NewDetermineAffected(out),
+		orca.NewDetermineVersion(out),
+	)
+
+	return cmd
+}
+
+// NewLintCmd represents the lint command
+func NewLintCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Lint functions",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(orca.NewLintChartCmd(out))
 
 	return cmd
 }
 
 // NewGetCmd represents the get command
-func NewGetCmd(out io.Writer) *cobra.Command {
+func NewGetCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "get",
 		Short: "Get functions",
@@ -102,49 +136,77 @@ func NewGetCmd(out io.Writer) *cobra.Command {
 	}
 
 	cmd.AddCommand(
-		orca.NewGetEnvCmd(out),
+		orca.NewGetEnvCmd(out, clients),
 		orca.NewGetResourceCmd(out),
 		orca.NewGetArtifactCmd(out),
+		orca.NewGetProtectedCmd(out, clients),
 	)
 
 	return cmd
 }
 
 // NewLockCmd represents the lock command
-func NewLockCmd(out io.Writer) *cobra.Command {
+func NewLockCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "lock",
 		Short: "Lock functions",
 		Long:  ``,
 	}
 
-	cmd.AddCommand(orca.NewLockEnvCmd(out))
+	cmd.AddCommand(orca.NewLockEnvCmd(out, clients))
 
 	return cmd
 }
 
 // NewUnlockCmd represents the unlock command
-func NewUnlockCmd(out io.Writer) *cobra.Command {
+func NewUnlockCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "unlock",
 		Short: "Unlock functions",
 		Long:  ``,
 	}
 
-	cmd.AddCommand(orca.NewUnlockEnvCmd(out))
+	cmd.AddCommand(orca.NewUnlockEnvCmd(out, clients))
+
+	return cmd
+}
+
+// NewProtectCmd represents the protect command
+func NewProtectCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "protect",
+		Short: "Protect functions",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(orca.NewProtectChartCmd(out, clients))
+
+	return cmd
+}
+
+// NewUnprotectCmd represents the unprotect command
+func NewUnprotectCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unprotect",
+		Short: "Unprotect functions",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(orca.NewUnprotectChartCmd(out, clients))
 
 	return cmd
 }
 
 // NewPushCmd represents the get command
-func NewPushCmd(out io.Writer) *cobra.Command {
+func NewPushCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "push",
 		Short: "Push functions",
 		Long:  ``,
 	}
 
-	cmd.AddCommand(orca.NewPushChartCmd(out))
+	cmd.AddCommand(orca.NewPushChartCmd(out, clients))
+	cmd.AddCommand(orca.NewPushChartsCmd(out, clients))
 
 	return cmd
 }
@@ -163,27 +225,40 @@ func NewCreateCmd(out io.Writer) *cobra.Command {
 }
 
 // NewDiffCmd represents the create command
-func NewDiffCmd(out io.Writer) *cobra.Command {
+func NewDiffCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "diff",
 		Short: "Differentiation functions",
 		Long:  ``,
 	}
 
-	cmd.AddCommand(orca.NewDiffEnvCmd(out))
+	cmd.AddCommand(orca.NewDiffEnvCmd(out, clients))
 
 	return cmd
 }
 
 // NewValidateCmd represents the validate command
-func NewValidateCmd(out io.Writer) *cobra.Command {
+func NewValidateCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "validate",
 		Short: "Validation functions",
 		Long:  ``,
 	}
 
-	cmd.AddCommand(orca.NewValidateEnvCmd(out))
+	cmd.AddCommand(orca.NewValidateEnvCmd(out, clients))
+
+	return cmd
+}
+
+// NewConfigCmd represents the config command
+func NewConfigCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Configuration functions",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(orca.NewConfigViewCmd(out))
 
 	return cmd
 }
@@ -0,0 +1,129 @@
+package orca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+type affectedCmd struct {
+	servicesFile string
+	changes      changesOptions
+	version      string
+	output       string
+
+	out io.Writer
+}
+
+// NewDetermineAffected represents the determine affected command
+func NewDetermineAffected(out io.Writer) *cobra.Command {
+	a := &affectedCmd{out: out}
+
+	cmd := &cobra.Command{
+		Use:   "affected",
+		Short: "Determine affected services and charts based on changed paths",
+		Long: `Determine affected services and charts based on changed paths.
+Services are matched by the paths they are built from, and services which depend on affected services are affected as well.
+If the changed paths can not be determined (e.g. the previous commit is not set or is an error), all services are affected.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := a.changes.validate(); err != nil {
+				return err
+			}
+			if a.servicesFile == "" {
+				return errors.New("services-file can not be empty")
+			}
+			switch a.output {
+			case "", "json", "override":
+			default:
+				return errors.New("output can be one of: json, override")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := a.determineAffected(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVarP(&a.servicesFile, "services-file", "s", "", "path to YAML file with the services of the repository, their paths, charts and dependencies. Overrides $ORCA_SERVICES_FILE")
+	bindEnvVar(f, "services-file", "ORCA_SERVICES_FILE")
+	a.changes.addFlags(f)
+	f.StringVar(&a.version, "version", "", "version of all affected charts (default is the version in the chart path of each service). Overrides $ORCA_VERSION")
+	bindEnvVar(f, "version", "ORCA_VERSION")
+	f.StringVarP(&a.output, "output", "o", "json", "output format (json, override). Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
+
+	return cmd
+}
+
+// determineAffected prints the services which are affected by the paths changed since the previous commit
+func (a *affectedCmd) determineAffected() error {
+	servicesFile, err := utils.LoadServicesFile(a.servicesFile)
+	if err != nil {
+		return err
+	}
+
+	changed, err := a.changes.detectChangedPaths()
+	if err != nil {
+		return err
+	}
+	all := changed.FallbackReason != ""
+	if all {
+		log.Printf("could not determine changed paths, all services are affected: %s", changed.FallbackReason)
+	}
+
+	services, err := utils.GetAffectedServices(servicesFile, changed.Paths, all)
+	if err != nil {
+		return err
+	}
+	return a.printAffected(changed.Paths, services)
+}
+
+// printAffected prints affected services as json, or as override arguments of their charts
+func (a *affectedCmd) printAffected(changedPaths []string, services []utils.AffectedService) error {
+	if a.version != "" {
+		services = append([]utils.AffectedService{}, services...)
+		for i := range services {
+			if services[i].Chart != "" {
+				services[i].Version = a.version
+			}
+		}
+	}
+
+	switch a.output {
+	case "override":
+		overrides := []string{}
+		for _, s := range services {
+			if s.Chart == "" {
+				continue
+			}
+			if s.Version == "" {
+				return fmt.Errorf("%w: chart \"%s\" of service \"%s\" has no version, set its chartPath or use --version", utils.ErrInvalidServicesFile, s.Chart, s.Name)
+			}
+			overrides = append(overrides, fmt.Sprintf("--override %s=%s", s.Chart, s.Version))
+		}
+		if len(overrides) != 0 {
+			fmt.Fprintln(a.out, strings.Join(overrides, " "))
+		}
+	default:
+		data, err := json.MarshalIndent(struct {
+			ChangedPaths []string                `json:"changedPaths"`
+			Services     []utils.AffectedService `json:"services"`
+		}{changedPaths, services}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(a.out, string(data))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package orca
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+)
+
+func TestPrintAffected(t *testing.T) {
+	services := []utils.AffectedService{
+		{Name: "common", Reason: utils.AffectedReasonChanged, Paths: []string{"libs/common/log.go"}},
+		{Name: "api", Chart: "api", Version: "1.2.3", Reason: utils.AffectedReasonDependency, AffectedBy: []string{"common"}},
+	}
+	tests := []struct {
+		name     string
+		output   string
+		version  string
+		services []utils.AffectedService
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "override",
+			output:   "override",
+			services: services,
+			want:     "--override api=1.2.3\n",
+		},
+		{
+			name:     "override with version",
+			output:   "override",
+			version:  "1.3.0-abc123",
+			services: services,
+			want:     "--override api=1.3.0-abc123\n",
+		},
+		{
+			name:     "override of chart without version",
+			output:   "override",
+			services: []utils.AffectedService{{Name: "worker", Chart: "worker", Reason: utils.AffectedReasonChanged}},
+			wantErr:  true,
+		},
+		{
+			name:     "override of no services",
+			output:   "override",
+			services: []utils.AffectedService{},
+			want:     "",
+		},
+		{
+			name:     "json",
+			output:   "json",
+			services: services[1:],
+			want: `{
+  "changedPaths": [
+    "libs/common/log.go"
+  ],
+  "services": [
+    {
+      "name": "api",
+      "chart": "api",
+      "version": "1.2.3",
+      "reason": "dependency",
+      "affectedBy": [
+        "common"
+      ]
+    }
+  ]
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			a := &affectedCmd{output: tt.output, version: tt.version, out: &out}
+			err := a.printAffected([]string{"libs/common/log.go"}, tt.services)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("printAffected() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := out.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("printAffected() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
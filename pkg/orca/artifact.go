@@ -4,7 +4,6 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 
 	"github.com/nuvo/orca/pkg/utils"
@@ -41,24 +40,30 @@ func NewGetArtifactCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			artifact := utils.PerformRequest(utils.PerformRequestOptions{
+			artifact, err := utils.PerformRequest(utils.PerformRequestOptions{
 				Method:             "GET",
 				URL:                a.url,
 				Headers:            []string{"X-JFrog-Art-Api:" + a.token},
 				ExpectedStatusCode: 200,
 			})
-			err := ioutil.WriteFile(a.file, artifact, 0644)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
+			}
+			err = ioutil.WriteFile(a.file, artifact, 0644)
+			if err != nil {
+				fatal(err)
 			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&a.url, "url", os.Getenv("ORCA_URL"), "url of file to get. Overrides $ORCA_URL")
-	f.StringVar(&a.token, "token", os.Getenv("ORCA_TOKEN"), "artifactory token to use. Overrides $ORCA_TOKEN")
-	f.StringVar(&a.file, "file", os.Getenv("ORCA_FILE"), "path of file to write. Overrides $ORCA_FILE")
+	f.StringVar(&a.url, "url", "", "url of file to get. Overrides $ORCA_URL")
+	bindEnvVar(f, "url", "ORCA_URL")
+	f.StringVar(&a.token, "token", "", "artifactory token to use. Overrides $ORCA_TOKEN")
+	bindEnvVar(f, "token", "ORCA_TOKEN")
+	f.StringVar(&a.file, "file", "", "path of file to write. Overrides $ORCA_FILE")
+	bindEnvVar(f, "file", "ORCA_FILE")
 
 	return cmd
 }
@@ -89,23 +94,28 @@ func NewDeployArtifactCmd(out io.Writer) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			data, err := os.Open(a.file)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
-			utils.PerformRequest(utils.PerformRequestOptions{
+			if _, err := utils.PerformRequest(utils.PerformRequestOptions{
 				Method:             "PUT",
 				URL:                a.url,
 				Headers:            []string{"X-JFrog-Art-Api:" + a.token},
 				ExpectedStatusCode: 201,
 				Data:               data,
-			})
+			}); err != nil {
+				fatal(err)
+			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&a.url, "url", os.Getenv("ORCA_URL"), "url of file to deploy. Overrides $ORCA_URL")
-	f.StringVar(&a.token, "token", os.Getenv("ORCA_TOKEN"), "artifactory token to use. Overrides $ORCA_TOKEN")
-	f.StringVar(&a.file, "file", os.Getenv("ORCA_FILE"), "path of file to deploy. Overrides $ORCA_FILE")
+	f.StringVar(&a.url, "url", "", "url of file to deploy. Overrides $ORCA_URL")
+	bindEnvVar(f, "url", "ORCA_URL")
+	f.StringVar(&a.token, "token", "", "artifactory token to use. Overrides $ORCA_TOKEN")
+	bindEnvVar(f, "token", "ORCA_TOKEN")
+	f.StringVar(&a.file, "file", "", "path of file to deploy. Overrides $ORCA_FILE")
+	bindEnvVar(f, "file", "ORCA_FILE")
 
 	return cmd
 }
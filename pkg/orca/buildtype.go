@@ -1,73 +1,283 @@
 package orca
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/nuvo/orca/pkg/utils"
 
+	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 )
 
 type determineCmd struct {
-	defaultType                  string
-	pathFilter                   []string
-	allowMultipleTypes           bool
-	mainRef                      string
-	releaseRef                   string
-	currentRef                   string
-	previousCommit               string
-	previousCommitErrorIndicator string
-
-	out io.Writer
+	defaultType        string
+	pathFilter         []string
+	rulesFile          string
+	mode               string
+	allowMultipleTypes bool
+	mainRef            string
+	releaseRef         string
+	currentRef         string
+	changes            changesOptions
+	explain            bool
+	output             string
+
+	out    io.Writer
+	errOut io.Writer
+}
+
+// Decisions of the build type which are made before changed paths are matched or by path filters, in addition to the decisions of utils.DetermineBuildType
+const (
+	buildTypeDecisionNotReleaseRef   = "not-release-ref"
+	buildTypeDecisionNoFilters       = "no-filters"
+	buildTypeDecisionPrevCommitError = "prev-commit-error"
+	buildTypeDecisionFallback        = "fallback"
+	// buildTypeDecisionAmbiguousPaths is the decision when a changed path is matched by multiple path filters
+	buildTypeDecisionAmbiguousPaths = "ambiguous-paths"
+)
+
+// buildTypeExplanation explains how a build type was determined
+type buildTypeExplanation struct {
+	BuildType    string                 `json:"buildType"`
+	Decision     string                 `json:"decision"`
+	Reason       string                 `json:"reason"`
+	Base         string                 `json:"base,omitempty"`
+	Head         string                 `json:"head,omitempty"`
+	ChangedPaths []string               `json:"changedPaths"`
+	Matches      []utils.BuildTypeMatch `json:"matches"`
+	Unmatched    []string               `json:"unmatched"`
 }
 
 // NewDetermineBuildtype represents the determine buildtype command
 func NewDetermineBuildtype(out io.Writer) *cobra.Command {
-	d := &determineCmd{out: out}
+	d := &determineCmd{out: out, errOut: os.Stderr}
 
 	cmd := &cobra.Command{
 		Use:   "buildtype",
-		Short: "Determine build type based on path filters",
+		Short: "Determine build type based on path filters or rules",
 		Long:  ``,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := d.changes.validate(); err != nil {
+				return err
+			}
+			if d.rulesFile != "" && len(d.pathFilter) != 0 {
+				return errors.New("path-filter can not be used along with rules-file")
+			}
+			switch d.mode {
+			case "", utils.BuildTypeModeSingle, utils.BuildTypeModeMultiple, utils.BuildTypeModeAll:
+			default:
+				return fmt.Errorf("mode should be one of %s, %s, %s", utils.BuildTypeModeSingle, utils.BuildTypeModeMultiple, utils.BuildTypeModeAll)
+			}
+			switch d.output {
+			case "", "json":
+			default:
+				return errors.New("output can be one of: json")
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-
-			if !utils.IsMainlineOrReleaseRef(d.currentRef, d.mainRef, d.releaseRef) {
-				fmt.Println(d.defaultType)
-				return
+			if err := d.determineBuildtype(); err != nil {
+				fatal(err)
 			}
+		},
+	}
 
-			if utils.IsCommitError(d.previousCommit, d.previousCommitErrorIndicator) {
-				fmt.Println(d.defaultType)
-				return
-			}
+	f := cmd.Flags()
+
+	f.StringVar(&d.defaultType, "default-type", "default", "default build type. Overrides $ORCA_DEFAULT_TYPE")
+	bindEnvVar(f, "default-type", "ORCA_DEFAULT_TYPE")
+	f.StringSliceVar(&d.pathFilter, "path-filter", []string{}, "path filter (supports multiple) in the path=buildtype form (supports regex). Overrides $ORCA_PATH_FILTER")
+	bindEnvVar(f, "path-filter", "ORCA_PATH_FILTER")
+	f.StringVar(&d.rulesFile, "rules-file", "", "path to YAML file with build type rules (include/exclude globs, priority and ignore rules), used instead of path filters. Overrides $ORCA_RULES_FILE")
+	bindEnvVar(f, "rules-file", "ORCA_RULES_FILE")
+	f.StringVar(&d.mode, "mode", "", "how to determine the build type when changed paths match multiple types or are not matched (single, multiple, all). overrides the mode of the rules file. Overrides $ORCA_BUILDTYPE_MODE")
+	bindEnvVar(f, "mode", "ORCA_BUILDTYPE_MODE")
+	f.BoolVar(&d.allowMultipleTypes, "allow-multiple-types", false, "allow multiple build types. Overrides $ORCA_ALLOW_MULTIPLE_TYPES")
+	bindEnvVar(f, "allow-multiple-types", "ORCA_ALLOW_MULTIPLE_TYPES")
+	f.StringVar(&d.mainRef, "main-ref", "", "name of the reference which is the main line. Overrides $ORCA_MAIN_REF")
+	bindEnvVar(f, "main-ref", "ORCA_MAIN_REF")
+	f.StringVar(&d.releaseRef, "rel-ref", "", "release reference name (or regex). Overrides $ORCA_REL_REF")
+	bindEnvVar(f, "rel-ref", "ORCA_REL_REF")
+	f.StringVar(&d.currentRef, "curr-ref", "", "current reference name. Overrides $ORCA_CURR_REF")
+	bindEnvVar(f, "curr-ref", "ORCA_CURR_REF")
+	d.changes.addFlags(f)
+	f.BoolVar(&d.explain, "explain", false, "explain how the build type was determined (changed paths, the filter or rule each matched and the decision), printed to stderr. Overrides $ORCA_EXPLAIN")
+	bindEnvVar(f, "explain", "ORCA_EXPLAIN")
+	f.StringVarP(&d.output, "output", "o", "", "output format (json), json includes the explanation of the build type. Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
+
+	return cmd
+}
+
+// determineBuildtype prints the build type determined by the paths changed since the previous commit
+func (d *determineCmd) determineBuildtype() error {
+	e, err := d.explainBuildtype()
+	if err != nil {
+		return err
+	}
+	if d.explain {
+		d.printExplanation(d.errOut, e)
+	}
+	if d.output == "json" {
+		data, err := json.MarshalIndent(e, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(d.out, string(data))
+		return nil
+	}
+	fmt.Fprintln(d.out, e.BuildType)
+	return nil
+}
+
+// explainBuildtype determines the build type and explains how it was determined
+func (d *determineCmd) explainBuildtype() (*buildTypeExplanation, error) {
+	e := &buildTypeExplanation{BuildType: d.defaultType, ChangedPaths: []string{}, Matches: []utils.BuildTypeMatch{}, Unmatched: []string{}}
+
+	// Changes against a target branch or in a range are determined on any reference
+	if !d.changes.branchAware() && !utils.IsMainlineOrReleaseRef(d.currentRef, d.mainRef, d.releaseRef) {
+		e.Decision = buildTypeDecisionNotReleaseRef
+		e.Reason = fmt.Sprintf("reference \"%s\" is not the main line (%s) or a release reference (%s)", d.currentRef, d.mainRef, d.releaseRef)
+		return e, nil
+	}
+
+	var rules *utils.BuildTypeRules
+	if d.rulesFile != "" {
+		var err error
+		if rules, err = utils.LoadBuildTypeRules(d.rulesFile); err != nil {
+			return nil, err
+		}
+	}
 
-			// If no path filters are defined - default type
-			if len(d.pathFilter) == 0 {
-				fmt.Println(d.defaultType)
-				return
+	// If no path filters or rules are defined - default type
+	if len(d.pathFilter) == 0 && rules == nil {
+		e.Decision, e.Reason = buildTypeDecisionNoFilters, "no path filters or rules are set"
+		return e, nil
+	}
+
+	if d.changes.previousCommit != "" && utils.IsCommitError(d.changes.previousCommit, d.changes.previousCommitErrorIndicator) {
+		e.Decision = buildTypeDecisionPrevCommitError
+		e.Reason = fmt.Sprintf("previous commit \"%s\" is an error (identified by \"%s\")", d.changes.previousCommit, d.changes.previousCommitErrorIndicator)
+		log.Printf("could not determine changed paths, falling back to default type: %s", e.Reason)
+		return e, nil
+	}
+
+	// Get changed paths
+	changed, err := d.changes.detectChangedPaths()
+	if err != nil {
+		return nil, err
+	}
+	if changed.FallbackReason != "" {
+		e.Decision, e.Reason = buildTypeDecisionFallback, changed.FallbackReason
+		log.Printf("could not determine changed paths, falling back to default type: %s", changed.FallbackReason)
+		return e, nil
+	}
+	e.Base, e.Head, e.ChangedPaths = changed.Base, changed.Head, changed.Paths
+
+	result, err := d.buildTypeByChangedPaths(rules, changed.Paths)
+	if err != nil {
+		return nil, err
+	}
+	e.BuildType = result.String()
+	e.Decision, e.Reason = result.Decision, result.Reason
+	e.Matches, e.Unmatched = result.Matches, result.Unmatched
+	return e, nil
+}
+
+// buildTypeByChangedPaths determines the build type of changed paths by rules, or by path filters if no rules are set.
+// Path filters are converted to rules, except that the default type is determined if a changed path is matched by multiple path filters
+func (d *determineCmd) buildTypeByChangedPaths(rules *utils.BuildTypeRules, changedPaths []string) (*utils.BuildTypeResult, error) {
+	byPathFilters := rules == nil
+	if byPathFilters {
+		rules = &utils.BuildTypeRules{}
+		for _, pf := range d.pathFilter {
+			re, bt, err := utils.SplitInTwo(pf, "=")
+			if err != nil {
+				return nil, err
 			}
+			rules.Rules = append(rules.Rules, utils.BuildTypeRule{Name: pf, Type: bt, Regex: re})
+		}
+	}
+
+	if d.mode != "" {
+		rules.Mode = d.mode
+	}
+	if rules.Mode == "" && d.allowMultipleTypes {
+		rules.Mode = utils.BuildTypeModeMultiple
+	}
+	result, err := utils.DetermineBuildType(rules, d.defaultType, changedPaths)
+	if err != nil || !byPathFilters {
+		return result, err
+	}
 
-			// Get changed paths
-			changedPaths := utils.GetChangedPaths(d.previousCommit)
+	ambiguous, err := pathsMatchedByMultipleFilters(rules.Rules, changedPaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(ambiguous) != 0 {
+		result.Types = []string{d.defaultType}
+		result.Decision = buildTypeDecisionAmbiguousPaths
+		result.Reason = fmt.Sprintf("%d changed path(s) are matched by multiple path filters (%s)", len(ambiguous), strings.Join(ambiguous, ", "))
+	}
+	return result, nil
+}
 
-			// Some paths changed, check against path filters
-			buildTypeByPathFilters := utils.GetBuildTypeByPathFilters(d.defaultType, changedPaths, d.pathFilter, d.allowMultipleTypes)
-			fmt.Println(buildTypeByPathFilters)
-		},
+// pathsMatchedByMultipleFilters returns the changed paths which are matched by more than one path filter
+func pathsMatchedByMultipleFilters(filters []utils.BuildTypeRule, changedPaths []string) ([]string, error) {
+	regexes := make([]*regexp.Regexp, 0, len(filters))
+	for _, f := range filters {
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, re)
 	}
 
-	f := cmd.Flags()
+	var ambiguous []string
+	for _, path := range changedPaths {
+		count := 0
+		for _, re := range regexes {
+			if re.MatchString(path) {
+				count++
+			}
+		}
+		if count > 1 {
+			ambiguous = append(ambiguous, path)
+		}
+	}
+	return ambiguous, nil
+}
 
-	f.StringVar(&d.defaultType, "default-type", utils.GetStringEnvVar("ORCA_DEFAULT_TYPE", "default"), "default build type. Overrides $ORCA_DEFAULT_TYPE")
-	f.StringSliceVar(&d.pathFilter, "path-filter", []string{}, "path filter (supports multiple) in the path=buildtype form (supports regex)")
-	f.BoolVar(&d.allowMultipleTypes, "allow-multiple-types", utils.GetBoolEnvVar("ORCA_ALLOW_MULTIPLE_TYPES", false), "allow multiple build types. Overrides $ORCA_ALLOW_MULTIPLE_TYPES")
-	f.StringVar(&d.mainRef, "main-ref", os.Getenv("ORCA_MAIN_REF"), "name of the reference which is the main line. Overrides $ORCA_MAIN_REF")
-	f.StringVar(&d.releaseRef, "rel-ref", os.Getenv("ORCA_REL_REF"), "release reference name (or regex). Overrides $ORCA_REL_REF")
-	f.StringVar(&d.currentRef, "curr-ref", os.Getenv("ORCA_CURR_REF"), "current reference name. Overrides $ORCA_CURR_REF")
-	f.StringVar(&d.previousCommit, "prev-commit", os.Getenv("ORCA_PREV_COMMIT"), "previous commit for paths comparison. Overrides $ORCA_PREV_COMMIT")
-	f.StringVar(&d.previousCommitErrorIndicator, "prev-commit-error", utils.GetStringEnvVar("ORCA_PREV_COMMIT_ERROR", "E"), "identify an error with the previous commit by this string. Overrides $ORCA_PREV_COMMIT_ERROR")
+// printExplanation prints how a build type was determined in a human readable form
+func (d *determineCmd) printExplanation(out io.Writer, e *buildTypeExplanation) {
+	fmt.Fprintf(out, "build type: %s\n", e.BuildType)
+	fmt.Fprintf(out, "decision: %s (%s)\n", e.Decision, e.Reason)
+	if e.Base != "" {
+		fmt.Fprintf(out, "compared: %s..%s\n", e.Base, e.Head)
+	}
+	if len(e.ChangedPaths) == 0 {
+		return
+	}
 
-	return cmd
+	tbl := uitable.New()
+	tbl.MaxColWidth = 80
+	tbl.AddRow("CHANGED PATH", "TYPE", "MATCHED BY")
+	for _, m := range e.Matches {
+		buildType := m.Type
+		if m.Ignored {
+			buildType = "(ignored)"
+		}
+		tbl.AddRow(m.Path, buildType, m.Rule)
+	}
+	for _, p := range e.Unmatched {
+		tbl.AddRow(p, "-", "(unmatched)")
+	}
+	fmt.Fprintln(out, tbl.String())
 }
@@ -0,0 +1,168 @@
+package orca
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+)
+
+func TestBuildTypeByChangedPaths(t *testing.T) {
+	changedPaths := []string{"src/file1.go", "kubernetes/Chart.yaml", "README.md"}
+	pathFilter := []string{"^src.*$=code", "^kubernetes.*$=chart"}
+	tests := []struct {
+		name               string
+		pathFilter         []string
+		rulesFile          string
+		mode               string
+		allowMultipleTypes bool
+		want               string
+	}{
+		{
+			name:       "path filters",
+			pathFilter: pathFilter,
+			want:       "default",
+		},
+		{
+			name:       "path filters in all mode",
+			pathFilter: pathFilter,
+			mode:       utils.BuildTypeModeAll,
+			want:       "code;chart",
+		},
+		{
+			name:               "path filters of the same type matching a path",
+			pathFilter:         []string{"^src.*$=code", "^.*\\.go$=code", "^kubernetes.*$=chart", "^README.md$=chart"},
+			allowMultipleTypes: true,
+			want:               "default",
+		},
+		{
+			name:       "path filters matching a path in all mode",
+			pathFilter: []string{"^src.*$=code", "^.*\\.go$=go", "^kubernetes.*$=chart"},
+			mode:       utils.BuildTypeModeAll,
+			want:       "default",
+		},
+		{
+			name:               "path filters each matching a path",
+			pathFilter:         []string{"^src.*$=code", "^kubernetes.*$=chart", "^README.md$=chart"},
+			allowMultipleTypes: true,
+			want:               "code;chart",
+		},
+		{
+			name:      "rules file",
+			rulesFile: "testdata/buildtype-rules.yaml",
+			want:      "default",
+		},
+		{
+			name:               "rules file with multiple types allowed",
+			rulesFile:          "testdata/buildtype-rules.yaml",
+			allowMultipleTypes: true,
+			want:               "code;chart",
+		},
+		{
+			name:      "rules file in single mode",
+			rulesFile: "testdata/buildtype-rules.yaml",
+			mode:      utils.BuildTypeModeSingle,
+			want:      "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &determineCmd{
+				defaultType:        "default",
+				pathFilter:         tt.pathFilter,
+				mode:               tt.mode,
+				allowMultipleTypes: tt.allowMultipleTypes,
+			}
+			var rules *utils.BuildTypeRules
+			if tt.rulesFile != "" {
+				var err error
+				if rules, err = utils.LoadBuildTypeRules(tt.rulesFile); err != nil {
+					t.Fatalf("LoadBuildTypeRules() error = %v", err)
+				}
+			}
+			got, err := d.buildTypeByChangedPaths(rules, changedPaths)
+			if err != nil {
+				t.Fatalf("buildTypeByChangedPaths() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("buildTypeByChangedPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetermineBuildtype_Explain(t *testing.T) {
+	tests := []struct {
+		name         string
+		d            determineCmd
+		wantDecision string
+		wantExplain  string
+	}{
+		{
+			name:         "not a release reference",
+			d:            determineCmd{pathFilter: []string{"^src.*$=code"}, mainRef: "master", releaseRef: "^release/.*$", currentRef: "feature"},
+			wantDecision: buildTypeDecisionNotReleaseRef,
+			wantExplain:  "decision: not-release-ref (reference \"feature\" is not the main line (master) or a release reference (^release/.*$))",
+		},
+		{
+			name:         "no path filters",
+			d:            determineCmd{mainRef: "master", currentRef: "master"},
+			wantDecision: buildTypeDecisionNoFilters,
+			wantExplain:  "decision: no-filters (no path filters or rules are set)",
+		},
+		{
+			name:         "previous commit error",
+			d:            determineCmd{pathFilter: []string{"^src.*$=code"}, mainRef: "master", currentRef: "master", changes: changesOptions{previousCommit: "E", previousCommitErrorIndicator: "E"}},
+			wantDecision: buildTypeDecisionPrevCommitError,
+			wantExplain:  "decision: prev-commit-error (previous commit \"E\" is an error (identified by \"E\"))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			d := tt.d
+			d.defaultType, d.explain, d.output, d.out, d.errOut = "default", true, "json", &out, &errOut
+			if err := d.determineBuildtype(); err != nil {
+				t.Fatalf("determineBuildtype() error = %v", err)
+			}
+			wantOut := "\"decision\": \"" + tt.wantDecision + "\""
+			if !strings.Contains(out.String(), wantOut) || !strings.Contains(out.String(), "\"buildType\": \"default\"") {
+				t.Errorf("determineBuildtype() output = %v, want %v", out.String(), wantOut)
+			}
+			if !strings.Contains(errOut.String(), tt.wantExplain) {
+				t.Errorf("determineBuildtype() explanation = %v, want %v", errOut.String(), tt.wantExplain)
+			}
+		})
+	}
+}
+
+func TestPrintExplanation(t *testing.T) {
+	d := &determineCmd{defaultType: "default", pathFilter: []string{"^src.*$=code", "^kubernetes.*$=chart"}}
+	result, err := d.buildTypeByChangedPaths(nil, []string{"src/file1.go", "README.md"})
+	if err != nil {
+		t.Fatalf("buildTypeByChangedPaths() error = %v", err)
+	}
+	var out bytes.Buffer
+	d.printExplanation(&out, &buildTypeExplanation{
+		BuildType:    result.String(),
+		Decision:     result.Decision,
+		Reason:       result.Reason,
+		Base:         "abc",
+		Head:         "def",
+		ChangedPaths: []string{"src/file1.go", "README.md"},
+		Matches:      result.Matches,
+		Unmatched:    result.Unmatched,
+	})
+	got := regexp.MustCompile(` +\n`).ReplaceAllString(out.String(), "\n")
+	want := "build type: default\n" +
+		"decision: unmatched-paths (1 changed path(s) are not matched by any rule (single mode))\n" +
+		"compared: abc..def\n" +
+		"CHANGED PATH\tTYPE\tMATCHED BY\n" +
+		"src/file1.go\tcode\t^src.*$=code\n" +
+		"README.md   \t-   \t(unmatched)\n"
+	if got != want {
+		t.Errorf("printExplanation() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package orca
+
+import (
+	"errors"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/pflag"
+)
+
+// changesOptions are options of commands which determine by the paths changed in the git repository
+type changesOptions struct {
+	previousCommit               string
+	previousCommitErrorIndicator string
+	targetBranch                 string
+	commitRange                  string
+	sinceTag                     string
+}
+
+// addFlags adds the flags of the options to a command
+func (c *changesOptions) addFlags(f *pflag.FlagSet) {
+	f.StringVar(&c.previousCommit, "prev-commit", "", "previous commit for paths comparison. Overrides $ORCA_PREV_COMMIT")
+	bindEnvVar(f, "prev-commit", "ORCA_PREV_COMMIT")
+	f.StringVar(&c.previousCommitErrorIndicator, "prev-commit-error", "E", "identify an error with the previous commit by this string. Overrides $ORCA_PREV_COMMIT_ERROR")
+	bindEnvVar(f, "prev-commit-error", "ORCA_PREV_COMMIT_ERROR")
+	f.StringVar(&c.targetBranch, "target-branch", "", "branch to compare with the merge-base of (e.g. origin/master for a pull request), instead of the previous commit. Overrides $ORCA_TARGET_BRANCH")
+	bindEnvVar(f, "target-branch", "ORCA_TARGET_BRANCH")
+	f.StringVar(&c.commitRange, "range", "", "range of commits to compare (from..to, or from...to to compare with the merge-base), instead of the previous commit. Overrides $ORCA_RANGE")
+	bindEnvVar(f, "range", "ORCA_RANGE")
+	f.StringVar(&c.sinceTag, "since-tag", "", "tag to compare with (or latest for the latest tag in the history of HEAD), instead of the previous commit. Overrides $ORCA_SINCE_TAG")
+	bindEnvVar(f, "since-tag", "ORCA_SINCE_TAG")
+}
+
+// validate returns an error if more than one way to determine the changed paths is set
+func (c *changesOptions) validate() error {
+	set := 0
+	for _, v := range []string{c.previousCommit, c.targetBranch, c.commitRange, c.sinceTag} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return errors.New("only one of prev-commit, target-branch, range and since-tag can be set")
+	}
+	return nil
+}
+
+// branchAware returns true if the changed paths are determined by a target branch or a range, which apply to any branch
+func (c *changesOptions) branchAware() bool {
+	return c.targetBranch != "" || c.commitRange != ""
+}
+
+// detectChangedPaths returns the paths changed in the git repository, or the reason they can not be determined
+func (c *changesOptions) detectChangedPaths() (*utils.ChangedPaths, error) {
+	if c.previousCommit != "" && utils.IsCommitError(c.previousCommit, c.previousCommitErrorIndicator) {
+		return &utils.ChangedPaths{Paths: []string{}, FallbackReason: "previous commit is an error"}, nil
+	}
+	return utils.DetectChangedPaths(utils.ChangedPathsOptions{
+		PreviousCommit: c.previousCommit,
+		TargetBranch:   c.targetBranch,
+		Range:          c.commitRange,
+		SinceTag:       c.sinceTag,
+	})
+}
@@ -2,13 +2,16 @@ package orca
 
 import (
 	"errors"
+	"fmt"
 	"io"
-	"log"
-	"os"
+	"time"
 
 	"github.com/nuvo/orca/pkg/utils"
 
+	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
 )
 
 type chartCmd struct {
@@ -25,13 +28,16 @@ type chartCmd struct {
 	inject       bool
 	timeout      int
 	validate     bool
+	helmClient   string
+	gracePeriod  int
 
-	out io.Writer
+	clients *utils.Clients
+	out     io.Writer
 }
 
 // NewDeployChartCmd represents the deploy chart command
-func NewDeployChartCmd(out io.Writer) *cobra.Command {
-	c := &chartCmd{out: out}
+func NewDeployChartCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	c := &chartCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "chart",
@@ -56,88 +62,425 @@ func NewDeployChartCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := utils.DeployChartFromRepository(utils.DeployChartFromRepositoryOptions{
-				ReleaseName:  c.releaseName,
-				Name:         c.name,
-				Version:      c.version,
-				KubeContext:  c.kubeContext,
-				Namespace:    c.namespace,
-				Repo:         c.repo,
-				TLS:          c.tls,
-				HelmTLSStore: c.helmTLSStore,
-				PackedValues: c.packedValues,
-				SetValues:    c.set,
-				IsIsolated:   true,
-				Inject:       c.inject,
-				Timeout:      c.timeout,
-				Validate:     c.validate,
-			}); err != nil {
-				log.Fatal(err)
+			if err := c.deployChart(); err != nil {
+				fatal(err)
 			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&c.name, "name", os.Getenv("ORCA_NAME"), "name of chart to deploy. Overrides $ORCA_NAME")
-	f.StringVar(&c.version, "version", os.Getenv("ORCA_VERSION"), "version of chart to deploy. Overrides $ORCA_VERSION")
-	f.StringVar(&c.repo, "repo", os.Getenv("ORCA_REPO"), "chart repository (name=url). Overrides $ORCA_REPO")
-	f.StringVar(&c.releaseName, "release-name", os.Getenv("ORCA_RELEASE_NAME"), "release name. Overrides $ORCA_RELEASE_NAME")
-	f.StringVar(&c.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
-	f.StringVarP(&c.namespace, "namespace", "n", os.Getenv("ORCA_NAMESPACE"), "kubernetes namespace to deploy to. Overrides $ORCA_NAMESPACE")
+	f.StringVar(&c.name, "name", "", "name of chart to deploy. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&c.version, "version", "", "version of chart to deploy. Overrides $ORCA_VERSION")
+	bindEnvVar(f, "version", "ORCA_VERSION")
+	f.StringVar(&c.repo, "repo", "", "chart repository (name=url). Overrides $ORCA_REPO")
+	bindEnvVar(f, "repo", "ORCA_REPO")
+	f.StringVar(&c.releaseName, "release-name", "", "release name. Overrides $ORCA_RELEASE_NAME")
+	bindEnvVar(f, "release-name", "ORCA_RELEASE_NAME")
+	f.StringVar(&c.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.StringVarP(&c.namespace, "namespace", "n", "", "kubernetes namespace to deploy to. Overrides $ORCA_NAMESPACE")
+	bindEnvVar(f, "namespace", "ORCA_NAMESPACE")
 	f.StringSliceVarP(&c.packedValues, "values", "f", []string{}, "values file to use (packaged within the chart)")
 	f.StringSliceVarP(&c.set, "set", "s", []string{}, "set additional parameters")
-	f.BoolVar(&c.tls, "tls", utils.GetBoolEnvVar("ORCA_TLS", false), "enable TLS for request. Overrides $ORCA_TLS")
-	f.StringVar(&c.helmTLSStore, "helm-tls-store", os.Getenv("HELM_TLS_STORE"), "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
-	f.BoolVar(&c.inject, "inject", utils.GetBoolEnvVar("ORCA_INJECT", false), "enable injection during helm upgrade. Overrides $ORCA_INJECT (requires helm inject plugin: https://github.com/maorfr/helm-inject)")
-	f.IntVar(&c.timeout, "timeout", utils.GetIntEnvVar("ORCA_TIMEOUT", 300), "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
-	f.BoolVar(&c.validate, "validate", utils.GetBoolEnvVar("ORCA_VALIDATE", false), "perform environment validation after deployment. Overrides $ORCA_VALIDATE")
+	f.BoolVar(&c.tls, "tls", false, "enable TLS for request. Overrides $ORCA_TLS")
+	bindEnvVar(f, "tls", "ORCA_TLS")
+	f.StringVar(&c.helmTLSStore, "helm-tls-store", "", "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
+	bindEnvVar(f, "helm-tls-store", "HELM_TLS_STORE")
+	f.BoolVar(&c.inject, "inject", false, "enable injection during helm upgrade. Overrides $ORCA_INJECT (requires helm inject plugin: https://github.com/maorfr/helm-inject)")
+	bindEnvVar(f, "inject", "ORCA_INJECT")
+	f.IntVar(&c.timeout, "timeout", 300, "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
+	bindSecondsEnvVar(f, "timeout", "ORCA_TIMEOUT")
+	f.BoolVar(&c.validate, "validate", false, "perform environment validation after deployment. Overrides $ORCA_VALIDATE")
+	bindEnvVar(f, "validate", "ORCA_VALIDATE")
+	f.StringVar(&c.helmClient, "helm-client", utils.HelmClientSDK, "helm client to use (sdk, exec). exec requires the helm binary. Overrides $ORCA_HELM_CLIENT")
+	bindEnvVar(f, "helm-client", "ORCA_HELM_CLIENT")
+	f.IntVar(&c.gracePeriod, "grace-period", utils.DefaultGracePeriod, "time in seconds to wait for the in-flight release when interrupted (SIGINT, SIGTERM) before killing it. Overrides $ORCA_GRACE_PERIOD")
+	bindSecondsEnvVar(f, "grace-period", "ORCA_GRACE_PERIOD")
 
 	return cmd
 }
 
+// deployChart deploys a Helm chart from a chart repository
+func (c *chartCmd) deployChart() error {
+	helmClient, err := c.clients.Helm(c.helmClient)
+	if err != nil {
+		return err
+	}
+	var clientset kubernetes.Interface
+	if c.validate {
+		clientset, err = c.clients.KubeClientSet(c.kubeContext)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := utils.WithGracePeriod(c.clients.Context, time.Duration(c.gracePeriod)*time.Second)
+	defer cancel()
+
+	return utils.DeployChartFromRepository(ctx, utils.DeployChartFromRepositoryOptions{
+		Helm:         helmClient,
+		ReleaseName:  c.releaseName,
+		Name:         c.name,
+		Version:      c.version,
+		KubeContext:  c.kubeContext,
+		Namespace:    c.namespace,
+		Repo:         c.repo,
+		TLS:          c.tls,
+		HelmTLSStore: c.helmTLSStore,
+		PackedValues: c.packedValues,
+		SetValues:    c.set,
+		IsIsolated:   true,
+		Inject:       c.inject,
+		Timeout:      c.timeout,
+		Validate:     c.validate,
+		ClientSet:    clientset,
+	})
+}
+
 type chartPushCmd struct {
-	path   string
-	append string
-	repo   string
-	lint   bool
+	path               string
+	append             string
+	gitVersion         bool
+	version            versionOptions
+	dependencyVersions []string
+	chartAnnotations   []string
+	repo               string
+	publisher          publisherOptions
+	skipExisting       bool
+	force              bool
+	packageOnly        bool
+	destination        string
+	lint               bool
+	policies           policyOptions
+	helmClient         string
 
-	out io.Writer
+	clients *utils.Clients
+	out     io.Writer
 }
 
 // NewPushChartCmd represents the push chart command
-func NewPushChartCmd(out io.Writer) *cobra.Command {
-	c := &chartPushCmd{out: out}
+func NewPushChartCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	c := &chartPushCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "chart",
-		Short: "Push Helm chart to chart repository (requires helm push plugin: https://github.com/chartmuseum/helm-push)",
-		Long:  ``,
+		Short: "Push Helm chart to chart repository (exec helm client requires helm push plugin: https://github.com/chartmuseum/helm-push)",
+		Long: `Push Helm chart to chart repository.
+By default the chart is pushed by the Helm client. Use --publisher to push it to ChartMuseum using its API, to an Artifactory Helm repository or to an OCI registry (--repo oci://registry/namespace).`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if c.repo == "" && !c.packageOnly {
+				return errors.New("repo can not be empty")
+			}
+			if err := c.publisher.validate(); err != nil {
+				return err
+			}
+			if c.gitVersion && c.append != "" {
+				return errors.New("append can not be used along with git-version")
+			}
+			if c.skipExisting && c.force {
+				return errors.New("skip-existing can not be used along with force")
+			}
+			return c.policies.validate()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.pushChart(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVar(&c.path, "path", "", "path to chart. Overrides $ORCA_PATH")
+	bindEnvVar(f, "path", "ORCA_PATH")
+	f.StringVar(&c.append, "append", "", "string to append to version. Overrides $ORCA_APPEND")
+	bindEnvVar(f, "append", "ORCA_APPEND")
+	f.BoolVar(&c.gitVersion, "git-version", false, "calculate the version from the git history (see determine version) instead of appending to the version. Overrides $ORCA_GIT_VERSION")
+	bindEnvVar(f, "git-version", "ORCA_GIT_VERSION")
+	c.version.addFlags(f)
+	f.StringSliceVar(&c.dependencyVersions, "dependency-versions", []string{}, "versions of dependencies to set in requirements.yaml (or Chart.yaml) (can specify multiple): chart=version. Overrides $ORCA_DEPENDENCY_VERSIONS")
+	bindEnvVar(f, "dependency-versions", "ORCA_DEPENDENCY_VERSIONS")
+	f.StringSliceVar(&c.chartAnnotations, "chart-annotations", []string{}, "annotations to set in Chart.yaml (can specify multiple): annotation=value. Overrides $ORCA_CHART_ANNOTATIONS")
+	bindEnvVar(f, "chart-annotations", "ORCA_CHART_ANNOTATIONS")
+	f.StringVar(&c.repo, "repo", "", "chart repository (name=url, or url for the artifactory and oci publishers). Overrides $ORCA_REPO")
+	bindEnvVar(f, "repo", "ORCA_REPO")
+	c.publisher.addFlags(f)
+	f.BoolVar(&c.skipExisting, "skip-existing", false, "skip pushing the chart if its version already exists in the repository, instead of failing. Overrides $ORCA_SKIP_EXISTING")
+	bindEnvVar(f, "skip-existing", "ORCA_SKIP_EXISTING")
+	f.BoolVar(&c.force, "force", false, "push the chart even if its version already exists in the repository (the repository may overwrite it). Overrides $ORCA_FORCE")
+	bindEnvVar(f, "force", "ORCA_FORCE")
+	f.BoolVar(&c.packageOnly, "package-only", false, "only package the chart to the destination and print the path and the digest of the package. Overrides $ORCA_PACKAGE_ONLY")
+	bindEnvVar(f, "package-only", "ORCA_PACKAGE_ONLY")
+	f.StringVarP(&c.destination, "destination", "d", ".", "directory to write the packaged chart to (with package-only). Overrides $ORCA_DESTINATION")
+	bindEnvVar(f, "destination", "ORCA_DESTINATION")
+	f.BoolVar(&c.lint, "lint", false, "should perform lint (helm lint and the semver-version policy, see lint chart) before push. Overrides $ORCA_LINT")
+	bindEnvVar(f, "lint", "ORCA_LINT")
+	c.policies.addPushFlags(f)
+	f.StringVar(&c.helmClient, "helm-client", utils.HelmClientSDK, "helm client to use (sdk, exec). exec requires the helm binary. Overrides $ORCA_HELM_CLIENT")
+	bindEnvVar(f, "helm-client", "ORCA_HELM_CLIENT")
+
+	return cmd
+}
+
+// pushChart packages and pushes a Helm chart to a chart repository
+func (c *chartPushCmd) pushChart() error {
+	helmClient, err := c.clients.Helm(c.helmClient)
+	if err != nil {
+		return err
+	}
+
+	edit := utils.ChartEdit{AppVersion: c.version.imageTag, Dependencies: map[string]string{}, Annotations: map[string]string{}}
+	if c.gitVersion {
+		calculated, err := c.version.calculate(c.path)
+		if err != nil {
+			return err
+		}
+		edit.Version = calculated.Version
+	}
+	for _, d := range c.dependencyVersions {
+		k, v, err := utils.SplitInTwo(d, "=")
+		if err != nil {
+			return err
+		}
+		edit.Dependencies[k] = v
+	}
+	for _, a := range c.chartAnnotations {
+		k, v, err := utils.SplitInTwo(a, "=")
+		if err != nil {
+			return err
+		}
+		edit.Annotations[k] = v
+	}
+
+	var publisher utils.ChartPublisher
+	var checker utils.ChartVersionChecker
+	if !c.packageOnly {
+		if publisher, err = c.publisher.publisher(c.repo, c.force); err != nil {
+			return err
+		}
+		// Existing versions are not checked when forced, to let the repository overwrite them
+		if !c.force {
+			if checker, err = c.publisher.checker(c.repo); err != nil {
+				return err
+			}
+		}
+	}
+
+	return utils.PushChartToRepository(c.clients.Context, utils.PushChartToRepositoryOptions{
+		Helm:         helmClient,
+		Path:         c.path,
+		Append:       c.append,
+		Edit:         edit,
+		Repo:         c.repo,
+		Publisher:    publisher,
+		Checker:      checker,
+		SkipExisting: c.skipExisting,
+		Force:        c.force,
+		PackageOnly:  c.packageOnly,
+		Destination:  c.destination,
+		Lint:         c.lint,
+		Policies:     c.policies.policies(),
+		Print:        false,
+	})
+}
+
+// publisherOptions are the flags of chart publishers
+type publisherOptions struct {
+	name      string
+	username  string
+	password  string
+	token     string
+	plainHTTP bool
+}
+
+// addFlags adds the flags of chart publishers to a command
+func (p *publisherOptions) addFlags(f *pflag.FlagSet) {
+	f.StringVar(&p.name, "publisher", utils.PublisherHelm, "how to push the chart (helm, chartmuseum, artifactory, oci). Overrides $ORCA_PUBLISHER")
+	bindEnvVar(f, "publisher", "ORCA_PUBLISHER")
+	f.StringVar(&p.username, "repo-username", "", "username of the chart repository (chartmuseum, artifactory and oci publishers). Overrides $ORCA_REPO_USERNAME")
+	bindEnvVar(f, "repo-username", "ORCA_REPO_USERNAME")
+	f.StringVar(&p.password, "repo-password", "", "password of the chart repository (chartmuseum, artifactory and oci publishers). Overrides $ORCA_REPO_PASSWORD")
+	bindEnvVar(f, "repo-password", "ORCA_REPO_PASSWORD")
+	f.StringVar(&p.token, "token", "", "artifactory token to use (artifactory publisher). Overrides $ORCA_TOKEN")
+	bindEnvVar(f, "token", "ORCA_TOKEN")
+	f.BoolVar(&p.plainHTTP, "plain-http", false, "use http instead of https to push to the OCI registry. Overrides $ORCA_PLAIN_HTTP")
+	bindEnvVar(f, "plain-http", "ORCA_PLAIN_HTTP")
+}
+
+// validate verifies the publisher is supported
+func (p *publisherOptions) validate() error {
+	switch p.name {
+	case "", utils.PublisherHelm, utils.PublisherChartMuseum, utils.PublisherArtifactory, utils.PublisherOCI:
+		return nil
+	}
+	return fmt.Errorf("publisher can be one of: %s, %s, %s, %s", utils.PublisherHelm, utils.PublisherChartMuseum, utils.PublisherArtifactory, utils.PublisherOCI)
+}
+
+func (p *publisherOptions) chartPublisherOptions(repo string) utils.ChartPublisherOptions {
+	return utils.ChartPublisherOptions{
+		Type:      p.name,
+		Repo:      repo,
+		Username:  p.username,
+		Password:  p.password,
+		Token:     p.token,
+		PlainHTTP: p.plainHTTP,
+	}
+}
+
+// publisher returns the chart publisher, or nil if charts are pushed by the Helm client.
+// If force is set, the publisher overwrites existing chart versions
+func (p *publisherOptions) publisher(repo string, force bool) (utils.ChartPublisher, error) {
+	if p.name == "" || p.name == utils.PublisherHelm {
+		return nil, nil
+	}
+	o := p.chartPublisherOptions(repo)
+	o.Force = force
+	return utils.NewChartPublisher(o)
+}
+
+// checker returns a checker of chart versions in the repository charts are pushed to
+func (p *publisherOptions) checker(repo string) (utils.ChartVersionChecker, error) {
+	return utils.NewChartVersionChecker(p.chartPublisherOptions(repo))
+}
+
+type chartsPushCmd struct {
+	dir        string
+	append     string
+	gitVersion bool
+	version    versionOptions
+	repo       string
+	publisher  publisherOptions
+	lint       bool
+	policies   policyOptions
+	parallel   int
+	helmClient string
+
+	clients *utils.Clients
+	out     io.Writer
+}
+
+// NewPushChartsCmd represents the push charts command
+func NewPushChartsCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	c := &chartsPushCmd{clients: clients, out: out}
+
+	cmd := &cobra.Command{
+		Use:   "charts",
+		Short: "Push all Helm charts in a directory to chart repository",
+		Long: `Push all Helm charts in a directory (and its subdirectories) to chart repository.
+Charts are versioned, linted, packaged and pushed in parallel. A chart is pushed after the charts it depends on (file:// dependencies),
+and the versions of these dependencies are set to their new versions. Charts whose version already exists in the repository are skipped.`,
 		Args: func(cmd *cobra.Command, args []string) error {
+			if c.dir == "" {
+				return errors.New("dir can not be empty")
+			}
 			if c.repo == "" {
 				return errors.New("repo can not be empty")
 			}
-			return nil
+			if err := c.publisher.validate(); err != nil {
+				return err
+			}
+			if c.gitVersion && c.append != "" {
+				return errors.New("append can not be used along with git-version")
+			}
+			return c.policies.validate()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := utils.PushChartToRepository(utils.PushChartToRepositoryOptions{
-				Path:   c.path,
-				Append: c.append,
-				Repo:   c.repo,
-				Lint:   c.lint,
-				Print:  false,
-			}); err != nil {
-				log.Fatal(err)
+			if err := c.pushCharts(); err != nil {
+				fatal(err)
 			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&c.path, "path", os.Getenv("ORCA_PATH"), "path to chart. Overrides $ORCA_PATH")
-	f.StringVar(&c.append, "append", os.Getenv("ORCA_APPEND"), "string to append to version. Overrides $ORCA_APPEND")
-	f.StringVar(&c.repo, "repo", os.Getenv("ORCA_REPO"), "chart repository (name=url). Overrides $ORCA_REPO")
-	f.BoolVar(&c.lint, "lint", utils.GetBoolEnvVar("ORCA_LINT", false), "should perform lint before push. Overrides $ORCA_LINT")
+	f.StringVar(&c.dir, "dir", "", "path to directory of charts. Overrides $ORCA_DIR")
+	bindEnvVar(f, "dir", "ORCA_DIR")
+	f.StringVar(&c.append, "append", "", "string to append to the versions of the charts. Overrides $ORCA_APPEND")
+	bindEnvVar(f, "append", "ORCA_APPEND")
+	f.BoolVar(&c.gitVersion, "git-version", false, "calculate the versions from the git history (see determine version) instead of appending to the versions. Overrides $ORCA_GIT_VERSION")
+	bindEnvVar(f, "git-version", "ORCA_GIT_VERSION")
+	c.version.addFlags(f)
+	f.StringVar(&c.repo, "repo", "", "chart repository (name=url, or url for the artifactory and oci publishers). Overrides $ORCA_REPO")
+	bindEnvVar(f, "repo", "ORCA_REPO")
+	c.publisher.addFlags(f)
+	f.BoolVar(&c.lint, "lint", false, "should perform lint (helm lint and the semver-version policy, see lint chart) before push. Overrides $ORCA_LINT")
+	bindEnvVar(f, "lint", "ORCA_LINT")
+	c.policies.addPushFlags(f)
+	f.IntVarP(&c.parallel, "parallel", "p", 0, "number of charts to push in parallel. set this flag to 0 for full parallelism. Overrides $ORCA_PARALLEL")
+	bindEnvVar(f, "parallel", "ORCA_PARALLEL")
+	f.StringVar(&c.helmClient, "helm-client", utils.HelmClientSDK, "helm client to use (sdk, exec). exec requires the helm binary. Overrides $ORCA_HELM_CLIENT")
+	bindEnvVar(f, "helm-client", "ORCA_HELM_CLIENT")
 
 	return cmd
 }
+
+// pushCharts pushes all charts in a directory to a chart repository and prints a summary
+func (c *chartsPushCmd) pushCharts() error {
+	charts, err := utils.DiscoverCharts(c.dir)
+	if err != nil {
+		return err
+	}
+	if len(charts) == 0 {
+		return fmt.Errorf("%w: no charts found in %s", utils.ErrInvalidChart, c.dir)
+	}
+
+	versions := map[string]string{}
+	if c.gitVersion {
+		for _, chart := range charts {
+			calculated, err := c.version.calculate(chart.Path)
+			if err != nil {
+				return err
+			}
+			versions[chart.Name] = calculated.Version
+		}
+	}
+
+	helmClient, err := c.clients.Helm(c.helmClient)
+	if err != nil {
+		return err
+	}
+	publisher, err := c.publisher.publisher(c.repo, false)
+	if err != nil {
+		return err
+	}
+	checker, err := c.publisher.checker(c.repo)
+	if err != nil {
+		return err
+	}
+
+	pushed, err := utils.PushChartsToRepository(c.clients.Context, utils.PushChartsToRepositoryOptions{
+		Helm:       helmClient,
+		Charts:     charts,
+		Append:     c.append,
+		Versions:   versions,
+		AppVersion: c.version.imageTag,
+		Repo:       c.repo,
+		Publisher:  publisher,
+		Checker:    checker,
+		Lint:       c.lint,
+		Policies:   c.policies.policies(),
+		Parallel:   c.parallel,
+		Print:      false,
+	})
+	printPushedCharts(c.out, pushed)
+	return err
+}
+
+// printPushedCharts prints a table of pushed and skipped charts
+func printPushedCharts(out io.Writer, pushed []utils.PushedChart) {
+	if len(pushed) == 0 {
+		return
+	}
+	table := uitable.New()
+	table.AddRow("CHART", "VERSION", "STATUS")
+	for _, p := range pushed {
+		status := "pushed"
+		if p.Skipped {
+			status = "skipped (exists)"
+		}
+		table.AddRow(p.Name, p.Version, status)
+	}
+	fmt.Fprintln(out, table)
+}
@@ -0,0 +1,212 @@
+package orca
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+	"github.com/nuvo/orca/pkg/utils/fake"
+)
+
+// writeCharts writes charts to a directory, by path of file
+func writeCharts(t *testing.T, dir string, files map[string]string) {
+	for file, content := range files {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPushCharts(t *testing.T) {
+	files := map[string]string{
+		"common/Chart.yaml":       "name: common\nversion: 0.2.0\n",
+		"api/Chart.yaml":          "name: api\nversion: 0.1.0\n",
+		"services/web/Chart.yaml": "name: web\nversion: 1.0.0\n",
+		"services/web/requirements.yaml": `dependencies:
+- name: api
+  version: 0.1.0
+  repository: file://../../api
+- name: common
+  version: ~0.2.0
+  repository: file://../../common
+`,
+	}
+	// the repository already has common 0.2.0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("entries:\n  common:\n  - version: 0.2.0\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		append           string
+		wantPushed       []string
+		wantRequirements string
+		wantOut          string
+	}{
+		{
+			name:             "existing versions are skipped",
+			wantPushed:       []string{"api-0.1.0", "web-1.0.0"},
+			wantRequirements: files["services/web/requirements.yaml"],
+			wantOut:          "CHART \tVERSION\tSTATUS          \napi   \t0.1.0  \tpushed          \ncommon\t0.2.0  \tskipped (exists)\nweb   \t1.0.0  \tpushed          \n",
+		},
+		{
+			name:       "versions of local dependencies are updated",
+			append:     "abc",
+			wantPushed: []string{"api-0.1.0-abc", "common-0.2.0-abc", "web-1.0.0-abc"},
+			wantRequirements: `dependencies:
+- name: api
+  version: 0.1.0-abc
+  repository: file://../../api
+- name: common
+  version: 0.2.0-abc
+  repository: file://../../common
+`,
+			wantOut: "CHART \tVERSION  \tSTATUS\napi   \t0.1.0-abc\tpushed\ncommon\t0.2.0-abc\tpushed\nweb   \t1.0.0-abc\tpushed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "orca-charts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			writeCharts(t, dir, files)
+
+			helmClient := fake.NewHelmClient(fake.NewClientSet())
+			var out bytes.Buffer
+			c := &chartsPushCmd{
+				dir:     dir,
+				append:  tt.append,
+				repo:    "myrepo=" + server.URL,
+				clients: fake.NewClients(fake.NewClientSet(), helmClient),
+				out:     &out,
+			}
+			if err := c.pushCharts(); err != nil {
+				t.Fatalf("pushCharts() error = %v", err)
+			}
+
+			// web is pushed last, after the charts it depends on
+			if !reflect.DeepEqual(helmClient.Repositories, []string{c.repo}) {
+				t.Errorf("pushCharts() added repositories %v, want %v", helmClient.Repositories, []string{c.repo})
+			}
+			if len(helmClient.Pushed) != len(tt.wantPushed) || helmClient.Pushed[len(helmClient.Pushed)-1] != tt.wantPushed[len(tt.wantPushed)-1] {
+				t.Errorf("pushCharts() pushed %v, want %v (in dependency order)", helmClient.Pushed, tt.wantPushed)
+			}
+			for _, p := range tt.wantPushed {
+				if !utils.Contains(helmClient.Pushed, p) {
+					t.Errorf("pushCharts() pushed %v, want %v", helmClient.Pushed, tt.wantPushed)
+				}
+			}
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "services", "web", "requirements.yaml"))
+			if string(data) != tt.wantRequirements {
+				t.Errorf("pushCharts() requirements.yaml = %q, want %q", data, tt.wantRequirements)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("pushCharts() output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestPushChart_ExistingVersion(t *testing.T) {
+	// the repository already has api 0.1.0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("entries:\n  api:\n  - version: 0.1.0\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		append       string
+		imageTag     string
+		skipExisting bool
+		force        bool
+		wantPushed   []string
+		wantForced   []string
+		wantErr      error
+	}{
+		{
+			name:       "new version",
+			append:     "abc",
+			wantPushed: []string{"api-0.1.0-abc"},
+		},
+		{
+			name:       "new version with image tag",
+			append:     "abc",
+			imageTag:   "1.2.3",
+			wantPushed: []string{"api-0.1.0-abc"},
+		},
+		{
+			name:     "existing version",
+			imageTag: "1.2.3",
+			wantErr:  utils.ErrChartVersionExists,
+		},
+		{
+			name:         "existing version skipped",
+			imageTag:     "1.2.3",
+			skipExisting: true,
+		},
+		{
+			name:       "existing version forced",
+			force:      true,
+			wantPushed: []string{"api-0.1.0"},
+			wantForced: []string{"api-0.1.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "orca-chart")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			const chartFile = "name: api\nversion: 0.1.0\n"
+			writeCharts(t, dir, map[string]string{"Chart.yaml": chartFile})
+
+			helmClient := fake.NewHelmClient(fake.NewClientSet())
+			c := &chartPushCmd{
+				path:         dir,
+				append:       tt.append,
+				version:      versionOptions{imageTag: tt.imageTag},
+				repo:         "myrepo=" + server.URL,
+				skipExisting: tt.skipExisting,
+				force:        tt.force,
+				clients:      fake.NewClients(fake.NewClientSet(), helmClient),
+				out:          ioutil.Discard,
+			}
+			err = c.pushChart()
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("pushChart() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(helmClient.Pushed, tt.wantPushed) {
+				t.Errorf("pushChart() pushed %v, want %v", helmClient.Pushed, tt.wantPushed)
+			}
+			if !reflect.DeepEqual(helmClient.Forced, tt.wantForced) {
+				t.Errorf("pushChart() forced %v, want %v", helmClient.Forced, tt.wantForced)
+			}
+			// A chart which is not pushed is left unchanged
+			if len(tt.wantPushed) == 0 {
+				data, err := ioutil.ReadFile(filepath.Join(dir, "Chart.yaml"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != chartFile {
+					t.Errorf("Chart.yaml = %q, want %q", string(data), chartFile)
+				}
+			}
+		})
+	}
+}
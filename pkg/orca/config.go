@@ -0,0 +1,199 @@
+package orca
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	configFlag  string = "config"
+	profileFlag string = "profile"
+	// envVarAnnotation is the annotation of a flag which holds the environment variable backing it
+	envVarAnnotation = "orca_env_var"
+)
+
+type configCmd struct {
+	out io.Writer
+}
+
+// configView is the output of the config view command
+type configView struct {
+	Config   string                   `yaml:"config"`
+	Profile  string                   `yaml:"profile,omitempty"`
+	Settings utils.Profile            `yaml:"settings,omitempty"`
+	Profiles map[string]utils.Profile `yaml:"profiles,omitempty"`
+}
+
+// UseConfig adds the config and profile flags to a root command. Before the arguments of its subcommands
+// are validated, it sets their flags which were not set by a flag to the values of the environment variables
+// backing them (failing on invalid values), and then to the values of the selected profile
+func UseConfig(root *cobra.Command) {
+	f := root.PersistentFlags()
+
+	f.String(configFlag, "", fmt.Sprintf("path to configuration file (default is %s in the current directory or its parents up to the git repository root). Overrides $ORCA_CONFIG", utils.ConfigFileName))
+	bindEnvVar(f, configFlag, "ORCA_CONFIG")
+	f.String(profileFlag, "", "name of configuration profile to use. Overrides $ORCA_PROFILE")
+	bindEnvVar(f, profileFlag, "ORCA_PROFILE")
+
+	// Environment variables and profiles are applied before arguments are validated, as validation depends on flag values
+	var wrap func(cmd *cobra.Command)
+	wrap = func(cmd *cobra.Command) {
+		for _, c := range cmd.Commands() {
+			wrap(c)
+		}
+		if cmd.Run == nil && cmd.RunE == nil {
+			return
+		}
+		args := cmd.Args
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			if err := applyEnvVars(cmd); err != nil {
+				return err
+			}
+			if err := applyProfile(cmd); err != nil {
+				return err
+			}
+			if args == nil {
+				return nil
+			}
+			return args(cmd, a)
+		}
+	}
+	wrap(root)
+}
+
+// NewConfigViewCmd represents the config view command
+func NewConfigViewCmd(out io.Writer) *cobra.Command {
+	c := &configCmd{out: out}
+
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "View the configuration file and the selected profile",
+		Long:  ``,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.view(cmd); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	return cmd
+}
+
+// view prints the configuration file in use and the selected profile (or all profiles if none is selected)
+func (c *configCmd) view(cmd *cobra.Command) error {
+	file, config, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New("no configuration file found")
+	}
+	name, _ := cmd.Flags().GetString(profileFlag)
+	profile, err := config.GetProfile(name)
+	if err != nil {
+		return err
+	}
+
+	view := configView{Config: file}
+	if profile == nil {
+		view.Profiles = config.Profiles
+	} else {
+		view.Profile = name
+		if view.Profile == "" {
+			view.Profile = config.Profile
+		}
+		view.Settings = profile
+	}
+	data, err := yaml.Marshal(view)
+	if err != nil {
+		return err
+	}
+	_, err = c.out.Write(data)
+	return err
+}
+
+// loadConfig loads the configuration file set by the config flag, or discovers it
+func loadConfig(f *pflag.FlagSet) (string, *utils.Config, error) {
+	file, _ := f.GetString(configFlag)
+	if file == "" {
+		var err error
+		if file, err = utils.FindConfigFile("."); err != nil {
+			return "", nil, err
+		}
+		if file == "" {
+			return "", nil, nil
+		}
+	}
+	config, err := utils.LoadConfig(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return file, config, nil
+}
+
+// applyProfile sets the flags of a command which were not set by a flag or an environment variable
+// to the values of the selected profile. Profile keys which are not flags of the command are ignored
+func applyProfile(cmd *cobra.Command) error {
+	f := cmd.Flags()
+	name, _ := f.GetString(profileFlag)
+	_, config, err := loadConfig(f)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		if name != "" {
+			return fmt.Errorf("%w: profile \"%s\" is set but no configuration file was found", utils.ErrInvalidConfig, name)
+		}
+		return nil
+	}
+	profile, err := config.GetProfile(name)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range profile.Keys() {
+		flag := f.Lookup(key)
+		if flag == nil || flag.Changed || key == configFlag || key == profileFlag {
+			continue
+		}
+		if envVar := flagEnvVar(flag); envVar != "" && os.Getenv(envVar) != "" {
+			continue
+		}
+		values, err := profile.Values(key)
+		if err != nil {
+			return err
+		}
+		if len(values) != 1 && !strings.HasSuffix(flag.Value.Type(), "Slice") {
+			return fmt.Errorf("%w: \"%s\" accepts a single value", utils.ErrInvalidConfig, key)
+		}
+		for _, v := range values {
+			if err := f.Set(key, v); err != nil {
+				return fmt.Errorf("%w: invalid value of \"%s\": %v", utils.ErrInvalidConfig, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bindEnvVar sets the environment variable backing a flag. Unless the flag is set, it is set to the
+// value of the environment variable before the arguments of the command are validated (see UseConfig)
+func bindEnvVar(f *pflag.FlagSet, name, envVar string) {
+	f.SetAnnotation(name, envVarAnnotation, []string{envVar})
+}
+
+// flagEnvVar returns the name of the environment variable a flag is backed by (see bindEnvVar)
+func flagEnvVar(flag *pflag.Flag) string {
+	if envVar := flag.Annotations[envVarAnnotation]; len(envVar) != 0 {
+		return envVar[0]
+	}
+	return ""
+}
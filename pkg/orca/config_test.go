@@ -0,0 +1,294 @@
+package orca
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+const testConfig = `profile: staging
+profiles:
+  staging:
+    kube-context: staging
+    parallel: 4
+    protected-chart:
+    - kaa
+    - cassandra
+  prod:
+    kube-context: prod
+    validate: true
+  invalid:
+    parallel: many
+`
+
+type testConfigCmd struct {
+	kubeContext     string
+	parallel        int
+	timeout         int
+	validate        bool
+	protectedCharts []string
+}
+
+func newTestRootCmd(c *testConfigCmd) *cobra.Command {
+	root := &cobra.Command{Use: "orca"}
+	cmd := &cobra.Command{
+		Use: "env",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if c.kubeContext == "" {
+				return errors.New("kube-context can not be empty")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {},
+	}
+	f := cmd.Flags()
+	f.StringVar(&c.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_TEST_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_TEST_KUBE_CONTEXT")
+	f.IntVarP(&c.parallel, "parallel", "p", 1, "number of releases to act on in parallel. Overrides $ORCA_TEST_PARALLEL")
+	bindEnvVar(f, "parallel", "ORCA_TEST_PARALLEL")
+	f.IntVar(&c.timeout, "timeout", 300, "time in seconds to wait for any individual Kubernetes operation. Overrides $ORCA_TEST_TIMEOUT")
+	bindSecondsEnvVar(f, "timeout", "ORCA_TEST_TIMEOUT")
+	f.BoolVar(&c.validate, "validate", false, "perform environment validation after deployment. Overrides $ORCA_TEST_VALIDATE")
+	bindEnvVar(f, "validate", "ORCA_TEST_VALIDATE")
+	f.StringSliceVar(&c.protectedCharts, "protected-chart", []string{}, "chart name to protect from being overridden (can specify multiple). Overrides $ORCA_TEST_PROTECTED_CHART")
+	bindEnvVar(f, "protected-chart", "ORCA_TEST_PROTECTED_CHART")
+	root.AddCommand(cmd)
+	UseConfig(root)
+	root.SetOutput(ioutil.Discard)
+	return root
+}
+
+func TestUseConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orca-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, utils.ConfigFileName)
+	if err := ioutil.WriteFile(configFile, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		want         testConfigCmd
+		wantExitCode int
+	}{
+		{
+			name: "default profile",
+			args: []string{"env", "--config", configFile},
+			want: testConfigCmd{kubeContext: "staging", parallel: 4, timeout: 300, protectedCharts: []string{"kaa", "cassandra"}},
+		},
+		{
+			name: "selected profile",
+			args: []string{"env", "--config", configFile, "--profile", "prod"},
+			want: testConfigCmd{kubeContext: "prod", parallel: 1, timeout: 300, validate: true, protectedCharts: []string{}},
+		},
+		{
+			name: "flag overrides profile",
+			args: []string{"env", "--config", configFile, "--kube-context", "dev", "--protected-chart", "mariadb"},
+			want: testConfigCmd{kubeContext: "dev", parallel: 4, timeout: 300, protectedCharts: []string{"mariadb"}},
+		},
+		{
+			name: "environment variable overrides profile",
+			args: []string{"env", "--config", configFile},
+			env:  map[string]string{"ORCA_TEST_PARALLEL": "2"},
+			want: testConfigCmd{kubeContext: "staging", parallel: 2, timeout: 300, protectedCharts: []string{"kaa", "cassandra"}},
+		},
+		{
+			name: "typed environment variables",
+			args: []string{"env", "--config", configFile, "--profile", "prod"},
+			env: map[string]string{
+				"ORCA_TEST_TIMEOUT":         "5m",
+				"ORCA_TEST_VALIDATE":        "no",
+				"ORCA_TEST_PROTECTED_CHART": "kaa, mariadb",
+			},
+			want: testConfigCmd{kubeContext: "prod", parallel: 1, timeout: 300, protectedCharts: []string{"kaa", "mariadb"}},
+		},
+		{
+			name:         "invalid integer environment variable",
+			args:         []string{"env", "--config", configFile},
+			env:          map[string]string{"ORCA_TEST_PARALLEL": "eight"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+		{
+			name:         "invalid boolean environment variable",
+			args:         []string{"env", "--config", configFile},
+			env:          map[string]string{"ORCA_TEST_VALIDATE": "maybe"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+		{
+			name:         "invalid duration environment variable",
+			args:         []string{"env", "--config", configFile},
+			env:          map[string]string{"ORCA_TEST_TIMEOUT": "5 minutes"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+		{
+			name:         "profile not found",
+			args:         []string{"env", "--config", configFile, "--profile", "dev"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+		{
+			name:         "invalid profile value",
+			args:         []string{"env", "--config", configFile, "--profile", "invalid"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+		{
+			name:         "config file not found",
+			args:         []string{"env", "--config", filepath.Join(dir, "missing.yaml")},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			var got testConfigCmd
+			root := newTestRootCmd(&got)
+			root.SetArgs(tt.args)
+
+			err := root.Execute()
+			if code := ExitCode(err); code != tt.wantExitCode {
+				t.Fatalf("Execute() error = %v, exit code = %v, want %v", err, code, tt.wantExitCode)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flags = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindEnvVar(t *testing.T) {
+	os.Setenv("ORCA_TEST_OUTPUT", "json")
+	defer os.Unsetenv("ORCA_TEST_OUTPUT")
+
+	// The environment variable is bound explicitly, regardless of the usage of the flag
+	var output string
+	root := &cobra.Command{Use: "orca"}
+	cmd := &cobra.Command{Use: "get", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().StringVar(&output, "output", "", "output format")
+	bindEnvVar(cmd.Flags(), "output", "ORCA_TEST_OUTPUT")
+	root.AddCommand(cmd)
+	UseConfig(root)
+	root.SetOutput(ioutil.Discard)
+	root.SetArgs([]string{"get"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if output != "json" {
+		t.Errorf("output = %q, want %q", output, "json")
+	}
+}
+
+func TestConfigView(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orca-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, utils.ConfigFileName)
+	if err := ioutil.WriteFile(configFile, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	root := &cobra.Command{Use: "orca"}
+	config := &cobra.Command{Use: "config"}
+	config.AddCommand(NewConfigViewCmd(&out))
+	root.AddCommand(config)
+	UseConfig(root)
+	root.SetArgs([]string{"config", "view", "--config", configFile, "--profile", "prod"})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"config: " + configFile,
+		"profile: prod",
+		"settings:",
+		"  kube-context: prod",
+		"  validate: true",
+		"",
+	}, "\n")
+	if got := out.String(); got != want {
+		t.Errorf("config view = %q, want %q", got, want)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantRows     []string
+		wantExitCode int
+	}{
+		{
+			name: "valid values",
+			env:  map[string]string{"ORCA_TEST_PARALLEL": "8"},
+			wantRows: []string{
+				"ORCA_TEST_PARALLEL 8 --parallel env",
+				"ORCA_TEST_TIMEOUT --timeout env",
+			},
+		},
+		{
+			name: "variables read directly",
+			env:  map[string]string{"TILLER_NAMESPACE": "tiller"},
+			wantRows: []string{
+				"TILLER_NAMESPACE tiller namespace of Tiller (sdk helm client, reading installed releases)",
+				"HELM_HOME path to the Helm home directory (sdk helm client)",
+			},
+		},
+		{
+			name:         "invalid value of a variable read directly",
+			env:          map[string]string{"HELM_TILLER_CONNECTION_TIMEOUT": "soon"},
+			wantRows:     []string{"HELM_TILLER_CONNECTION_TIMEOUT soon time in seconds to wait for a connection to Tiller (sdk helm client)"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+		{
+			name:         "invalid value",
+			env:          map[string]string{"ORCA_TEST_PARALLEL": "eight"},
+			wantRows:     []string{"ORCA_TEST_PARALLEL eight --parallel env"},
+			wantExitCode: ExitCodeInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			var out bytes.Buffer
+			e := &envVarsCmd{out: &out}
+
+			err := e.printEnvVars(newTestRootCmd(&testConfigCmd{}))
+			if code := ExitCode(err); code != tt.wantExitCode {
+				t.Fatalf("printEnvVars() error = %v, exit code = %v, want %v", err, code, tt.wantExitCode)
+			}
+			rows := map[string]bool{}
+			for _, line := range strings.Split(out.String(), "\n") {
+				rows[strings.Join(strings.Fields(line), " ")] = true
+			}
+			for _, row := range tt.wantRows {
+				if !rows[row] {
+					t.Errorf("printEnvVars() output does not contain %q:\n%s", row, out.String())
+				}
+			}
+		})
+	}
+}
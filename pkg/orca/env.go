@@ -1,6 +1,8 @@
 package orca
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,17 +14,21 @@ import (
 	"github.com/nuvo/orca/pkg/utils"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
 	annotationPrefix    string = "orca.nuvocares.com"
 	stateAnnotation     string = annotationPrefix + "/state"
 	protectedAnnotation string = annotationPrefix + "/protected"
-	busyState           string = "busy"
-	freeState           string = "free"
-	deleteState         string = "delete"
-	failedState         string = "failed"
-	unknownState        string = "unknown"
+	// deletionProtectionAnnotation protects an environment from being deleted when set to true
+	deletionProtectionAnnotation string = annotationPrefix + "/deletion-protection"
+	busyState                    string = "busy"
+	freeState                    string = "free"
+	deleteState                  string = "delete"
+	failedState                  string = "failed"
+	unknownState                 string = "unknown"
 )
 
 type envCmd struct {
@@ -48,13 +54,28 @@ type envCmd struct {
 	deployOnlyOverrideIfEnvExists bool
 	protectedCharts               []string
 	refresh                       bool
-
-	out io.Writer
+	helmClient                    string
+	logDir                        string
+	gracePeriod                   int
+	lockTimeout                   int
+	envTemplate                   string
+	copySecrets                   []string
+	copyConfigMaps                []string
+	imagePullSecrets              bool
+	ignoreProtection              bool
+	yes                           bool
+	waitTimeout                   int
+	deleteOrphans                 bool
+
+	clients *utils.Clients
+	out     io.Writer
+	// in is the input to read confirmations from, nil if orca is not attached to a terminal
+	in io.Reader
 }
 
 // NewGetEnvCmd represents the get env command
-func NewGetEnvCmd(out io.Writer) *cobra.Command {
-	e := &envCmd{out: out}
+func NewGetEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &envCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "env",
@@ -67,40 +88,27 @@ func NewGetEnvCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
-				KubeContext:   e.kubeContext,
-				Namespace:     e.name,
-				IncludeFailed: false,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			switch e.output {
-			case "yaml":
-				utils.PrintReleasesYaml(releases)
-			case "md":
-				utils.PrintReleasesMarkdown(releases)
-			case "table":
-				utils.PrintReleasesTable(releases)
-			case "":
-				utils.PrintReleasesYaml(releases)
+			if err := e.getEnv(); err != nil {
+				fatal(err)
 			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVarP(&e.name, "name", "n", os.Getenv("ORCA_NAME"), "name of environment (namespace) to get. Overrides $ORCA_NAME")
-	f.StringVar(&e.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
-	f.StringVarP(&e.output, "output", "o", os.Getenv("ORCA_OUTPUT"), "output format (yaml, md, table). Overrides $ORCA_OUTPUT")
+	f.StringVarP(&e.name, "name", "n", "", "name of environment (namespace) to get. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&e.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.StringVarP(&e.output, "output", "o", "", "output format (yaml, md, table). Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
 
 	return cmd
 }
 
 // NewDeployEnvCmd represents the deploy env command
-func NewDeployEnvCmd(out io.Writer) *cobra.Command {
-	e := &envCmd{out: out}
+func NewDeployEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &envCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:     "env",
@@ -130,190 +138,84 @@ func NewDeployEnvCmd(out io.Writer) *cobra.Command {
 					return errors.New("override has to be defined when using using deploy-only-override-if-env-exists")
 				}
 			}
-			if e.chartsFile != "" && utils.CheckCircularDependencies(utils.InitReleasesFromChartsFile(e.chartsFile, e.name)) {
-				return errors.New("Circular dependency found")
-			}
-			return nil
-		},
-		Run: func(cmd *cobra.Command, args []string) {
-
-			log.Println("initializing chart repository configuration")
-			if err := utils.AddRepository(utils.AddRepositoryOptions{
-				Repo:  e.repo,
-				Print: false,
-			}); err != nil {
-				log.Fatal(err)
-			}
-			if err := utils.UpdateRepositories(false); err != nil {
-				log.Fatal(err)
-			}
-
-			log.Printf("deploying environment \"%s\"", e.name)
-			nsPreExists, err := utils.NamespaceExists(e.name, e.kubeContext)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if !nsPreExists {
-				if err := utils.CreateNamespace(e.name, e.kubeContext, false); err != nil {
-					log.Fatal(err)
-				}
-				log.Printf("created environment \"%s\"", e.name)
-			}
-			if err := lockEnvironment(e.name, e.kubeContext, true); err != nil {
-				log.Fatal(err)
-			}
-
-			annotations := map[string]string{}
-			for _, a := range e.annotations {
-				k, v := utils.SplitInTwo(a, "=")
-				annotations[k] = v
-			}
-			labels := map[string]string{}
-			for _, a := range e.labels {
-				k, v := utils.SplitInTwo(a, "=")
-				labels[k] = v
-			}
-			if err := utils.UpdateNamespace(e.name, e.kubeContext, annotations, labels, true); err != nil {
-				log.Fatal(err)
-			}
-
-			log.Print("initializing releases to deploy")
-			var desiredReleases []utils.ReleaseSpec
-			if nsPreExists && e.deployOnlyOverrideIfEnvExists {
-				desiredReleases = utils.InitReleases(e.name, e.override)
-			} else {
-				if e.chartsFile != "" {
-					desiredReleases = utils.InitReleasesFromChartsFile(e.chartsFile, e.name)
-				}
-				desiredReleases = utils.OverrideReleases(desiredReleases, e.override, e.name)
-			}
-
-			log.Print("getting currently deployed releases")
-			installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
-				KubeContext:   e.kubeContext,
-				Namespace:     e.name,
-				IncludeFailed: false,
-			})
-			if err != nil {
-				unlockEnvironment(e.name, e.kubeContext, true)
-				log.Fatal(err)
-			}
-
-			log.Print("updating protected charts")
-			protectedCharts, err := updateProtectedCharts(e.name, e.kubeContext, e.protectedCharts, true)
-			if err != nil {
-				unlockEnvironment(e.name, e.kubeContext, true)
-				log.Fatal(err)
-			}
-
-			for _, ir := range installedReleases {
-				for _, pc := range protectedCharts {
-					if pc != ir.ChartName {
-						continue
-					}
-					desiredReleases = utils.OverrideReleases(desiredReleases, []string{ir.ChartName + "=" + ir.ChartVersion}, e.name)
-				}
-			}
-
-			log.Print("calculating delta between desired releases and currently deployed releases")
-			releasesToInstall := utils.GetReleasesDelta(desiredReleases, installedReleases)
-
-			log.Print("deploying releases")
-			if err := utils.DeployChartsFromRepository(utils.DeployChartsFromRepositoryOptions{
-				ReleasesToInstall: releasesToInstall,
-				KubeContext:       e.kubeContext,
-				Namespace:         e.name,
-				Repo:              e.repo,
-				TLS:               e.tls,
-				HelmTLSStore:      e.helmTLSStore,
-				PackedValues:      e.packedValues,
-				SetValues:         e.set,
-				Inject:            e.inject,
-				Parallel:          e.parallel,
-				Timeout:           e.timeout,
-			}); err != nil {
-				markEnvironmentAsFailed(e.name, e.kubeContext, true)
-				log.Fatal(err)
-			}
-
-			if !e.deployOnlyOverrideIfEnvExists {
-				log.Print("getting currently deployed releases")
-				installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
-					KubeContext:   e.kubeContext,
-					Namespace:     e.name,
-					IncludeFailed: false,
-				})
+			if e.chartsFile != "" {
+				releases, err := utils.InitReleasesFromChartsFile(e.chartsFile, e.name)
 				if err != nil {
-					markEnvironmentAsUnknown(e.name, e.kubeContext, true)
-					log.Fatal(err)
+					return err
 				}
-				log.Print("calculating delta between desired releases and currently deployed releases")
-				releasesToDelete := utils.GetReleasesDelta(installedReleases, desiredReleases)
-				log.Print("deleting undesired releases")
-				if err := utils.DeleteReleases(utils.DeleteReleasesOptions{
-					ReleasesToDelete: releasesToDelete,
-					KubeContext:      e.kubeContext,
-					TLS:              e.tls,
-					HelmTLSStore:     e.helmTLSStore,
-					Parallel:         e.parallel,
-					Timeout:          e.timeout,
-				}); err != nil {
-					markEnvironmentAsFailed(e.name, e.kubeContext, true)
-					log.Fatal(err)
+				if utils.CheckCircularDependencies(releases) {
+					return utils.ErrCircularDependency
 				}
 			}
-			log.Printf("deployed environment \"%s\"", e.name)
-
-			var envValid bool
-			if e.validate {
-				envValid, err = utils.IsEnvValidWithLoopBackOff(e.name, e.kubeContext)
-			}
-
-			unlockEnvironment(e.name, e.kubeContext, true)
-
-			if !e.validate {
-				return
-			}
-			if err != nil {
-				log.Fatal(err)
-			}
-			if !envValid {
-				markEnvironmentAsFailed(e.name, e.kubeContext, true)
-				log.Fatalf("environment \"%s\" validation failed!", e.name)
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := e.deployEnv(); err != nil {
+				fatal(err)
 			}
-			// If we have made it so far, the environment is validated
-			log.Printf("environment \"%s\" validated!", e.name)
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVarP(&e.chartsFile, "charts-file", "c", os.Getenv("ORCA_CHARTS_FILE"), "path to file with list of Helm charts to install. Overrides $ORCA_CHARTS_FILE")
-	f.StringSliceVar(&e.override, "override", []string{}, "chart to override with different version (can specify multiple): chart=version")
-	f.StringVarP(&e.name, "name", "n", os.Getenv("ORCA_NAME"), "name of environment (namespace) to deploy to. Overrides $ORCA_NAME")
-	f.StringVar(&e.repo, "repo", os.Getenv("ORCA_REPO"), "chart repository (name=url). Overrides $ORCA_REPO")
-	f.StringVar(&e.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	f.StringVarP(&e.chartsFile, "charts-file", "c", "", "path to file with list of Helm charts to install. Overrides $ORCA_CHARTS_FILE")
+	bindEnvVar(f, "charts-file", "ORCA_CHARTS_FILE")
+	f.StringSliceVar(&e.override, "override", []string{}, "chart to override with different version (can specify multiple): chart=version. Overrides $ORCA_OVERRIDE")
+	bindEnvVar(f, "override", "ORCA_OVERRIDE")
+	f.StringVarP(&e.name, "name", "n", "", "name of environment (namespace) to deploy to. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&e.repo, "repo", "", "chart repository (name=url). Overrides $ORCA_REPO")
+	bindEnvVar(f, "repo", "ORCA_REPO")
+	f.StringVar(&e.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
 	f.StringSliceVarP(&e.packedValues, "values", "f", []string{}, "values file to use (packaged within the chart)")
 	f.StringSliceVarP(&e.set, "set", "s", []string{}, "set additional parameters")
-	f.BoolVar(&e.tls, "tls", utils.GetBoolEnvVar("ORCA_TLS", false), "enable TLS for request. Overrides $ORCA_TLS")
-	f.StringVar(&e.helmTLSStore, "helm-tls-store", os.Getenv("HELM_TLS_STORE"), "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
-	f.BoolVar(&e.inject, "inject", utils.GetBoolEnvVar("ORCA_INJECT", false), "enable injection during helm upgrade. Overrides $ORCA_INJECT (requires helm inject plugin: https://github.com/maorfr/helm-inject)")
-	f.IntVarP(&e.parallel, "parallel", "p", utils.GetIntEnvVar("ORCA_PARALLEL", 1), "number of releases to act on in parallel. set this flag to 0 for full parallelism. Overrides $ORCA_PARALLEL")
-	f.IntVar(&e.timeout, "timeout", utils.GetIntEnvVar("ORCA_TIMEOUT", 300), "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
-	f.StringSliceVar(&e.annotations, "annotations", []string{}, "additional environment (namespace) annotations (can specify multiple): annotation=value")
-	f.StringSliceVar(&e.labels, "labels", []string{}, "environment (namespace) labels (can specify multiple): label=value")
-	f.BoolVar(&e.validate, "validate", utils.GetBoolEnvVar("ORCA_VALIDATE", false), "perform environment validation after deployment. Overrides $ORCA_VALIDATE")
-	f.BoolVarP(&e.deployOnlyOverrideIfEnvExists, "deploy-only-override-if-env-exists", "x", utils.GetBoolEnvVar("ORCA_DEPLOY_ONLY_OVERRIDE_IF_ENV_EXISTS", false), "if environment exists - deploy only override(s) (avoid environment update). Overrides $ORCA_DEPLOY_ONLY_OVERRIDE_IF_ENV_EXISTS")
-	f.StringSliceVar(&e.protectedCharts, "protected-chart", []string{}, "chart name to protect from being overridden (can specify multiple)")
-
-	f.BoolVar(&e.refresh, "refresh", utils.GetBoolEnvVar("ORCA_REFRESH", false), "refresh the environment based on reference environment. Overrides $ORCA_REFRESH")
+	f.BoolVar(&e.tls, "tls", false, "enable TLS for request. Overrides $ORCA_TLS")
+	bindEnvVar(f, "tls", "ORCA_TLS")
+	f.StringVar(&e.helmTLSStore, "helm-tls-store", "", "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
+	bindEnvVar(f, "helm-tls-store", "HELM_TLS_STORE")
+	f.BoolVar(&e.inject, "inject", false, "enable injection during helm upgrade. Overrides $ORCA_INJECT (requires helm inject plugin: https://github.com/maorfr/helm-inject)")
+	bindEnvVar(f, "inject", "ORCA_INJECT")
+	f.IntVarP(&e.parallel, "parallel", "p", 1, "number of releases to act on in parallel. set this flag to 0 for full parallelism. Overrides $ORCA_PARALLEL")
+	bindEnvVar(f, "parallel", "ORCA_PARALLEL")
+	f.IntVar(&e.timeout, "timeout", 300, "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
+	bindSecondsEnvVar(f, "timeout", "ORCA_TIMEOUT")
+	f.StringSliceVar(&e.annotations, "annotations", []string{}, "additional environment (namespace) annotations (can specify multiple): annotation=value. Overrides $ORCA_ANNOTATIONS")
+	bindEnvVar(f, "annotations", "ORCA_ANNOTATIONS")
+	f.StringSliceVar(&e.labels, "labels", []string{}, "environment (namespace) labels (can specify multiple): label=value. Overrides $ORCA_LABELS")
+	bindEnvVar(f, "labels", "ORCA_LABELS")
+	f.BoolVar(&e.validate, "validate", false, "perform environment validation after deployment. Overrides $ORCA_VALIDATE")
+	bindEnvVar(f, "validate", "ORCA_VALIDATE")
+	f.BoolVarP(&e.deployOnlyOverrideIfEnvExists, "deploy-only-override-if-env-exists", "x", false, "if environment exists - deploy only override(s) (avoid environment update). Overrides $ORCA_DEPLOY_ONLY_OVERRIDE_IF_ENV_EXISTS")
+	bindEnvVar(f, "deploy-only-override-if-env-exists", "ORCA_DEPLOY_ONLY_OVERRIDE_IF_ENV_EXISTS")
+	f.StringSliceVar(&e.protectedCharts, "protected-chart", []string{}, "chart to protect from being overridden and deleted, optionally pinned to a version (chart or chart=version, can specify multiple). charts protected by this flag are deployed at their desired (or pinned) version, other protected charts keep their installed version. Overrides $ORCA_PROTECTED_CHART")
+	bindEnvVar(f, "protected-chart", "ORCA_PROTECTED_CHART")
+	f.StringVar(&e.helmClient, "helm-client", utils.HelmClientSDK, "helm client to use (sdk, exec). exec requires the helm binary. Overrides $ORCA_HELM_CLIENT")
+	bindEnvVar(f, "helm-client", "ORCA_HELM_CLIENT")
+	f.StringVar(&e.logDir, "log-dir", "", "directory to write a log file per release to. Overrides $ORCA_LOG_DIR")
+	bindEnvVar(f, "log-dir", "ORCA_LOG_DIR")
+	f.IntVar(&e.gracePeriod, "grace-period", utils.DefaultGracePeriod, "time in seconds to wait for in-flight releases when interrupted (SIGINT, SIGTERM) before killing them. Overrides $ORCA_GRACE_PERIOD")
+	bindSecondsEnvVar(f, "grace-period", "ORCA_GRACE_PERIOD")
+	f.IntVar(&e.lockTimeout, "lock-timeout", 0, "time in seconds to wait for a busy (locked) environment before failing. set this flag to 0 to wait indefinitely. Overrides $ORCA_LOCK_TIMEOUT")
+	bindSecondsEnvVar(f, "lock-timeout", "ORCA_LOCK_TIMEOUT")
+	f.StringVar(&e.envTemplate, "env-template", "", "path to environment template (manifest file or directory of manifests) of ResourceQuotas, LimitRanges, NetworkPolicies, Roles and RoleBindings to apply to the environment. Overrides $ORCA_ENV_TEMPLATE")
+	bindEnvVar(f, "env-template", "ORCA_ENV_TEMPLATE")
+	f.StringSliceVar(&e.copySecrets, "copy-secret", []string{}, "secret to copy into the environment and keep in sync (can specify multiple): namespace/name. Overrides $ORCA_COPY_SECRET")
+	bindEnvVar(f, "copy-secret", "ORCA_COPY_SECRET")
+	f.StringSliceVar(&e.copyConfigMaps, "copy-configmap", []string{}, "configmap to copy into the environment and keep in sync (can specify multiple): namespace/name. Overrides $ORCA_COPY_CONFIGMAP")
+	bindEnvVar(f, "copy-configmap", "ORCA_COPY_CONFIGMAP")
+	f.BoolVar(&e.imagePullSecrets, "image-pull-secrets", false, "add copied docker registry secrets to the imagePullSecrets of the environment's default service account. Overrides $ORCA_IMAGE_PULL_SECRETS")
+	bindEnvVar(f, "image-pull-secrets", "ORCA_IMAGE_PULL_SECRETS")
+
+	f.BoolVar(&e.refresh, "refresh", false, "refresh the environment based on reference environment. Overrides $ORCA_REFRESH")
+	bindEnvVar(f, "refresh", "ORCA_REFRESH")
 	f.MarkDeprecated("refresh", "this is now the default behavior. use -x to deploy only overrides")
 	return cmd
 }
 
 // NewDeleteEnvCmd represents the delete env command
-func NewDeleteEnvCmd(out io.Writer) *cobra.Command {
-	e := &envCmd{out: out}
+func NewDeleteEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &envCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "env",
@@ -332,67 +234,56 @@ func NewDeleteEnvCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			nsExists, err := utils.NamespaceExists(e.name, e.kubeContext)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if nsExists {
-				if err := markEnvironmentForDeletion(e.name, e.kubeContext, e.force, true); err != nil {
-					log.Fatal(err)
-				}
-			} else {
-				log.Printf("environment \"%s\" not found", e.name)
-			}
-
-			log.Print("getting currently deployed releases")
-			releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
-				KubeContext:   e.kubeContext,
-				Namespace:     e.name,
-				IncludeFailed: true,
-			})
-			if err != nil {
-				log.Fatal(err)
+			if isTerminal(os.Stdin) {
+				e.in = os.Stdin
 			}
-			log.Print("deleting releases")
-			if err := utils.DeleteReleases(utils.DeleteReleasesOptions{
-				ReleasesToDelete: releases,
-				KubeContext:      e.kubeContext,
-				TLS:              e.tls,
-				HelmTLSStore:     e.helmTLSStore,
-				Parallel:         e.parallel,
-				Timeout:          e.timeout,
-			}); err != nil {
-				markEnvironmentAsFailed(e.name, e.kubeContext, true)
-				log.Fatal(err)
+			if err := e.deleteEnv(); err != nil {
+				fatal(err)
 			}
-
-			if nsExists {
-				if utils.Contains([]string{"default", "kube-system", "kube-public"}, e.name) {
-					removeAnnotationsFromEnvironment(e.name, e.kubeContext, true)
-				} else {
-					utils.DeleteNamespace(e.name, e.kubeContext, false)
-				}
-			}
-			log.Printf("deleted environment \"%s\"", e.name)
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVarP(&e.name, "name", "n", os.Getenv("ORCA_NAME"), "name of environment (namespace) to delete. Overrides $ORCA_NAME")
-	f.StringVar(&e.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
-	f.BoolVar(&e.tls, "tls", utils.GetBoolEnvVar("ORCA_TLS", false), "enable TLS for request. Overrides $ORCA_TLS")
-	f.StringVar(&e.helmTLSStore, "helm-tls-store", os.Getenv("HELM_TLS_STORE"), "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
-	f.BoolVar(&e.force, "force", utils.GetBoolEnvVar("ORCA_FORCE", false), "force environment deletion. Overrides $ORCA_FORCE")
-	f.IntVarP(&e.parallel, "parallel", "p", utils.GetIntEnvVar("ORCA_PARALLEL", 1), "number of releases to act on in parallel. set this flag to 0 for full parallelism. Overrides $ORCA_PARALLEL")
-	f.IntVar(&e.timeout, "timeout", utils.GetIntEnvVar("ORCA_TIMEOUT", 300), "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
+	f.StringVarP(&e.name, "name", "n", "", "name of environment (namespace) to delete. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVarP(&e.chartsFile, "charts-file", "c", "", "path to file with list of Helm charts, used to delete releases in reverse order of their dependencies (default is the dependencies recorded when the environment was deployed). Overrides $ORCA_CHARTS_FILE")
+	bindEnvVar(f, "charts-file", "ORCA_CHARTS_FILE")
+	f.StringVar(&e.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.BoolVar(&e.tls, "tls", false, "enable TLS for request. Overrides $ORCA_TLS")
+	bindEnvVar(f, "tls", "ORCA_TLS")
+	f.StringVar(&e.helmTLSStore, "helm-tls-store", "", "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
+	bindEnvVar(f, "helm-tls-store", "HELM_TLS_STORE")
+	f.BoolVar(&e.force, "force", false, "force environment deletion. Overrides $ORCA_FORCE")
+	bindEnvVar(f, "force", "ORCA_FORCE")
+	f.StringVar(&e.helmClient, "helm-client", utils.HelmClientSDK, "helm client to use (sdk, exec). exec requires the helm binary. Overrides $ORCA_HELM_CLIENT")
+	bindEnvVar(f, "helm-client", "ORCA_HELM_CLIENT")
+	f.StringVar(&e.logDir, "log-dir", "", "directory to write a log file per release to. Overrides $ORCA_LOG_DIR")
+	bindEnvVar(f, "log-dir", "ORCA_LOG_DIR")
+	f.IntVar(&e.gracePeriod, "grace-period", utils.DefaultGracePeriod, "time in seconds to wait for in-flight releases when interrupted (SIGINT, SIGTERM) before killing them. Overrides $ORCA_GRACE_PERIOD")
+	bindSecondsEnvVar(f, "grace-period", "ORCA_GRACE_PERIOD")
+	f.IntVar(&e.lockTimeout, "lock-timeout", 0, "time in seconds to wait for a busy (locked) environment before failing. set this flag to 0 to wait indefinitely. Overrides $ORCA_LOCK_TIMEOUT")
+	bindSecondsEnvVar(f, "lock-timeout", "ORCA_LOCK_TIMEOUT")
+	f.IntVarP(&e.parallel, "parallel", "p", 1, "number of releases to act on in parallel. set this flag to 0 for full parallelism. Overrides $ORCA_PARALLEL")
+	bindEnvVar(f, "parallel", "ORCA_PARALLEL")
+	f.IntVar(&e.timeout, "timeout", 300, "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
+	bindSecondsEnvVar(f, "timeout", "ORCA_TIMEOUT")
+	f.BoolVar(&e.ignoreProtection, "ignore-protection", false, fmt.Sprintf("delete the environment even if it is protected from deletion (annotated with %s=true). Overrides $ORCA_IGNORE_PROTECTION", deletionProtectionAnnotation))
+	bindEnvVar(f, "ignore-protection", "ORCA_IGNORE_PROTECTION")
+	f.BoolVarP(&e.yes, "yes", "y", false, "do not ask for confirmation when attached to a terminal. Overrides $ORCA_YES")
+	bindEnvVar(f, "yes", "ORCA_YES")
+	f.IntVar(&e.waitTimeout, "wait-timeout", 300, "time in seconds to wait for the environment (namespace) to finish terminating. set this flag to 0 to not wait. Overrides $ORCA_WAIT_TIMEOUT")
+	bindSecondsEnvVar(f, "wait-timeout", "ORCA_WAIT_TIMEOUT")
+	f.BoolVar(&e.deleteOrphans, "delete-orphans", false, "delete cluster-scoped objects (e.g. ClusterRoles, PersistentVolumes) labelled with the names of the deleted releases. Overrides $ORCA_DELETE_ORPHANS")
+	bindEnvVar(f, "delete-orphans", "ORCA_DELETE_ORPHANS")
 
 	return cmd
 }
 
 // NewLockEnvCmd represents the lock env command
-func NewLockEnvCmd(out io.Writer) *cobra.Command {
-	e := &envCmd{out: out}
+func NewLockEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &envCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "env",
@@ -405,32 +296,27 @@ func NewLockEnvCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			nsExists, err := utils.NamespaceExists(e.name, e.kubeContext)
-			if err != nil {
-				log.Fatal(err)
+			if err := e.lockEnv(); err != nil {
+				fatal(err)
 			}
-			if !nsExists {
-				log.Printf("environment \"%s\" not found", e.name)
-				return
-			}
-			if err := lockEnvironment(e.name, e.kubeContext, false); err != nil {
-				log.Fatal(err)
-			}
-			log.Printf("locked environment \"%s\"", e.name)
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVarP(&e.name, "name", "n", os.Getenv("ORCA_NAME"), "name of environment (namespace) to lock. Overrides $ORCA_NAME")
-	f.StringVar(&e.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	f.StringVarP(&e.name, "name", "n", "", "name of environment (namespace) to lock. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&e.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.IntVar(&e.lockTimeout, "lock-timeout", 0, "time in seconds to wait for a busy (locked) environment before failing. set this flag to 0 to wait indefinitely. Overrides $ORCA_LOCK_TIMEOUT")
+	bindSecondsEnvVar(f, "lock-timeout", "ORCA_LOCK_TIMEOUT")
 
 	return cmd
 }
 
 // NewUnlockEnvCmd represents the unlock env command
-func NewUnlockEnvCmd(out io.Writer) *cobra.Command {
-	e := &envCmd{out: out}
+func NewUnlockEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &envCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "env",
@@ -443,25 +329,18 @@ func NewUnlockEnvCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			nsExists, err := utils.NamespaceExists(e.name, e.kubeContext)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if !nsExists {
-				log.Printf("environment \"%s\" not found", e.name)
-				return
-			}
-			if err := unlockEnvironment(e.name, e.kubeContext, false); err != nil {
-				log.Fatal(err)
+			if err := e.unlockEnv(); err != nil {
+				fatal(err)
 			}
-			log.Printf("unlocked environment \"%s\"", e.name)
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVarP(&e.name, "name", "n", os.Getenv("ORCA_NAME"), "name of environment (namespace) to unlock. Overrides $ORCA_NAME")
-	f.StringVar(&e.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	f.StringVarP(&e.name, "name", "n", "", "name of environment (namespace) to unlock. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&e.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
 
 	return cmd
 }
@@ -472,13 +351,15 @@ type diffEnvCmd struct {
 	kubeContextLeft  string
 	kubeContextRight string
 	output           string
+	exitCode         bool
 
-	out io.Writer
+	clients *utils.Clients
+	out     io.Writer
 }
 
 // NewDiffEnvCmd represents the diff env command
-func NewDiffEnvCmd(out io.Writer) *cobra.Command {
-	e := &diffEnvCmd{out: out}
+func NewDiffEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &diffEnvCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "env",
@@ -494,50 +375,33 @@ func NewDiffEnvCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			releasesLeft, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
-				KubeContext:   e.kubeContextLeft,
-				Namespace:     e.nameLeft,
-				IncludeFailed: false,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-			releasesRight, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
-				KubeContext:   e.kubeContextRight,
-				Namespace:     e.nameRight,
-				IncludeFailed: false,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			diffOptions := utils.DiffOptions{
-				KubeContextLeft:   e.kubeContextLeft,
-				KubeContextRight:  e.kubeContextRight,
-				EnvNameLeft:       e.nameLeft,
-				EnvNameRight:      e.nameRight,
-				ReleasesSpecLeft:  releasesLeft,
-				ReleasesSpecRight: releasesRight,
-				Output:            e.output,
+			if err := e.diffEnv(); err != nil {
+				fatal(err)
 			}
-			utils.PrintDiff(diffOptions)
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&e.nameLeft, "name-left", os.Getenv("ORCA_NAME_LEFT"), "name of left environment to compare. Overrides $ORCA_NAME_LEFT")
-	f.StringVar(&e.nameRight, "name-right", os.Getenv("ORCA_NAME_RIGHT"), "name of right environment to compare. Overrides $ORCA_NAME_RIGHT")
-	f.StringVar(&e.kubeContextLeft, "kube-context-left", os.Getenv("ORCA_KUBE_CONTEXT_LEFT"), "name of the left kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT_LEFT")
-	f.StringVar(&e.kubeContextRight, "kube-context-right", os.Getenv("ORCA_KUBE_CONTEXT_RIGHT"), "name of the right kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT_RIGHT")
-	f.StringVarP(&e.output, "output", "o", utils.GetStringEnvVar("ORCA_OUTPUT", "yaml"), "output format (yaml, table). Overrides $ORCA_OUTPUT")
+	f.StringVar(&e.nameLeft, "name-left", "", "name of left environment to compare. Overrides $ORCA_NAME_LEFT")
+	bindEnvVar(f, "name-left", "ORCA_NAME_LEFT")
+	f.StringVar(&e.nameRight, "name-right", "", "name of right environment to compare. Overrides $ORCA_NAME_RIGHT")
+	bindEnvVar(f, "name-right", "ORCA_NAME_RIGHT")
+	f.StringVar(&e.kubeContextLeft, "kube-context-left", "", "name of the left kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT_LEFT")
+	bindEnvVar(f, "kube-context-left", "ORCA_KUBE_CONTEXT_LEFT")
+	f.StringVar(&e.kubeContextRight, "kube-context-right", "", "name of the right kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT_RIGHT")
+	bindEnvVar(f, "kube-context-right", "ORCA_KUBE_CONTEXT_RIGHT")
+	f.StringVarP(&e.output, "output", "o", "yaml", "output format (yaml, table). Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
+	f.BoolVar(&e.exitCode, "exit-code", false, "exit with the drift detected exit code if there are differences between the environments. Overrides $ORCA_EXIT_CODE")
+	bindEnvVar(f, "exit-code", "ORCA_EXIT_CODE")
 
 	return cmd
 }
 
 // NewValidateEnvCmd represents the validate env command
-func NewValidateEnvCmd(out io.Writer) *cobra.Command {
-	e := &envCmd{out: out}
+func NewValidateEnvCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	e := &envCmd{clients: clients, out: out}
 
 	cmd := &cobra.Command{
 		Use:   "env",
@@ -550,53 +414,638 @@ func NewValidateEnvCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			log.Printf("validating environment \"%s\"", e.name)
-			nsExists, err := utils.NamespaceExists(e.name, e.kubeContext)
-			if err != nil {
-				log.Fatal(err)
+			if err := e.validateEnv(); err != nil {
+				fatal(err)
 			}
-			if !nsExists {
-				log.Fatalf("environment \"%s\" not found", e.name)
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVarP(&e.name, "name", "n", "", "name of environment (namespace) to validate. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&e.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+
+	return cmd
+}
+
+// getEnv prints the Helm releases in an environment
+func (e *envCmd) getEnv() error {
+	clientset, err := e.clients.KubeClientSet(e.kubeContext)
+	if err != nil {
+		return err
+	}
+	releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     e.name,
+		IncludeFailed: false,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch e.output {
+	case "yaml":
+		utils.PrintReleasesYaml(releases)
+	case "md":
+		utils.PrintReleasesMarkdown(releases)
+	case "table":
+		utils.PrintReleasesTable(releases)
+	case "":
+		utils.PrintReleasesYaml(releases)
+	}
+	return nil
+}
+
+// deployEnv deploys a list of Helm charts to an environment
+func (e *envCmd) deployEnv() error {
+	ctx, cancel := utils.WithGracePeriod(e.clients.Context, time.Duration(e.gracePeriod)*time.Second)
+	defer cancel()
+
+	helmClient, err := e.clients.Helm(e.helmClient)
+	if err != nil {
+		return err
+	}
+	clientset, err := e.clients.KubeClientSet(e.kubeContext)
+	if err != nil {
+		return err
+	}
+
+	log.Println("initializing chart repository configuration")
+	if err := helmClient.AddRepository(ctx, utils.AddRepositoryOptions{
+		Repo:  e.repo,
+		Print: false,
+	}); err != nil {
+		return err
+	}
+	if err := helmClient.UpdateRepositories(ctx, false); err != nil {
+		return err
+	}
+
+	annotations := map[string]string{}
+	for _, a := range e.annotations {
+		k, v, err := utils.SplitInTwo(a, "=")
+		if err != nil {
+			return err
+		}
+		annotations[k] = v
+	}
+	labels := map[string]string{}
+	for _, a := range e.labels {
+		k, v, err := utils.SplitInTwo(a, "=")
+		if err != nil {
+			return err
+		}
+		labels[k] = v
+	}
+	for _, c := range append(e.copySecrets, e.copyConfigMaps...) {
+		if _, _, err := utils.SplitInTwo(c, "/"); err != nil {
+			return err
+		}
+	}
+	addedProtectedCharts, err := parseProtectedCharts(e.protectedCharts)
+	if err != nil {
+		return err
+	}
+	var envTemplate []runtime.Object
+	if e.envTemplate != "" {
+		if envTemplate, err = utils.LoadEnvTemplate(e.envTemplate); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("deploying environment \"%s\"", e.name)
+	nsPreExists, err := utils.NamespaceExists(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	if !nsPreExists {
+		if err := utils.CreateNamespace(clientset, e.name, false); err != nil {
+			return err
+		}
+		log.Printf("created environment \"%s\"", e.name)
+	}
+	if err := lockEnvironment(ctx, clientset, e.name, time.Duration(e.lockTimeout)*time.Second, true); err != nil {
+		return err
+	}
+
+	if err := utils.UpdateNamespace(clientset, e.name, annotations, labels, true); err != nil {
+		return err
+	}
+	if e.envTemplate != "" {
+		log.Print("applying environment template")
+		if err := utils.ApplyEnvTemplate(clientset, e.name, envTemplate, true); err != nil {
+			unlockEnvironment(clientset, e.name, true)
+			return err
+		}
+	}
+	// Copies are only synced (and pruned) when objects to copy are set, so deployments without them keep existing copies
+	if len(e.copySecrets) != 0 || len(e.copyConfigMaps) != 0 {
+		log.Print("copying secrets and configmaps")
+		pullSecrets, prunedSecrets, err := utils.CopyObjects(utils.CopyObjectsOptions{
+			ClientSet:  clientset,
+			Namespace:  e.name,
+			Secrets:    e.copySecrets,
+			ConfigMaps: e.copyConfigMaps,
+			Print:      true,
+		})
+		if err == nil {
+			// References to deleted copies are removed even if copied secrets are no longer added
+			if !e.imagePullSecrets {
+				pullSecrets = nil
 			}
+			err = utils.UpdateImagePullSecrets(clientset, e.name, pullSecrets, prunedSecrets, true)
+		}
+		if err != nil {
+			unlockEnvironment(clientset, e.name, true)
+			return err
+		}
+	}
 
-			envValid, err := utils.IsEnvValid(e.name, e.kubeContext)
-			if err != nil {
-				log.Fatal(err)
+	log.Print("initializing releases to deploy")
+	desiredReleases, err := e.initDesiredReleases(nsPreExists)
+	if err != nil {
+		unlockEnvironment(clientset, e.name, true)
+		return err
+	}
+	// Dependencies of desired releases are modified while calculating the delta, record them beforehand
+	desiredDependencies := map[string][]string{}
+	if !nsPreExists || !e.deployOnlyOverrideIfEnvExists {
+		for _, r := range desiredReleases {
+			desiredDependencies[r.ChartName] = append([]string{}, r.Dependencies...)
+		}
+	}
+
+	log.Print("getting currently deployed releases")
+	installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     e.name,
+		IncludeFailed: false,
+	})
+	if err != nil {
+		unlockEnvironment(clientset, e.name, true)
+		return err
+	}
+
+	log.Print("updating protected charts")
+	protectedCharts, err := getProtectedCharts(clientset, e.name)
+	if err != nil {
+		unlockEnvironment(clientset, e.name, true)
+		return err
+	}
+	protectedCharts = mergeProtectedCharts(protectedCharts, addedProtectedCharts)
+	if len(addedProtectedCharts) != 0 {
+		if err := setProtectedCharts(clientset, e.name, protectedCharts, true); err != nil {
+			unlockEnvironment(clientset, e.name, true)
+			return err
+		}
+	}
+	desiredReleases = pinProtectedReleases(desiredReleases, installedReleases, protectedCharts, addedProtectedCharts)
+
+	log.Print("calculating delta between desired releases and currently deployed releases")
+	releasesToInstall := utils.GetReleasesDelta(desiredReleases, installedReleases)
+
+	if ctx.Err() != nil {
+		unlockEnvironment(clientset, e.name, true)
+		return fmt.Errorf("deployment of environment \"%s\" %w: %v", e.name, ErrInterrupted, ctx.Err())
+	}
+
+	log.Print("deploying releases")
+	if err := utils.DeployChartsFromRepository(ctx, utils.DeployChartsFromRepositoryOptions{
+		Helm:              helmClient,
+		ReleasesToInstall: releasesToInstall,
+		KubeContext:       e.kubeContext,
+		Namespace:         e.name,
+		Repo:              e.repo,
+		TLS:               e.tls,
+		HelmTLSStore:      e.helmTLSStore,
+		PackedValues:      e.packedValues,
+		SetValues:         e.set,
+		Inject:            e.inject,
+		Parallel:          e.parallel,
+		Timeout:           e.timeout,
+		Out:               e.out,
+		LogDir:            e.logDir,
+	}); err != nil {
+		markEnvironmentAsFailed(clientset, e.name, true)
+		return interruptedError(ctx, e.name, err)
+	}
+
+	if !e.deployOnlyOverrideIfEnvExists {
+		log.Print("getting currently deployed releases")
+		installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+			ClientSet:     clientset,
+			Namespace:     e.name,
+			IncludeFailed: false,
+		})
+		if err != nil {
+			markEnvironmentAsUnknown(clientset, e.name, true)
+			return err
+		}
+		log.Print("calculating delta between desired releases and currently deployed releases")
+		releasesToDelete := []utils.ReleaseSpec{}
+		for _, r := range utils.GetReleasesDelta(installedReleases, desiredReleases) {
+			if protectedChartIndex(protectedCharts, r.ChartName) != -1 {
+				log.Printf("skipping deletion of protected chart \"%s\"", r.ChartName)
+				continue
 			}
+			releasesToDelete = append(releasesToDelete, r)
+		}
+		log.Print("deleting undesired releases")
+		if err := utils.DeleteReleases(ctx, utils.DeleteReleasesOptions{
+			Helm:             helmClient,
+			ReleasesToDelete: releasesToDelete,
+			KubeContext:      e.kubeContext,
+			TLS:              e.tls,
+			HelmTLSStore:     e.helmTLSStore,
+			Parallel:         e.parallel,
+			Timeout:          e.timeout,
+			Out:              e.out,
+			LogDir:           e.logDir,
+		}); err != nil {
+			markEnvironmentAsFailed(clientset, e.name, true)
+			return interruptedError(ctx, e.name, err)
+		}
+	}
+	log.Print("recording environment metadata")
+	if err := e.recordMetadata(clientset, desiredDependencies, nsPreExists); err != nil {
+		unlockEnvironment(clientset, e.name, true)
+		return err
+	}
+	log.Printf("deployed environment \"%s\"", e.name)
+
+	if !e.validate {
+		unlockEnvironment(clientset, e.name, true)
+		return nil
+	}
+
+	envValid, err := utils.IsEnvValidWithLoopBackOff(ctx, clientset, e.name)
+	if err != nil {
+		if ctx.Err() != nil {
+			markEnvironmentAsUnknown(clientset, e.name, true)
+			return interruptedError(ctx, e.name, err)
+		}
+		unlockEnvironment(clientset, e.name, true)
+		return err
+	}
+	unlockEnvironment(clientset, e.name, true)
+	if !envValid {
+		markEnvironmentAsFailed(clientset, e.name, true)
+		return fmt.Errorf("environment \"%s\" %w", e.name, ErrValidationFailed)
+	}
+	// If we have made it so far, the environment is validated
+	log.Printf("environment \"%s\" validated!", e.name)
+	return nil
+}
 
-			if !envValid {
-				log.Fatalf("environment \"%s\" validation failed!", e.name)
+// initDesiredReleases returns the releases which should be deployed to the environment
+func (e *envCmd) initDesiredReleases(nsPreExists bool) ([]utils.ReleaseSpec, error) {
+	if nsPreExists && e.deployOnlyOverrideIfEnvExists {
+		return utils.InitReleases(e.name, e.override)
+	}
+	var desiredReleases []utils.ReleaseSpec
+	if e.chartsFile != "" {
+		releases, err := utils.InitReleasesFromChartsFile(e.chartsFile, e.name)
+		if err != nil {
+			return nil, err
+		}
+		desiredReleases = releases
+	}
+	return utils.OverrideReleases(desiredReleases, e.override, e.name)
+}
+
+// recordMetadata records the dependencies of the deployed releases and the hash of the charts file in the environment
+func (e *envCmd) recordMetadata(clientset kubernetes.Interface, desiredDependencies map[string][]string, nsPreExists bool) error {
+	metadata, err := utils.GetEnvironmentMetadata(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	for chart, deps := range desiredDependencies {
+		metadata.Dependencies[chart] = deps
+	}
+	installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     e.name,
+		IncludeFailed: true,
+	})
+	if err != nil {
+		return err
+	}
+	for chart := range metadata.Dependencies {
+		if utils.GetChartIndex(installedReleases, chart) == -1 {
+			delete(metadata.Dependencies, chart)
+		}
+	}
+	if e.chartsFile != "" && (!nsPreExists || !e.deployOnlyOverrideIfEnvExists) {
+		if metadata.ChartsFileHash, err = utils.ChartsFileHash(e.chartsFile); err != nil {
+			return err
+		}
+	}
+	return utils.UpdateEnvironmentMetadata(clientset, e.name, metadata, false)
+}
+
+// deleteEnv deletes an environment along with all Helm releases in it
+func (e *envCmd) deleteEnv() error {
+	ctx, cancel := utils.WithGracePeriod(e.clients.Context, time.Duration(e.gracePeriod)*time.Second)
+	defer cancel()
+
+	helmClient, err := e.clients.Helm(e.helmClient)
+	if err != nil {
+		return err
+	}
+	clientset, err := e.clients.KubeClientSet(e.kubeContext)
+	if err != nil {
+		return err
+	}
+	var chartsFileReleases []utils.ReleaseSpec
+	if e.chartsFile != "" {
+		if chartsFileReleases, err = utils.InitReleasesFromChartsFile(e.chartsFile, e.name); err != nil {
+			return err
+		}
+	}
+	nsExists, err := utils.NamespaceExists(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	if nsExists {
+		if err := e.checkDeletionProtection(clientset); err != nil {
+			return err
+		}
+	}
+	if e.in != nil && !e.yes {
+		if err := e.confirmDeletion(clientset); err != nil {
+			return err
+		}
+	}
+	if nsExists {
+		if err := markEnvironmentForDeletion(ctx, clientset, e.name, time.Duration(e.lockTimeout)*time.Second, e.force, true); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("environment \"%s\" not found", e.name)
+	}
+
+	log.Print("getting currently deployed releases")
+	releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     e.name,
+		IncludeFailed: true,
+	})
+	if err != nil {
+		return err
+	}
+	// Dependencies are taken from the charts file if set, or from the dependencies recorded in the environment
+	if e.chartsFile != "" {
+		releases = withDependencies(releases, chartsFileReleases)
+	}
+	log.Print("deleting releases")
+	if err := utils.DeleteReleases(ctx, utils.DeleteReleasesOptions{
+		Helm:             helmClient,
+		ReleasesToDelete: releases,
+		KubeContext:      e.kubeContext,
+		TLS:              e.tls,
+		HelmTLSStore:     e.helmTLSStore,
+		Parallel:         e.parallel,
+		Timeout:          e.timeout,
+		Out:              e.out,
+		LogDir:           e.logDir,
+	}); err != nil {
+		markEnvironmentAsFailed(clientset, e.name, true)
+		return interruptedError(ctx, e.name, err)
+	}
+
+	if e.deleteOrphans {
+		log.Print("deleting orphaned cluster-scoped objects")
+		releaseNames := []string{}
+		for _, r := range releases {
+			releaseNames = append(releaseNames, r.ReleaseName)
+		}
+		if err := utils.DeleteOrphanedObjects(clientset, releaseNames, true); err != nil {
+			markEnvironmentAsFailed(clientset, e.name, true)
+			return err
+		}
+	}
+
+	if nsExists {
+		if utils.Contains([]string{"default", "kube-system", "kube-public"}, e.name) {
+			removeAnnotationsFromEnvironment(clientset, e.name, true)
+		} else {
+			if err := utils.DeleteNamespace(clientset, e.name, false); err != nil {
+				markEnvironmentAsFailed(clientset, e.name, true)
+				return err
 			}
-			// If we have made it so far, the environment is validated
-			log.Printf("environment \"%s\" validated!", e.name)
-		},
+			if e.waitTimeout > 0 {
+				log.Printf("waiting for environment \"%s\" to terminate", e.name)
+				if err := utils.WaitForNamespaceDeletion(ctx, clientset, e.name, time.Duration(e.waitTimeout)*time.Second); err != nil {
+					return err
+				}
+			}
+		}
 	}
+	log.Printf("deleted environment \"%s\"", e.name)
+	return nil
+}
 
-	f := cmd.Flags()
+// checkDeletionProtection returns an error if the environment is protected from deletion and the protection is not ignored
+func (e *envCmd) checkDeletionProtection(clientset kubernetes.Interface) error {
+	ns, err := utils.GetNamespace(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	value, ok := ns.Annotations[deletionProtectionAnnotation]
+	if !ok {
+		return nil
+	}
+	protected, err := utils.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid value of annotation %s: %v", deletionProtectionAnnotation, err)
+	}
+	if !protected {
+		return nil
+	}
+	if e.ignoreProtection {
+		log.Printf("environment \"%s\" is protected from deletion, ignoring protection", e.name)
+		return nil
+	}
+	return fmt.Errorf("%w: environment \"%s\" is protected from deletion (%s=%s). use --ignore-protection to delete it", ErrEnvironmentProtected, e.name, deletionProtectionAnnotation, value)
+}
 
-	f.StringVarP(&e.name, "name", "n", os.Getenv("ORCA_NAME"), "name of environment (namespace) to validate. Overrides $ORCA_NAME")
-	f.StringVar(&e.kubeContext, "kube-context", os.Getenv("ORCA_KUBE_CONTEXT"), "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+// confirmDeletion asks for confirmation to delete the environment and the releases in it
+func (e *envCmd) confirmDeletion(clientset kubernetes.Interface) error {
+	releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     e.name,
+		IncludeFailed: true,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "environment \"%s\" and %d release(s) in it will be deleted:\n", e.name, len(releases))
+	for _, r := range releases {
+		fmt.Fprintf(e.out, "  %s (%s-%s)\n", r.ReleaseName, r.ChartName, r.ChartVersion)
+	}
+	fmt.Fprint(e.out, "are you sure? [y/N]: ")
+	answer, err := bufio.NewReader(e.in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("deletion of environment \"%s\" was not confirmed", e.name)
+}
 
-	return cmd
+// isTerminal reports whether a file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// lockEnv locks an environment
+func (e *envCmd) lockEnv() error {
+	clientset, err := e.clients.KubeClientSet(e.kubeContext)
+	if err != nil {
+		return err
+	}
+	nsExists, err := utils.NamespaceExists(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	if !nsExists {
+		log.Printf("environment \"%s\" not found", e.name)
+		return nil
+	}
+	if err := lockEnvironment(e.clients.Context, clientset, e.name, time.Duration(e.lockTimeout)*time.Second, false); err != nil {
+		return err
+	}
+	log.Printf("locked environment \"%s\"", e.name)
+	return nil
+}
+
+// unlockEnv unlocks an environment
+func (e *envCmd) unlockEnv() error {
+	clientset, err := e.clients.KubeClientSet(e.kubeContext)
+	if err != nil {
+		return err
+	}
+	nsExists, err := utils.NamespaceExists(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	if !nsExists {
+		log.Printf("environment \"%s\" not found", e.name)
+		return nil
+	}
+	if err := unlockEnvironment(clientset, e.name, false); err != nil {
+		return err
+	}
+	log.Printf("unlocked environment \"%s\"", e.name)
+	return nil
+}
+
+// diffEnv prints the differences in Helm releases between environments
+func (e *diffEnvCmd) diffEnv() error {
+	clientsetLeft, err := e.clients.KubeClientSet(e.kubeContextLeft)
+	if err != nil {
+		return err
+	}
+	releasesLeft, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientsetLeft,
+		Namespace:     e.nameLeft,
+		IncludeFailed: false,
+	})
+	if err != nil {
+		return err
+	}
+	clientsetRight, err := e.clients.KubeClientSet(e.kubeContextRight)
+	if err != nil {
+		return err
+	}
+	releasesRight, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientsetRight,
+		Namespace:     e.nameRight,
+		IncludeFailed: false,
+	})
+	if err != nil {
+		return err
+	}
+
+	diffOptions := utils.DiffOptions{
+		KubeContextLeft:   e.kubeContextLeft,
+		KubeContextRight:  e.kubeContextRight,
+		EnvNameLeft:       e.nameLeft,
+		EnvNameRight:      e.nameRight,
+		ReleasesSpecLeft:  releasesLeft,
+		ReleasesSpecRight: releasesRight,
+		Output:            e.output,
+	}
+	if utils.PrintDiff(diffOptions) && e.exitCode {
+		return fmt.Errorf("%w between environments \"%s\" and \"%s\"", ErrDriftDetected, e.nameLeft, e.nameRight)
+	}
+	return nil
+}
+
+// validateEnv validates an environment
+func (e *envCmd) validateEnv() error {
+	clientset, err := e.clients.KubeClientSet(e.kubeContext)
+	if err != nil {
+		return err
+	}
+	log.Printf("validating environment \"%s\"", e.name)
+	nsExists, err := utils.NamespaceExists(clientset, e.name)
+	if err != nil {
+		return err
+	}
+	if !nsExists {
+		return fmt.Errorf("environment \"%s\" not found", e.name)
+	}
+
+	envValid, err := utils.IsEnvValid(clientset, e.name)
+	if err != nil {
+		return err
+	}
+
+	if !envValid {
+		return fmt.Errorf("environment \"%s\" %w", e.name, ErrValidationFailed)
+	}
+	// If we have made it so far, the environment is validated
+	log.Printf("environment \"%s\" validated!", e.name)
+	return nil
 }
 
-// lockEnvironment annotates a namespace with "busy"
-func lockEnvironment(name, kubeContext string, print bool) error {
+// lockEnvironment annotates a namespace with "busy".
+// If the namespace is already busy, it waits for it to become free for up to timeout (0 waits indefinitely)
+func lockEnvironment(ctx context.Context, clientset kubernetes.Interface, name string, timeout time.Duration, print bool) error {
 	sleepPeriod := 5 * time.Second
-	ns, err := utils.GetNamespace(name, kubeContext)
+	ns, err := utils.GetNamespace(clientset, name)
 	if err != nil {
 		return err
 	}
 	state := ns.Annotations[stateAnnotation]
 	if state != "" {
 		if state != freeState && state != busyState {
-			return fmt.Errorf("Environment state is %s", state)
+			return fmt.Errorf("%w: environment \"%s\" state is %s", ErrEnvironmentLocked, name, state)
 		}
+		deadline := time.Now().Add(timeout)
 		for state == busyState {
-			log.Printf("environment \"%s\" %s, backing off for %d seconds", name, busyState, int(sleepPeriod.Seconds()))
-			time.Sleep(sleepPeriod)
+			if timeout > 0 && time.Now().After(deadline) {
+				return fmt.Errorf("%w: environment \"%s\" is %s for more than %d seconds", ErrEnvironmentLocked, name, busyState, int(timeout.Seconds()))
+			}
+			backOff := sleepPeriod
+			if remaining := time.Until(deadline); timeout > 0 && remaining < backOff {
+				backOff = remaining
+			}
+			log.Printf("environment \"%s\" %s, backing off for %d seconds", name, busyState, int(backOff.Seconds()))
+			if err := utils.Sleep(ctx, backOff); err != nil {
+				return err
+			}
 			sleepPeriod += 5 * time.Second
-			ns, err := utils.GetNamespace(name, kubeContext)
+			ns, err := utils.GetNamespace(clientset, name)
 			if err != nil {
 				return err
 			}
@@ -605,95 +1054,70 @@ func lockEnvironment(name, kubeContext string, print bool) error {
 	}
 	// There is a race condition here, may need to attend to it in the future
 	annotations := map[string]string{stateAnnotation: busyState}
-	err = utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
+	err = utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
 
 	return err
 }
 
 // unlockEnvironment annotates a namespace with "free"
-func unlockEnvironment(name, kubeContext string, print bool) error {
-	ns, err := utils.GetNamespace(name, kubeContext)
+func unlockEnvironment(clientset kubernetes.Interface, name string, print bool) error {
+	ns, err := utils.GetNamespace(clientset, name)
 	if err != nil {
 		return err
 	}
 	state := ns.Annotations[stateAnnotation]
 	if state != "" {
 		if state != freeState && state != busyState {
-			return fmt.Errorf("Environment state is %s", state)
+			return fmt.Errorf("%w: environment \"%s\" state is %s", ErrEnvironmentLocked, name, state)
 		}
 	}
 	annotations := map[string]string{stateAnnotation: freeState}
-	err = utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
+	err = utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
 
 	return err
 }
 
 // markEnvironmentForDeletion annotates a namespace with "delete"
-func markEnvironmentForDeletion(name, kubeContext string, force, print bool) error {
+func markEnvironmentForDeletion(ctx context.Context, clientset kubernetes.Interface, name string, lockTimeout time.Duration, force, print bool) error {
 	if !force {
-		if err := lockEnvironment(name, kubeContext, print); err != nil {
+		if err := lockEnvironment(ctx, clientset, name, lockTimeout, print); err != nil {
 			return err
 		}
 	}
 	annotations := map[string]string{stateAnnotation: deleteState}
-	err := utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
+	err := utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
 
 	return err
 }
 
 // markEnvironmentAsFailed annotates a namespace with "failed"
-func markEnvironmentAsFailed(name, kubeContext string, print bool) error {
+func markEnvironmentAsFailed(clientset kubernetes.Interface, name string, print bool) error {
 	annotations := map[string]string{stateAnnotation: failedState}
-	err := utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
+	err := utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
 
 	return err
 }
 
 // markEnvironmentAsUnknown annotates a namespace with "unknown"
-func markEnvironmentAsUnknown(name, kubeContext string, print bool) error {
+func markEnvironmentAsUnknown(clientset kubernetes.Interface, name string, print bool) error {
 	annotations := map[string]string{stateAnnotation: unknownState}
-	err := utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
+	err := utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
 
 	return err
 }
 
 // removeAnnotationsFromEnvironment removes annotations from a namespace
-func removeAnnotationsFromEnvironment(name, kubeContext string, print bool) error {
+func removeAnnotationsFromEnvironment(clientset kubernetes.Interface, name string, print bool) error {
 	annotations := map[string]string{}
-	err := utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
+	err := utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
 
 	return err
 }
 
-func updateProtectedCharts(name, kubeContext string, protectedChartsToAdd []string, print bool) ([]string, error) {
-	ns, err := utils.GetNamespace(name, kubeContext)
-	if err != nil {
-		return nil, err
-	}
-
-	protectedCharts := strings.Split(ns.Annotations[protectedAnnotation], ",")
-	if len(protectedCharts) == 0 && len(protectedChartsToAdd) == 0 {
-		return []string{}, nil
-	}
-
-	for _, pcta := range protectedChartsToAdd {
-		if utils.Contains(protectedCharts, pcta) {
-			continue
-		}
-		protectedCharts = append(protectedCharts, pcta)
-	}
-
-	annotationValue := strings.Trim(strings.Join(protectedCharts, ","), ",")
-	annotations := map[string]string{protectedAnnotation: annotationValue}
-
-	protectedChartsWithoutAdds := []string{}
-	for _, pr := range protectedCharts {
-		if utils.Contains(protectedChartsToAdd, pr) {
-			continue
-		}
-		protectedChartsWithoutAdds = append(protectedChartsWithoutAdds, pr)
+// interruptedError describes an error of an environment operation which was interrupted
+func interruptedError(ctx context.Context, name string, err error) error {
+	if ctx.Err() == nil {
+		return err
 	}
-
-	err = utils.UpdateNamespace(name, kubeContext, annotations, map[string]string{}, print)
-	return protectedChartsWithoutAdds, err
+	return fmt.Errorf("operation on environment \"%s\" %w, environment state is not guaranteed: %v", name, ErrInterrupted, err)
 }
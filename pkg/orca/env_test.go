@@ -0,0 +1,886 @@
+package orca
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+	"github.com/nuvo/orca/pkg/utils/fake"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testEnv = "test"
+
+func newTestEnvCmd(clientset kubernetes.Interface, helmClient utils.HelmClient) *envCmd {
+	return &envCmd{
+		name:    testEnv,
+		repo:    "myrepo=https://charts.example.com",
+		clients: fake.NewClients(clientset, helmClient),
+		out:     ioutil.Discard,
+	}
+}
+
+func installedCharts(t *testing.T, clientset kubernetes.Interface, name string) []string {
+	releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     name,
+		IncludeFailed: true,
+	})
+	if err != nil {
+		t.Fatalf("failed getting installed releases: %v", err)
+	}
+	charts := []string{}
+	for _, r := range releases {
+		charts = append(charts, r.ChartName+"="+r.ChartVersion)
+	}
+	sort.Strings(charts)
+	return charts
+}
+
+func envState(t *testing.T, clientset kubernetes.Interface, name string) string {
+	ns, err := clientset.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting namespace: %v", err)
+	}
+	return ns.Annotations[stateAnnotation]
+}
+
+func TestDeployEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		objects       []runtime.Object
+		override      []string
+		upgradeErrors map[string]error
+		wantErr       bool
+		wantState     string
+		wantCharts    []string
+	}{
+		{
+			name:       "new environment",
+			wantState:  freeState,
+			wantCharts: []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+		{
+			name:       "new environment with override",
+			override:   []string{"kaa=0.2.0"},
+			wantState:  freeState,
+			wantCharts: []string{"cassandra=0.4.0", "kaa=0.2.0", "mariadb=0.5.4"},
+		},
+		{
+			name:          "release fails to deploy",
+			upgradeErrors: map[string]error{testEnv + "-kaa": errors.New("timed out")},
+			wantErr:       true,
+			wantState:     failedState,
+			wantCharts:    []string{"cassandra=0.4.0", "mariadb=0.5.4"},
+		},
+		{
+			name:       "environment in failed state",
+			objects:    []runtime.Object{fake.Namespace(testEnv, map[string]string{stateAnnotation: failedState})},
+			wantErr:    true,
+			wantState:  failedState,
+			wantCharts: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientSet(tt.objects...)
+			helmClient := fake.NewHelmClient(clientset)
+			for k, v := range tt.upgradeErrors {
+				helmClient.UpgradeErrors[k] = v
+			}
+			e := newTestEnvCmd(clientset, helmClient)
+			e.chartsFile = "testdata/charts.yaml"
+			e.override = tt.override
+			e.parallel = 0
+
+			err := e.deployEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deployEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := envState(t, clientset, testEnv); got != tt.wantState {
+				t.Errorf("deployEnv() state = %v, want %v", got, tt.wantState)
+			}
+			if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, tt.wantCharts) {
+				t.Errorf("deployEnv() charts = %v, want %v", got, tt.wantCharts)
+			}
+		})
+	}
+}
+
+func TestDeployEnv_DeletesUndesiredReleases(t *testing.T) {
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.chartsFile = "testdata/charts.yaml"
+	e.parallel = 0
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+
+	e.chartsFile = ""
+	e.override = []string{"cassandra=0.4.0"}
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+
+	want := []string{"cassandra=0.4.0"}
+	if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, want) {
+		t.Errorf("deployEnv() charts = %v, want %v", got, want)
+	}
+	sort.Strings(helmClient.Deleted)
+	wantDeleted := []string{testEnv + "-kaa", testEnv + "-mariadb"}
+	if !reflect.DeepEqual(helmClient.Deleted, wantDeleted) {
+		t.Errorf("deployEnv() deleted = %v, want %v", helmClient.Deleted, wantDeleted)
+	}
+}
+
+func TestDeployEnv_RecordsDependencies(t *testing.T) {
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.chartsFile = "testdata/charts.yaml"
+	e.parallel = 0
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	hash, err := utils.ChartsFileHash(e.chartsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deploying only overrides keeps the recorded dependencies
+	e.chartsFile = ""
+	e.override = []string{"kaa=0.2.0"}
+	e.deployOnlyOverrideIfEnvExists = true
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	metadata, err := utils.GetEnvironmentMetadata(clientset, testEnv)
+	if err != nil {
+		t.Fatalf("GetEnvironmentMetadata() error = %v", err)
+	}
+	if metadata.ChartsFileHash != hash {
+		t.Errorf("recorded charts file hash = %v, want %v", metadata.ChartsFileHash, hash)
+	}
+	releases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{ClientSet: clientset, Namespace: testEnv})
+	if err != nil {
+		t.Fatalf("GetInstalledReleases() error = %v", err)
+	}
+	kaa := releases[utils.GetChartIndex(releases, "kaa")]
+	if want := []string{"cassandra", "mariadb"}; !reflect.DeepEqual(kaa.Dependencies, want) {
+		t.Errorf("installed kaa depends on %v, want %v", kaa.Dependencies, want)
+	}
+
+	// Recorded dependencies are used to delete releases in reverse order
+	if err := e.deleteEnv(); err != nil {
+		t.Fatalf("deleteEnv() error = %v", err)
+	}
+	if got := helmClient.Deleted[0]; got != testEnv+"-kaa" {
+		t.Errorf("deleteEnv() deleted %v, want %s first", helmClient.Deleted, testEnv+"-kaa")
+	}
+}
+
+func TestDeployEnv_EnvTemplate(t *testing.T) {
+	unmanaged := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "allow-monitoring", Namespace: testEnv}}
+	clientset := fake.NewClientSet(unmanaged)
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.override = []string{"cassandra=0.4.0"}
+	e.envTemplate = "testdata/env-template"
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	quota, err := clientset.CoreV1().ResourceQuotas(testEnv).Get("compute", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting resource quota: %v", err)
+	}
+	if quota.Labels[utils.EnvTemplateLabel] != "true" {
+		t.Errorf("resource quota labels = %v, want %s label", quota.Labels, utils.EnvTemplateLabel)
+	}
+	if _, err := clientset.RbacV1().RoleBindings(testEnv).Get("developers-edit", metav1.GetOptions{}); err != nil {
+		t.Errorf("failed getting role binding: %v", err)
+	}
+
+	// Objects removed from the template are deleted, objects not created from it are kept
+	e.envTemplate = "testdata/env-template/quota.yaml"
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	policies, err := clientset.NetworkingV1().NetworkPolicies(testEnv).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed listing network policies: %v", err)
+	}
+	if len(policies.Items) != 1 || policies.Items[0].Name != unmanaged.Name {
+		t.Errorf("network policies = %v, want only %s", policies.Items, unmanaged.Name)
+	}
+	if _, err := clientset.RbacV1().RoleBindings(testEnv).Get("developers-edit", metav1.GetOptions{}); err == nil {
+		t.Error("role binding removed from the template was not deleted")
+	}
+	if _, err := clientset.CoreV1().LimitRanges(testEnv).Get("defaults", metav1.GetOptions{}); err != nil {
+		t.Errorf("failed getting limit range: %v", err)
+	}
+
+	e.envTemplate = "testdata/env-template-invalid"
+	if err := e.deployEnv(); ExitCode(err) != ExitCodeInvalidInput {
+		t.Errorf("deployEnv() error = %v, exit code = %v, want %v", err, ExitCode(err), ExitCodeInvalidInput)
+	}
+	if got := envState(t, clientset, testEnv); got != freeState {
+		t.Errorf("deployEnv() state = %v, want %v", got, freeState)
+	}
+}
+
+func TestDeployEnv_CopyObjects(t *testing.T) {
+	const shared = "shared"
+	clientset := fake.NewClientSet(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: shared},
+			Type:       v1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{v1.DockerConfigJsonKey: []byte("{}")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: shared},
+			Type:       v1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{v1.DockerConfigJsonKey: []byte("{}")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: shared},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "endpoints", Namespace: shared},
+			Data:       map[string]string{"db": "db.shared"},
+		},
+	)
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.override = []string{"cassandra=0.4.0"}
+	e.copySecrets = []string{shared + "/registry", shared + "/mirror", shared + "/db"}
+	e.copyConfigMaps = []string{shared + "/endpoints"}
+	e.imagePullSecrets = true
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	secret, err := clientset.CoreV1().Secrets(testEnv).Get("db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting copied secret: %v", err)
+	}
+	if string(secret.Data["password"]) != "secret" || secret.Annotations[utils.CopiedFromAnnotation] != shared+"/db" {
+		t.Errorf("copied secret = %+v", secret)
+	}
+	sa, err := clientset.CoreV1().ServiceAccounts(testEnv).Get("default", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting service account: %v", err)
+	}
+	if want := []v1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}; !reflect.DeepEqual(sa.ImagePullSecrets, want) {
+		t.Errorf("service account image pull secrets = %v, want %v", sa.ImagePullSecrets, want)
+	}
+
+	// Copies are kept in sync with their source, and deleted when no longer copied
+	configMaps := clientset.CoreV1().ConfigMaps(shared)
+	cm, _ := configMaps.Get("endpoints", metav1.GetOptions{})
+	cm.Data["db"] = "db2.shared"
+	if _, err := configMaps.Update(cm); err != nil {
+		t.Fatal(err)
+	}
+	e.copySecrets = []string{shared + "/registry"}
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	if cm, err := clientset.CoreV1().ConfigMaps(testEnv).Get("endpoints", metav1.GetOptions{}); err != nil || cm.Data["db"] != "db2.shared" {
+		t.Errorf("copied configmap = %+v, error = %v, want synced with source", cm, err)
+	}
+	if _, err := clientset.CoreV1().Secrets(testEnv).Get("db", metav1.GetOptions{}); err == nil {
+		t.Error("secret which is no longer copied was not deleted")
+	}
+	// References to deleted image pull secrets are removed from the service account
+	sa, _ = clientset.CoreV1().ServiceAccounts(testEnv).Get("default", metav1.GetOptions{})
+	if want := []v1.LocalObjectReference{{Name: "registry"}}; !reflect.DeepEqual(sa.ImagePullSecrets, want) {
+		t.Errorf("service account image pull secrets = %v, want %v", sa.ImagePullSecrets, want)
+	}
+
+	// Deployments without objects to copy keep the existing copies
+	e.copySecrets, e.copyConfigMaps = []string{}, []string{}
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	if _, err := clientset.CoreV1().Secrets(testEnv).Get("registry", metav1.GetOptions{}); err != nil {
+		t.Errorf("copied secret was deleted by a deployment without objects to copy: %v", err)
+	}
+	if _, err := clientset.CoreV1().ConfigMaps(testEnv).Get("endpoints", metav1.GetOptions{}); err != nil {
+		t.Errorf("copied configmap was deleted by a deployment without objects to copy: %v", err)
+	}
+	sa, _ = clientset.CoreV1().ServiceAccounts(testEnv).Get("default", metav1.GetOptions{})
+	if want := []v1.LocalObjectReference{{Name: "registry"}}; !reflect.DeepEqual(sa.ImagePullSecrets, want) {
+		t.Errorf("service account image pull secrets = %v, want %v", sa.ImagePullSecrets, want)
+	}
+
+	e.copySecrets = []string{"registry"}
+	if err := e.deployEnv(); ExitCode(err) != ExitCodeInvalidInput {
+		t.Errorf("deployEnv() error = %v, exit code = %v, want %v", err, ExitCode(err), ExitCodeInvalidInput)
+	}
+}
+
+func TestDeployEnv_CopyObjectsConflicts(t *testing.T) {
+	tls := func(namespace string, labels, annotations map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: namespace, Labels: labels, Annotations: annotations},
+			Data:       map[string][]byte{"tls.crt": []byte(namespace)},
+		}
+	}
+	copied := map[string]string{utils.CopiedLabel: "true"}
+	tests := []struct {
+		name         string
+		existing     *v1.Secret
+		copySecrets  []string
+		wantErr      bool
+		wantExitCode int
+		wantData     string
+	}{
+		{
+			name:        "copy of the same source is updated",
+			existing:    tls(testEnv, copied, map[string]string{utils.CopiedFromAnnotation: "ns1/tls"}),
+			copySecrets: []string{"ns1/tls"},
+			wantData:    "ns1",
+		},
+		{
+			name:        "object which is not a copy is not overwritten",
+			existing:    tls(testEnv, nil, nil),
+			copySecrets: []string{"ns1/tls"},
+			wantErr:     true,
+			wantData:    testEnv,
+		},
+		{
+			name:        "copy of another source is not overwritten",
+			existing:    tls(testEnv, copied, map[string]string{utils.CopiedFromAnnotation: "ns2/tls"}),
+			copySecrets: []string{"ns1/tls"},
+			wantErr:     true,
+			wantData:    testEnv,
+		},
+		{
+			name:         "sources copied to the same name",
+			copySecrets:  []string{"ns1/tls", "ns2/tls"},
+			wantErr:      true,
+			wantExitCode: ExitCodeInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{tls("ns1", nil, nil), tls("ns2", nil, nil)}
+			if tt.existing != nil {
+				objects = append(objects, tt.existing)
+			}
+			clientset := fake.NewClientSet(objects...)
+			e := newTestEnvCmd(clientset, fake.NewHelmClient(clientset))
+			e.override = []string{"cassandra=0.4.0"}
+			e.copySecrets = tt.copySecrets
+			err := e.deployEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deployEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantExitCode != 0 && ExitCode(err) != tt.wantExitCode {
+				t.Errorf("deployEnv() exit code = %v, want %v", ExitCode(err), tt.wantExitCode)
+			}
+			secret, err := clientset.CoreV1().Secrets(testEnv).Get("tls", metav1.GetOptions{})
+			if tt.wantData == "" {
+				if err == nil {
+					t.Errorf("secret was copied: %+v", secret)
+				}
+				return
+			}
+			if err != nil || string(secret.Data["tls.crt"]) != tt.wantData {
+				t.Errorf("secret = %+v, error = %v, want data of %s", secret, err, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestDeployEnv_ReleaseOutput(t *testing.T) {
+	logDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(logDir)
+
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	helmClient.UpgradeErrors[testEnv+"-mariadb"] = errors.New("timed out waiting for the condition")
+	var out bytes.Buffer
+	e := newTestEnvCmd(clientset, helmClient)
+	e.out = &out
+	e.chartsFile = "testdata/charts.yaml"
+	e.logDir = logDir
+
+	err = e.deployEnv()
+	if err == nil {
+		t.Fatal("deployEnv() expected an error")
+	}
+	if !strings.Contains(err.Error(), "Error: timed out waiting for the condition") {
+		t.Errorf("deployEnv() error does not contain the release output tail: %v", err)
+	}
+	if !strings.Contains(out.String(), "[test-cassandra] upgrading release test-cassandra\n") {
+		t.Errorf("deployEnv() did not stream prefixed release output: %q", out.String())
+	}
+	log, err := ioutil.ReadFile(filepath.Join(logDir, testEnv+"-mariadb.log"))
+	if err != nil {
+		t.Fatalf("failed reading release log: %v", err)
+	}
+	if want := "upgrading release test-mariadb\nError: timed out waiting for the condition\n"; string(log) != want {
+		t.Errorf("release log = %q, want %q", string(log), want)
+	}
+}
+
+func TestDeployEnv_Interrupted(t *testing.T) {
+	tests := []struct {
+		name        string
+		interruptAt string
+		wantState   string
+		wantCharts  []string
+	}{
+		{
+			name:       "interrupted before deployment",
+			wantState:  freeState,
+			wantCharts: []string{},
+		},
+		{
+			name:        "interrupted during deployment",
+			interruptAt: testEnv + "-cassandra",
+			wantState:   failedState,
+			wantCharts:  []string{"cassandra=0.4.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, interrupt := context.WithCancel(context.Background())
+			defer interrupt()
+			clientset := fake.NewClientSet(fake.Namespace(testEnv, nil))
+			helmClient := fake.NewHelmClient(clientset)
+			if tt.interruptAt == "" {
+				interrupt()
+			}
+			helmClient.OnUpgrade = func(releaseName string) {
+				if releaseName == tt.interruptAt {
+					interrupt()
+				}
+			}
+			e := newTestEnvCmd(clientset, helmClient)
+			e.clients.Context = ctx
+			e.chartsFile = "testdata/charts.yaml"
+			e.parallel = 1
+			e.gracePeriod = 5
+
+			err := e.deployEnv()
+			if err == nil || !strings.Contains(err.Error(), "interrupted") {
+				t.Fatalf("deployEnv() error = %v, want interrupted", err)
+			}
+			if got := envState(t, clientset, testEnv); got != tt.wantState {
+				t.Errorf("deployEnv() state = %v, want %v", got, tt.wantState)
+			}
+			if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, tt.wantCharts) {
+				t.Errorf("deployEnv() charts = %v, want %v", got, tt.wantCharts)
+			}
+		})
+	}
+}
+
+func TestDeleteEnv(t *testing.T) {
+	orphans := []runtime.Object{
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "kaa", Labels: map[string]string{"release": testEnv + "-kaa"}}},
+		&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-data", Labels: map[string]string{"app.kubernetes.io/instance": testEnv + "-cassandra"}}},
+		&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "other-data", Labels: map[string]string{"release": "other-cassandra"}}},
+	}
+	tests := []struct {
+		name             string
+		state            string
+		annotations      map[string]string
+		force            bool
+		ignoreProtection bool
+		confirmation     string
+		deleteOrphans    bool
+		deleteErrors     map[string]error
+		wantErr          bool
+		wantExitCode     int
+		wantNS           bool
+		wantCharts       []string
+		wantPVs          []string
+	}{
+		{
+			name:       "free environment",
+			state:      freeState,
+			wantCharts: []string{},
+		},
+		{
+			name:       "busy environment with force",
+			state:      busyState,
+			force:      true,
+			wantCharts: []string{},
+		},
+		{
+			name:       "failed environment",
+			state:      failedState,
+			wantErr:    true,
+			wantNS:     true,
+			wantCharts: []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+		{
+			name:         "release fails to delete",
+			state:        freeState,
+			deleteErrors: map[string]error{testEnv + "-mariadb": errors.New("timed out")},
+			wantErr:      true,
+			wantNS:       true,
+			wantCharts:   []string{"mariadb=0.5.4"},
+		},
+		{
+			name:         "protected environment",
+			state:        freeState,
+			annotations:  map[string]string{deletionProtectionAnnotation: "true"},
+			wantErr:      true,
+			wantExitCode: ExitCodeLocked,
+			wantNS:       true,
+			wantCharts:   []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+		{
+			name:             "protected environment with ignore protection",
+			state:            freeState,
+			annotations:      map[string]string{deletionProtectionAnnotation: "true"},
+			ignoreProtection: true,
+			wantCharts:       []string{},
+		},
+		{
+			name:         "deletion confirmed",
+			state:        freeState,
+			confirmation: "yes\n",
+			wantCharts:   []string{},
+		},
+		{
+			name:         "deletion not confirmed",
+			state:        freeState,
+			confirmation: "\n",
+			wantErr:      true,
+			wantExitCode: ExitCodeError,
+			wantNS:       true,
+			wantCharts:   []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+		{
+			name:          "orphaned cluster-scoped objects",
+			state:         freeState,
+			deleteOrphans: true,
+			wantCharts:    []string{},
+			wantPVs:       []string{"other-data"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientSet(orphans...)
+			helmClient := fake.NewHelmClient(clientset)
+			e := newTestEnvCmd(clientset, helmClient)
+			e.chartsFile = "testdata/charts.yaml"
+			e.parallel = 0
+			if err := e.deployEnv(); err != nil {
+				t.Fatalf("deployEnv() error = %v", err)
+			}
+			annotations := map[string]string{stateAnnotation: tt.state}
+			for k, v := range tt.annotations {
+				annotations[k] = v
+			}
+			if err := utils.UpdateNamespace(clientset, testEnv, annotations, map[string]string{}, false); err != nil {
+				t.Fatalf("failed updating namespace: %v", err)
+			}
+			for k, v := range tt.deleteErrors {
+				helmClient.DeleteErrors[k] = v
+			}
+
+			e.force = tt.force
+			e.ignoreProtection = tt.ignoreProtection
+			e.deleteOrphans = tt.deleteOrphans
+			if tt.confirmation != "" {
+				e.in = strings.NewReader(tt.confirmation)
+			}
+			err := e.deleteEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deleteEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantExitCode != 0 && ExitCode(err) != tt.wantExitCode {
+				t.Errorf("deleteEnv() exit code = %v, want %v", ExitCode(err), tt.wantExitCode)
+			}
+			nsExists, err := utils.NamespaceExists(clientset, testEnv)
+			if err != nil {
+				t.Fatalf("failed checking namespace: %v", err)
+			}
+			if nsExists != tt.wantNS {
+				t.Errorf("deleteEnv() namespace exists = %v, want %v", nsExists, tt.wantNS)
+			}
+			if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, tt.wantCharts) {
+				t.Errorf("deleteEnv() charts = %v, want %v", got, tt.wantCharts)
+			}
+			if tt.wantPVs != nil {
+				pvs, err := clientset.CoreV1().PersistentVolumes().List(metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("failed listing persistent volumes: %v", err)
+				}
+				got := []string{}
+				for _, pv := range pvs.Items {
+					got = append(got, pv.Name)
+				}
+				if !reflect.DeepEqual(got, tt.wantPVs) {
+					t.Errorf("deleteEnv() persistent volumes = %v, want %v", got, tt.wantPVs)
+				}
+				if _, err := clientset.RbacV1().ClusterRoles().Get("kaa", metav1.GetOptions{}); err == nil {
+					t.Error("deleteEnv() did not delete orphaned cluster role")
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteEnv_ReverseDependencyOrder(t *testing.T) {
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.chartsFile = "testdata/charts.yaml"
+	e.parallel = 0
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+	// Dependencies are deployed before dependents
+	if got := helmClient.Upgraded[len(helmClient.Upgraded)-1]; got != testEnv+"-kaa" {
+		t.Errorf("deployEnv() upgraded %v, want %s last", helmClient.Upgraded, testEnv+"-kaa")
+	}
+
+	if err := e.deleteEnv(); err != nil {
+		t.Fatalf("deleteEnv() error = %v", err)
+	}
+	if got := helmClient.Deleted[0]; got != testEnv+"-kaa" {
+		t.Errorf("deleteEnv() deleted %v, want %s first", helmClient.Deleted, testEnv+"-kaa")
+	}
+}
+
+func TestDeleteEnv_ReleaseFailsToDelete(t *testing.T) {
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.chartsFile = "testdata/charts.yaml"
+	e.parallel = 1
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+
+	// cassandra is deleted before mariadb, which does not depend on it and is deleted anyway
+	helmClient.DeleteErrors[testEnv+"-cassandra"] = errors.New("timed out")
+	err := e.deleteEnv()
+	var releaseErr *utils.ReleaseError
+	if !errors.As(err, &releaseErr) || releaseErr.ReleaseName != testEnv+"-cassandra" {
+		t.Fatalf("deleteEnv() error = %v, want a release error of %s", err, testEnv+"-cassandra")
+	}
+	if want := []string{testEnv + "-kaa", testEnv + "-mariadb"}; !reflect.DeepEqual(helmClient.Deleted, want) {
+		t.Errorf("deleteEnv() deleted %v, want %v", helmClient.Deleted, want)
+	}
+	if got, want := installedCharts(t, clientset, testEnv), []string{"cassandra=0.4.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleteEnv() charts = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteEnv_WaitTimeout(t *testing.T) {
+	clientset := fake.NewClientSet(fake.Namespace(testEnv, map[string]string{stateAnnotation: freeState}))
+	// The namespace never finishes terminating
+	clientset.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	e := newTestEnvCmd(clientset, fake.NewHelmClient(clientset))
+	e.waitTimeout = 1
+
+	err := e.deleteEnv()
+	if err == nil || !strings.Contains(err.Error(), "timed out waiting for namespace") {
+		t.Errorf("deleteEnv() error = %v, want timed out", err)
+	}
+}
+
+func TestLockEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        string
+		unlock       bool
+		lockTimeout  int
+		wantExitCode int
+		wantState    string
+	}{
+		{
+			name:      "lock new environment",
+			state:     "",
+			wantState: busyState,
+		},
+		{
+			name:      "lock free environment",
+			state:     freeState,
+			wantState: busyState,
+		},
+		{
+			name:         "lock failed environment",
+			state:        failedState,
+			wantExitCode: ExitCodeLocked,
+			wantState:    failedState,
+		},
+		{
+			name:         "lock busy environment with lock timeout",
+			state:        busyState,
+			lockTimeout:  1,
+			wantExitCode: ExitCodeLocked,
+			wantState:    busyState,
+		},
+		{
+			name:      "unlock busy environment",
+			state:     busyState,
+			unlock:    true,
+			wantState: freeState,
+		},
+		{
+			name:         "unlock failed environment",
+			state:        failedState,
+			unlock:       true,
+			wantExitCode: ExitCodeLocked,
+			wantState:    failedState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tt.state != "" {
+				annotations[stateAnnotation] = tt.state
+			}
+			clientset := fake.NewClientSet(fake.Namespace(testEnv, annotations))
+			e := newTestEnvCmd(clientset, fake.NewHelmClient(clientset))
+			e.lockTimeout = tt.lockTimeout
+
+			var err error
+			if tt.unlock {
+				err = e.unlockEnv()
+			} else {
+				err = e.lockEnv()
+			}
+			if got := ExitCode(err); got != tt.wantExitCode {
+				t.Fatalf("error = %v, exit code = %v, want %v", err, got, tt.wantExitCode)
+			}
+			if got := envState(t, clientset, testEnv); got != tt.wantState {
+				t.Errorf("state = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		objects      []runtime.Object
+		wantErr      bool
+		wantExitCode int
+	}{
+		{
+			name: "healthy environment",
+			objects: []runtime.Object{
+				fake.Namespace(testEnv, nil),
+				fake.Pod(testEnv, "cassandra-0", true),
+				fake.Pod(testEnv, "mariadb-0", true),
+			},
+			wantErr: false,
+		},
+		{
+			name: "unhealthy pod",
+			objects: []runtime.Object{
+				fake.Namespace(testEnv, nil),
+				fake.Pod(testEnv, "cassandra-0", true),
+				fake.Pod(testEnv, "mariadb-0", false),
+			},
+			wantErr:      true,
+			wantExitCode: ExitCodeValidationFailed,
+		},
+		{
+			name:         "environment not found",
+			objects:      []runtime.Object{},
+			wantErr:      true,
+			wantExitCode: ExitCodeError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientSet(tt.objects...)
+			e := newTestEnvCmd(clientset, fake.NewHelmClient(clientset))
+
+			err := e.validateEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := ExitCode(err); got != tt.wantExitCode {
+				t.Errorf("validateEnv() exit code = %v, want %v", got, tt.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestDiffEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		right        []string
+		exitCode     bool
+		wantExitCode int
+	}{
+		{
+			name:  "no differences",
+			right: []string{"kaa=0.1.7"},
+		},
+		{
+			name:     "no differences with exit code",
+			right:    []string{"kaa=0.1.7"},
+			exitCode: true,
+		},
+		{
+			name:  "differences",
+			right: []string{"kaa=0.2.0"},
+		},
+		{
+			name:         "differences with exit code",
+			right:        []string{"kaa=0.2.0"},
+			exitCode:     true,
+			wantExitCode: ExitCodeDriftDetected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientSet()
+			helmClient := fake.NewHelmClient(clientset)
+			for name, override := range map[string][]string{"left": {"kaa=0.1.7"}, "right": tt.right} {
+				e := newTestEnvCmd(clientset, helmClient)
+				e.name = name
+				e.override = override
+				if err := e.deployEnv(); err != nil {
+					t.Fatalf("deployEnv() error = %v", err)
+				}
+			}
+
+			d := &diffEnvCmd{
+				nameLeft:  "left",
+				nameRight: "right",
+				exitCode:  tt.exitCode,
+				clients:   fake.NewClients(clientset, helmClient),
+				out:       ioutil.Discard,
+			}
+			err := d.diffEnv()
+			if got := ExitCode(err); got != tt.wantExitCode {
+				t.Errorf("diffEnv() error = %v, exit code = %v, want %v", err, got, tt.wantExitCode)
+			}
+		})
+	}
+}
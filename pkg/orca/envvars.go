@@ -0,0 +1,208 @@
+package orca
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// secondsAnnotation is the annotation of an int flag which holds a number of seconds
+const secondsAnnotation = "orca_seconds"
+
+// bindSecondsEnvVar sets the environment variable backing an int flag which holds a number of seconds.
+// The environment variable may also be set to a duration (e.g. 5m)
+func bindSecondsEnvVar(f *pflag.FlagSet, name, envVar string) {
+	bindEnvVar(f, name, envVar)
+	f.SetAnnotation(name, secondsAnnotation, []string{"true"})
+}
+
+type envVarsCmd struct {
+	out io.Writer
+}
+
+// envVarFlag is a flag of a command which is backed by an environment variable
+type envVarFlag struct {
+	command string
+	flag    *pflag.Flag
+}
+
+// NewEnvVarsCmd represents the env-vars command
+func NewEnvVarsCmd(out io.Writer) *cobra.Command {
+	e := &envVarsCmd{out: out}
+
+	cmd := &cobra.Command{
+		Use:   "env-vars",
+		Short: "Print all environment variables orca recognizes, their current values and the flags they map to",
+		Long:  ``,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := e.printEnvVars(cmd.Root()); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	return cmd
+}
+
+// printEnvVars prints a table of all environment variables backing flags of the commands of root, and a table of
+// the environment variables orca reads directly. It returns an error if any of them has an invalid value
+func (e *envVarsCmd) printEnvVars(root *cobra.Command) error {
+	envVars := map[string][]envVarFlag{}
+	var collect func(cmd *cobra.Command)
+	collect = func(cmd *cobra.Command) {
+		for _, c := range cmd.Commands() {
+			collect(c)
+		}
+		if cmd.Run == nil && cmd.RunE == nil {
+			return
+		}
+		command := strings.TrimPrefix(cmd.CommandPath(), root.Name()+" ")
+		cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			// Global flags are collected once
+			if root.PersistentFlags().Lookup(flag.Name) == flag {
+				return
+			}
+			if envVar := flagEnvVar(flag); envVar != "" {
+				envVars[envVar] = append(envVars[envVar], envVarFlag{command: command, flag: flag})
+			}
+		})
+	}
+	collect(root)
+	root.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if envVar := flagEnvVar(flag); envVar != "" {
+			envVars[envVar] = append(envVars[envVar], envVarFlag{command: "all commands", flag: flag})
+		}
+	})
+
+	names := make([]string, 0, len(envVars))
+	for name := range envVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tbl := uitable.New()
+	tbl.MaxColWidth = 60
+	tbl.Wrap = true
+	tbl.AddRow("VARIABLE", "VALUE", "FLAG", "COMMANDS")
+	var invalid []string
+	for _, name := range names {
+		value := os.Getenv(name)
+		// Group the commands by flag, as a variable usually maps to the same flag in all commands
+		commands := map[string][]string{}
+		var flags []*pflag.Flag
+		for _, f := range envVars[name] {
+			if _, ok := commands[f.flag.Name]; !ok {
+				flags = append(flags, f.flag)
+			}
+			commands[f.flag.Name] = append(commands[f.flag.Name], f.command)
+		}
+		for _, flag := range flags {
+			sort.Strings(commands[flag.Name])
+			tbl.AddRow(name, value, "--"+flag.Name, strings.Join(commands[flag.Name], ", "))
+			if value == "" {
+				continue
+			}
+			if _, err := parseEnvVar(flag, value); err != nil {
+				invalid = append(invalid, fmt.Sprintf("$%s=\"%s\" (--%s): %v", name, value, flag.Name, err))
+			}
+		}
+	}
+	fmt.Fprintln(e.out, tbl)
+
+	// Variables which are read directly are listed separately, as they do not map to flags
+	tbl = uitable.New()
+	tbl.MaxColWidth = 100
+	tbl.Wrap = true
+	tbl.AddRow("VARIABLE", "VALUE", "USAGE")
+	for _, v := range utils.EnvVars {
+		value := os.Getenv(v.Name)
+		tbl.AddRow(v.Name, value, v.Usage)
+		if value == "" || v.Parse == nil {
+			continue
+		}
+		if err := v.Parse(value); err != nil {
+			invalid = append(invalid, fmt.Sprintf("$%s=\"%s\": %v", v.Name, value, err))
+		}
+	}
+	fmt.Fprintln(e.out)
+	fmt.Fprintln(e.out, tbl)
+
+	if len(invalid) != 0 {
+		return fmt.Errorf("%w:\n%s", utils.ErrInvalidEnvVar, strings.Join(invalid, "\n"))
+	}
+	return nil
+}
+
+// applyEnvVars sets the flags of a command which were not set by a flag to the values of
+// the environment variables backing them, and returns an error if a value is invalid for the flag
+func applyEnvVars(cmd *cobra.Command) error {
+	var errs []string
+	f := cmd.Flags()
+	f.VisitAll(func(flag *pflag.Flag) {
+		envVar := flagEnvVar(flag)
+		if envVar == "" || flag.Changed {
+			return
+		}
+		value := os.Getenv(envVar)
+		if value == "" {
+			return
+		}
+		values, err := parseEnvVar(flag, value)
+		if err == nil {
+			for _, v := range values {
+				if err = f.Set(flag.Name, v); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("$%s=\"%s\": %v", envVar, value, err))
+		}
+	})
+	if len(errs) != 0 {
+		return fmt.Errorf("%w: %s", utils.ErrInvalidEnvVar, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// parseEnvVar parses the value of an environment variable by the type of the flag it backs,
+// and returns the values to set the flag to
+func parseEnvVar(flag *pflag.Flag, value string) ([]string, error) {
+	switch flag.Value.Type() {
+	case "bool":
+		b, err := utils.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{strconv.FormatBool(b)}, nil
+	case "int":
+		parse := utils.ParseInt
+		if isSecondsFlag(flag) {
+			parse = utils.ParseSeconds
+		}
+		i, err := parse(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{strconv.Itoa(i)}, nil
+	case "stringSlice":
+		return utils.ParseList(value)
+	default:
+		return []string{value}, nil
+	}
+}
+
+// isSecondsFlag reports whether a flag holds a number of seconds (see bindSecondsEnvVar)
+func isSecondsFlag(flag *pflag.Flag) bool {
+	_, ok := flag.Annotations[secondsAnnotation]
+	return ok
+}
@@ -0,0 +1,110 @@
+package orca
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/nuvo/orca/pkg/utils"
+)
+
+// Exit codes orca exits with, per failure category
+const (
+	// ExitCodeError is the exit code of a failure which has no specific exit code
+	ExitCodeError = 1
+	// ExitCodeInvalidInput is the exit code when the input (charts file, flags) is invalid
+	ExitCodeInvalidInput = 2
+	// ExitCodeLocked is the exit code when an environment is locked (busy or in a failed, unknown or delete state)
+	// or protected from deletion
+	ExitCodeLocked = 3
+	// ExitCodeValidationFailed is the exit code when an environment validation failed
+	ExitCodeValidationFailed = 4
+	// ExitCodeHelmFailed is the exit code when a Helm operation (e.g. upgrade) failed
+	ExitCodeHelmFailed = 5
+	// ExitCodeClusterUnreachable is the exit code when a Kubernetes cluster can not be reached
+	ExitCodeClusterUnreachable = 6
+	// ExitCodeDriftDetected is the exit code when differences between environments are found
+	ExitCodeDriftDetected = 7
+	// ExitCodeRequestFailed is the exit code when an HTTP request (REST API, Artifactory) failed
+	ExitCodeRequestFailed = 8
+	// ExitCodeChartVersionExists is the exit code when a pushed chart version already exists in the chart repository
+	ExitCodeChartVersionExists = 9
+	// ExitCodeLintFailed is the exit code when a chart violates policies or fails helm lint
+	ExitCodeLintFailed = 10
+	// ExitCodeInterrupted is the exit code when orca was interrupted (SIGINT, SIGTERM)
+	ExitCodeInterrupted = 130
+)
+
+var (
+	// ErrEnvironmentLocked is returned when an environment can not be locked
+	ErrEnvironmentLocked = errors.New("environment locked")
+	// ErrEnvironmentProtected is returned when a protected environment is deleted without ignoring the protection
+	ErrEnvironmentProtected = errors.New("environment protected")
+	// ErrValidationFailed is returned when an environment validation failed
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrDriftDetected is returned when differences between environments are found
+	ErrDriftDetected = errors.New("drift detected")
+	// ErrInterrupted is returned when an operation was interrupted
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// ExitCode returns the exit code orca should exit with for an error
+func ExitCode(err error) int {
+	var helmErr *utils.HelmError
+	var releaseErr *utils.ReleaseError
+	var httpErr *utils.HTTPError
+	var urlErr *url.Error
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrInterrupted),
+		errors.Is(err, context.Canceled):
+		return ExitCodeInterrupted
+	case errors.Is(err, utils.ErrInvalidChartsFile),
+		errors.Is(err, utils.ErrInvalidChart),
+		errors.Is(err, utils.ErrCircularDependency),
+		errors.Is(err, utils.ErrInvalidKeyValue),
+		errors.Is(err, utils.ErrInvalidConfig),
+		errors.Is(err, utils.ErrInvalidEnvVar),
+		errors.Is(err, utils.ErrInvalidEnvTemplate),
+		errors.Is(err, utils.ErrInvalidBuildTypeRules),
+		errors.Is(err, utils.ErrInvalidServicesFile):
+		return ExitCodeInvalidInput
+	case errors.Is(err, ErrEnvironmentLocked),
+		errors.Is(err, ErrEnvironmentProtected):
+		return ExitCodeLocked
+	case errors.Is(err, ErrValidationFailed):
+		return ExitCodeValidationFailed
+	case errors.Is(err, ErrDriftDetected):
+		return ExitCodeDriftDetected
+	case errors.Is(err, utils.ErrChartVersionExists):
+		return ExitCodeChartVersionExists
+	case errors.Is(err, utils.ErrLintFailed):
+		return ExitCodeLintFailed
+	case errors.Is(err, utils.ErrClusterUnreachable):
+		return ExitCodeClusterUnreachable
+	case errors.Is(err, utils.ErrRequestFailed),
+		errors.As(err, &httpErr):
+		return ExitCodeRequestFailed
+	case errors.As(err, &helmErr),
+		errors.As(err, &releaseErr):
+		return ExitCodeHelmFailed
+	// Requests which are not REST API requests are Kubernetes API requests
+	case errors.As(err, &urlErr),
+		errors.As(err, &netErr):
+		return ExitCodeClusterUnreachable
+	default:
+		return ExitCodeError
+	}
+}
+
+// fatal logs an error and exits with its exit code
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(ExitCode(err))
+}
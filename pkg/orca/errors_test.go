@@ -0,0 +1,122 @@
+package orca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "no error",
+			err:  nil,
+			want: 0,
+		},
+		{
+			name: "generic error",
+			err:  errors.New("something went wrong"),
+			want: ExitCodeError,
+		},
+		{
+			name: "invalid charts file",
+			err:  fmt.Errorf("%w: charts.yaml: no such file or directory", utils.ErrInvalidChartsFile),
+			want: ExitCodeInvalidInput,
+		},
+		{
+			name: "invalid chart",
+			err:  fmt.Errorf("%w: chart \"a\" found in both a and b", utils.ErrInvalidChart),
+			want: ExitCodeInvalidInput,
+		},
+		{
+			name: "circular dependency",
+			err:  utils.ErrCircularDependency,
+			want: ExitCodeInvalidInput,
+		},
+		{
+			name: "invalid key-value pair",
+			err:  fmt.Errorf("%w: \"a\" does not contain \"=\"", utils.ErrInvalidKeyValue),
+			want: ExitCodeInvalidInput,
+		},
+		{
+			name: "environment locked",
+			err:  fmt.Errorf("%w: environment \"test\" state is failed", ErrEnvironmentLocked),
+			want: ExitCodeLocked,
+		},
+		{
+			name: "environment protected",
+			err:  fmt.Errorf("%w: environment \"test\" is protected from deletion", ErrEnvironmentProtected),
+			want: ExitCodeLocked,
+		},
+		{
+			name: "validation failed",
+			err:  fmt.Errorf("environment \"test\" %w", ErrValidationFailed),
+			want: ExitCodeValidationFailed,
+		},
+		{
+			name: "helm upgrade failed",
+			err:  &utils.ReleaseError{ReleaseName: "test-kaa", Err: &utils.HelmError{Op: "upgrade", Target: "test-kaa", Err: errors.New("timed out")}},
+			want: ExitCodeHelmFailed,
+		},
+		{
+			name: "multiple releases failed",
+			err:  utils.ReleaseErrors{&utils.ReleaseError{ReleaseName: "test-kaa", Err: errors.New("timed out")}, errors.New("other")},
+			want: ExitCodeHelmFailed,
+		},
+		{
+			name: "cluster unreachable",
+			err:  fmt.Errorf("%w: context \"prod\" does not exist", utils.ErrClusterUnreachable),
+			want: ExitCodeClusterUnreachable,
+		},
+		{
+			name: "kubernetes api unreachable",
+			err:  &url.Error{Op: "Get", URL: "https://10.0.0.1/api/v1/namespaces/test", Err: errors.New("connection refused")},
+			want: ExitCodeClusterUnreachable,
+		},
+		{
+			name: "drift detected",
+			err:  fmt.Errorf("%w between environments \"left\" and \"right\"", ErrDriftDetected),
+			want: ExitCodeDriftDetected,
+		},
+		{
+			name: "unexpected status code",
+			err:  &utils.HTTPError{Method: "GET", URL: "https://example.com", StatusCode: 500},
+			want: ExitCodeRequestFailed,
+		},
+		{
+			name: "request not sent",
+			err:  fmt.Errorf("%w: connection refused", utils.ErrRequestFailed),
+			want: ExitCodeRequestFailed,
+		},
+		{
+			name: "chart version exists",
+			err:  fmt.Errorf("%w: chart api version 0.1.0 is in myrepo=https://charts.example.com", utils.ErrChartVersionExists),
+			want: ExitCodeChartVersionExists,
+		},
+		{
+			name: "lint failed",
+			err:  fmt.Errorf("chart api: %w: chart api version 0.1.0 has 1 errors", utils.ErrLintFailed),
+			want: ExitCodeLintFailed,
+		},
+		{
+			name: "interrupted",
+			err:  fmt.Errorf("operation on environment \"test\" %w, environment state is not guaranteed: %v", ErrInterrupted, context.Canceled),
+			want: ExitCodeInterrupted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
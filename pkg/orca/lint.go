@@ -0,0 +1,241 @@
+package orca
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// policyOptions are options of commands which check charts against policies
+type policyOptions struct {
+	requiredLabels []string
+	skipPolicies   []string
+	// optional is set for commands which only check the policies of rendered objects if enabled
+	optional bool
+	enabled  bool
+}
+
+// addPushFlags adds the flags of the options to a command which lints charts before pushing them.
+// Only the semver-version policy is checked, unless the policies of rendered objects are enabled
+func (p *policyOptions) addPushFlags(f *pflag.FlagSet) {
+	p.optional = true
+	f.BoolVar(&p.enabled, "lint-policies", false, "with lint, also check the rendered templates against the labels, image-tag, resources and probes policies (see lint chart). Overrides $ORCA_LINT_POLICIES")
+	bindEnvVar(f, "lint-policies", "ORCA_LINT_POLICIES")
+	p.addFlags(f)
+}
+
+// addFlags adds the flags of the options to a command
+func (p *policyOptions) addFlags(f *pflag.FlagSet) {
+	f.StringSliceVar(&p.requiredLabels, "required-labels", []string{"app", "release"}, "labels every rendered object should have (can specify multiple). Overrides $ORCA_REQUIRED_LABELS")
+	bindEnvVar(f, "required-labels", "ORCA_REQUIRED_LABELS")
+	f.StringSliceVar(&p.skipPolicies, "skip-policies", []string{}, fmt.Sprintf("policies not to check (can specify multiple): %s. Overrides $ORCA_SKIP_POLICIES", strings.Join(utils.ChartPolicies, ", ")))
+	bindEnvVar(f, "skip-policies", "ORCA_SKIP_POLICIES")
+}
+
+// validate verifies the skipped policies exist
+func (p *policyOptions) validate() error {
+	for _, policy := range p.skipPolicies {
+		if !utils.Contains(utils.ChartPolicies, policy) {
+			return fmt.Errorf("unknown policy \"%s\", policies are: %s", policy, strings.Join(utils.ChartPolicies, ", "))
+		}
+	}
+	return nil
+}
+
+func (p *policyOptions) policies() utils.PolicyOptions {
+	skip := p.skipPolicies
+	if p.optional && !p.enabled {
+		skip = append([]string{}, p.skipPolicies...)
+		for _, policy := range utils.ChartPolicies {
+			if policy != utils.PolicySemverVersion {
+				skip = utils.AddIfNotContained(skip, policy)
+			}
+		}
+	}
+	return utils.PolicyOptions{
+		RequiredLabels: p.requiredLabels,
+		Skip:           skip,
+	}
+}
+
+type lintChartCmd struct {
+	path        string
+	valuesFiles []string
+	set         []string
+	policies    policyOptions
+	output      string
+
+	out io.Writer
+}
+
+// NewLintChartCmd represents the lint chart command
+func NewLintChartCmd(out io.Writer) *cobra.Command {
+	l := &lintChartCmd{out: out}
+
+	cmd := &cobra.Command{
+		Use:   "chart",
+		Short: "Lint a Helm chart and check its rendered templates against policies",
+		Long: `Lint a Helm chart (helm lint) and render its templates with values files to check them against policies:
+semver-version - the version in Chart.yaml is a valid semantic version
+labels         - rendered objects have the required labels
+image-tag      - container images have a tag which is not latest (or a digest)
+resources      - containers have resource requests and limits
+probes         - containers of long running workloads have readiness and liveness probes`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if l.path == "" {
+				return errors.New("path can not be empty")
+			}
+			switch l.output {
+			case "", "json", "junit":
+			default:
+				return errors.New("output can be one of: json, junit")
+			}
+			return l.policies.validate()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := l.lintChart(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVar(&l.path, "path", "", "path to chart. Overrides $ORCA_PATH")
+	bindEnvVar(f, "path", "ORCA_PATH")
+	f.StringSliceVarP(&l.valuesFiles, "values", "f", []string{}, "values file to render the templates with (can specify multiple)")
+	f.StringSliceVarP(&l.set, "set", "s", []string{}, "set additional parameters")
+	l.policies.addFlags(f)
+	f.StringVarP(&l.output, "output", "o", "", "output format (json, junit). Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
+
+	return cmd
+}
+
+// lintChart lints a chart, prints the result and returns an error if the chart failed
+func (l *lintChartCmd) lintChart() error {
+	result, err := utils.LintChart(utils.LintChartOptions{
+		Path:        l.path,
+		ValuesFiles: l.valuesFiles,
+		Set:         l.set,
+		Policies:    l.policies.policies(),
+		HelmLint:    true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := printLintResult(l.out, result, l.output); err != nil {
+		return err
+	}
+	// The findings were printed, so the error only summarizes them
+	if !result.Passed() {
+		return fmt.Errorf("%w: chart %s version %s has %d errors", utils.ErrLintFailed, result.Chart, result.Version, result.Errors())
+	}
+	return nil
+}
+
+// printLintResult prints the result of a chart lint as a table of findings, json or a JUnit report
+func printLintResult(out io.Writer, result *utils.ChartLintResult, output string) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "junit":
+		data, err := xml.MarshalIndent(junitReport(result), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, xml.Header+string(data))
+	default:
+		if len(result.Findings) != 0 {
+			table := uitable.New()
+			table.MaxColWidth = 100
+			table.Wrap = true
+			table.AddRow("POLICY", "SEVERITY", "OBJECT", "MESSAGE")
+			for _, f := range result.Findings {
+				object := f.Object
+				if object == "" {
+					object = f.Template
+				}
+				table.AddRow(f.Policy, f.Severity, object, f.Message)
+			}
+			fmt.Fprintln(out, table)
+		}
+		status := "passed"
+		if !result.Passed() {
+			status = fmt.Sprintf("failed (%d errors)", result.Errors())
+		}
+		fmt.Fprintf(out, "chart %s version %s %s\n", result.Chart, result.Version, status)
+	}
+	return nil
+}
+
+// junitTestSuites is a JUnit report, in which a chart is a test suite and each check is a test case
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport returns a JUnit report of the result of a chart lint. Findings with an error severity fail their check,
+// other findings are reported as its output
+func junitReport(result *utils.ChartLintResult) junitTestSuites {
+	suite := junitTestSuite{Name: result.Chart + "-" + result.Version, Tests: len(result.Policies)}
+	for _, policy := range result.Policies {
+		tc := junitTestCase{Name: policy, ClassName: result.Chart}
+		var errs, others []string
+		for _, f := range result.Findings {
+			if f.Policy != policy {
+				continue
+			}
+			msg := f.Message
+			if f.Object != "" {
+				msg = f.Object + ": " + msg
+			} else if f.Template != "" {
+				msg = f.Template + ": " + msg
+			}
+			if f.Severity == utils.LintSeverityError {
+				errs = append(errs, msg)
+			} else {
+				others = append(others, "["+f.Severity+"] "+msg)
+			}
+		}
+		if len(errs) != 0 {
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: fmt.Sprintf("%s failed with %d errors", policy, len(errs)), Text: strings.Join(errs, "\n")}
+		}
+		tc.SystemOut = strings.Join(others, "\n")
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
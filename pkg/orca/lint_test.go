@@ -0,0 +1,103 @@
+package orca
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+)
+
+func TestLintChart(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      []string
+		output   string
+		wantErr  error
+		wantOuts []string
+	}{
+		{
+			name:     "passed",
+			wantOuts: []string{"chart api version 0.1.0 passed\n"},
+		},
+		{
+			name:     "latest image tag",
+			set:      []string{"image.tag=latest"},
+			wantErr:  utils.ErrLintFailed,
+			wantOuts: []string{"image-tag", "failed (1 errors)"},
+		},
+		{
+			name:     "json",
+			set:      []string{"image.tag=latest"},
+			output:   "json",
+			wantErr:  utils.ErrLintFailed,
+			wantOuts: []string{`"policy": "image-tag"`, `"severity": "error"`},
+		},
+		{
+			name:     "junit",
+			set:      []string{"image.tag=latest"},
+			output:   "junit",
+			wantErr:  utils.ErrLintFailed,
+			wantOuts: []string{`<testsuite name="api-0.1.0" tests="7" failures="1">`, `<failure message="image-tag failed with 1 errors">`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			l := &lintChartCmd{
+				path:     "../utils/testdata/charts/api",
+				set:      tt.set,
+				policies: policyOptions{requiredLabels: []string{"app"}},
+				output:   tt.output,
+				out:      &out,
+			}
+			err := l.lintChart()
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("lintChart() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantOuts {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("lintChart() output = %q, want it to contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyOptions_Policies(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  policyOptions
+		wantSkip []string
+	}{
+		{
+			name:     "lint chart",
+			options:  policyOptions{skipPolicies: []string{utils.PolicyProbes}},
+			wantSkip: []string{utils.PolicyProbes},
+		},
+		{
+			name:     "push without policies",
+			options:  policyOptions{skipPolicies: []string{utils.PolicyProbes}, optional: true},
+			wantSkip: []string{utils.PolicyProbes, utils.PolicyRequiredLabels, utils.PolicyImageTag, utils.PolicyResources},
+		},
+		{
+			name:     "push without policies, semver skipped",
+			options:  policyOptions{skipPolicies: []string{utils.PolicySemverVersion}, optional: true},
+			wantSkip: []string{utils.PolicySemverVersion, utils.PolicyRequiredLabels, utils.PolicyImageTag, utils.PolicyResources, utils.PolicyProbes},
+		},
+		{
+			name:     "push with policies",
+			options:  policyOptions{skipPolicies: []string{utils.PolicyProbes}, optional: true, enabled: true},
+			wantSkip: []string{utils.PolicyProbes},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.policies().Skip; !reflect.DeepEqual(got, tt.wantSkip) {
+				t.Errorf("policies() skip = %v, want %v", got, tt.wantSkip)
+			}
+		})
+	}
+}
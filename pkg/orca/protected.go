@@ -0,0 +1,402 @@
+package orca
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/client-go/kubernetes"
+)
+
+// protectedChart is a chart which is protected in an environment, optionally pinned to a version
+type protectedChart struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// String returns the representation of a protected chart in the protected charts annotation (chart or chart=version)
+func (pc protectedChart) String() string {
+	if pc.Version == "" {
+		return pc.Name
+	}
+	return pc.Name + "=" + pc.Version
+}
+
+// parseProtectedCharts parses protected charts from entries of the form chart or chart=version.
+// Entries may be comma separated, empty entries are skipped
+func parseProtectedCharts(entries []string) ([]protectedChart, error) {
+	protectedCharts := []protectedChart{}
+	for _, entry := range strings.Split(strings.Join(entries, ","), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pc := protectedChart{Name: entry}
+		if strings.Contains(entry, "=") {
+			name, version, _ := utils.SplitInTwo(entry, "=")
+			pc = protectedChart{Name: strings.TrimSpace(name), Version: strings.TrimSpace(version)}
+			if pc.Name == "" || pc.Version == "" {
+				return nil, fmt.Errorf("%w: protected chart \"%s\" should be of the form chart or chart=version", utils.ErrInvalidKeyValue, entry)
+			}
+		}
+		protectedCharts = mergeProtectedCharts(protectedCharts, []protectedChart{pc})
+	}
+	return protectedCharts, nil
+}
+
+// formatProtectedCharts returns the value of the protected charts annotation
+func formatProtectedCharts(protectedCharts []protectedChart) string {
+	entries := []string{}
+	for _, pc := range protectedCharts {
+		entries = append(entries, pc.String())
+	}
+	return strings.Join(entries, ",")
+}
+
+// mergeProtectedCharts adds protected charts to a list of protected charts.
+// A chart which is already protected is pinned to the added version, if one is specified
+func mergeProtectedCharts(protectedCharts, added []protectedChart) []protectedChart {
+	merged := append([]protectedChart{}, protectedCharts...)
+	for _, a := range added {
+		i := protectedChartIndex(merged, a.Name)
+		if i == -1 {
+			merged = append(merged, a)
+			continue
+		}
+		if a.Version != "" {
+			merged[i].Version = a.Version
+		}
+	}
+	return merged
+}
+
+// protectedChartIndex returns the index of a chart in a list of protected charts, or -1 if it is not protected
+func protectedChartIndex(protectedCharts []protectedChart, name string) int {
+	for i, pc := range protectedCharts {
+		if pc.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// protectedChartNames returns the names of protected charts
+func protectedChartNames(protectedCharts []protectedChart) []string {
+	names := []string{}
+	for _, pc := range protectedCharts {
+		names = append(names, pc.Name)
+	}
+	return names
+}
+
+// getProtectedCharts returns the charts which are protected in an environment
+func getProtectedCharts(clientset kubernetes.Interface, name string) ([]protectedChart, error) {
+	ns, err := utils.GetNamespace(clientset, name)
+	if err != nil {
+		return nil, err
+	}
+	return parseProtectedCharts([]string{ns.Annotations[protectedAnnotation]})
+}
+
+// setProtectedCharts sets the charts which are protected in an environment
+func setProtectedCharts(clientset kubernetes.Interface, name string, protectedCharts []protectedChart, print bool) error {
+	annotations := map[string]string{protectedAnnotation: formatProtectedCharts(protectedCharts)}
+	return utils.UpdateNamespace(clientset, name, annotations, map[string]string{}, print)
+}
+
+// pinProtectedReleases sets the versions of desired releases of protected charts.
+// A chart pinned to a version is deployed at that version. A chart which is protected without a version keeps its
+// installed version, or is deployed at its desired version if it is not installed yet (e.g. on the first deploy).
+// Charts in protectedByDeploy are protected by the current deployment, which may override them: they are deployed at their desired (or pinned) version
+func pinProtectedReleases(desiredReleases, installedReleases []utils.ReleaseSpec, protectedCharts, protectedByDeploy []protectedChart) []utils.ReleaseSpec {
+	var outReleases []utils.ReleaseSpec
+	for _, r := range desiredReleases {
+		if i := protectedChartIndex(protectedCharts, r.ChartName); i != -1 {
+			pc := protectedCharts[i]
+			switch {
+			case pc.Version != "":
+				r.ChartVersion = pc.Version
+			case protectedChartIndex(protectedByDeploy, r.ChartName) != -1:
+			default:
+				if j := utils.GetChartIndex(installedReleases, r.ChartName); j != -1 {
+					r.ChartVersion = installedReleases[j].ChartVersion
+				}
+			}
+		}
+		outReleases = append(outReleases, r)
+	}
+	return outReleases
+}
+
+type protectedCmd struct {
+	name        string
+	charts      []string
+	kubeContext string
+	lockTimeout int
+	output      string
+
+	clients *utils.Clients
+	out     io.Writer
+}
+
+// NewProtectChartCmd represents the protect chart command
+func NewProtectChartCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	p := &protectedCmd{clients: clients, out: out}
+
+	cmd := &cobra.Command{
+		Use:   "chart",
+		Short: "Protect charts in an environment (Kubernetes namespace) from being overridden and deleted",
+		Long: `Protect charts in an environment (Kubernetes namespace) from being overridden and deleted.
+A chart protected without a version keeps its installed version when the environment is deployed,
+unless the deployment protects it as well (deploy env --protected-chart),
+or is deployed at its desired version if it is not installed yet.
+A chart protected at a version (chart=version) is always deployed at that version.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if p.name == "" {
+				return errors.New("name can not be empty")
+			}
+			if len(p.charts) == 0 {
+				return errors.New("chart can not be empty")
+			}
+			if _, err := parseProtectedCharts(p.charts); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := p.protectCharts(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVarP(&p.name, "name", "n", "", "name of environment (namespace) to protect charts in. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringSliceVar(&p.charts, "chart", []string{}, "chart to protect, optionally pinned to a version (chart or chart=version, can specify multiple). Overrides $ORCA_CHART")
+	bindEnvVar(f, "chart", "ORCA_CHART")
+	f.StringVar(&p.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.IntVar(&p.lockTimeout, "lock-timeout", 0, "time in seconds to wait for a busy (locked) environment before failing. set this flag to 0 to wait indefinitely. Overrides $ORCA_LOCK_TIMEOUT")
+	bindSecondsEnvVar(f, "lock-timeout", "ORCA_LOCK_TIMEOUT")
+
+	return cmd
+}
+
+// NewUnprotectChartCmd represents the unprotect chart command
+func NewUnprotectChartCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	p := &protectedCmd{clients: clients, out: out}
+
+	cmd := &cobra.Command{
+		Use:   "chart",
+		Short: "Remove the protection of charts in an environment (Kubernetes namespace)",
+		Long:  ``,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if p.name == "" {
+				return errors.New("name can not be empty")
+			}
+			if len(p.charts) == 0 {
+				return errors.New("chart can not be empty")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := p.unprotectCharts(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVarP(&p.name, "name", "n", "", "name of environment (namespace) to unprotect charts in. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringSliceVar(&p.charts, "chart", []string{}, "name of chart to unprotect (can specify multiple). Overrides $ORCA_CHART")
+	bindEnvVar(f, "chart", "ORCA_CHART")
+	f.StringVar(&p.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.IntVar(&p.lockTimeout, "lock-timeout", 0, "time in seconds to wait for a busy (locked) environment before failing. set this flag to 0 to wait indefinitely. Overrides $ORCA_LOCK_TIMEOUT")
+	bindSecondsEnvVar(f, "lock-timeout", "ORCA_LOCK_TIMEOUT")
+
+	return cmd
+}
+
+// NewGetProtectedCmd represents the get protected command
+func NewGetProtectedCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	p := &protectedCmd{clients: clients, out: out}
+
+	cmd := &cobra.Command{
+		Use:   "protected",
+		Short: "Get the protected charts of an environment (Kubernetes namespace)",
+		Long:  ``,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if p.name == "" {
+				return errors.New("name can not be empty")
+			}
+			switch p.output {
+			case "", "yaml", "table":
+			default:
+				return errors.New("output can be one of: yaml, table")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := p.getProtected(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVarP(&p.name, "name", "n", "", "name of environment (namespace) to get protected charts of. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringVar(&p.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.StringVarP(&p.output, "output", "o", "", "output format (yaml, table). Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
+
+	return cmd
+}
+
+// protectCharts adds charts to the protected charts of an environment
+func (p *protectedCmd) protectCharts() error {
+	clientset, err := p.clients.KubeClientSet(p.kubeContext)
+	if err != nil {
+		return err
+	}
+	added, err := parseProtectedCharts(p.charts)
+	if err != nil {
+		return err
+	}
+	_, err = p.updateProtectedCharts(clientset, func(protectedCharts []protectedChart) []protectedChart {
+		return mergeProtectedCharts(protectedCharts, added)
+	})
+	if err != nil {
+		return err
+	}
+	for _, pc := range added {
+		log.Printf("protected chart \"%s\" in environment \"%s\"", pc, p.name)
+	}
+	return nil
+}
+
+// unprotectCharts removes charts from the protected charts of an environment
+func (p *protectedCmd) unprotectCharts() error {
+	clientset, err := p.clients.KubeClientSet(p.kubeContext)
+	if err != nil {
+		return err
+	}
+	protectedCharts, err := p.updateProtectedCharts(clientset, func(protectedCharts []protectedChart) []protectedChart {
+		remaining := []protectedChart{}
+		for _, pc := range protectedCharts {
+			if !utils.Contains(p.charts, pc.Name) {
+				remaining = append(remaining, pc)
+			}
+		}
+		return remaining
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range p.charts {
+		if protectedChartIndex(protectedCharts, c) == -1 {
+			log.Printf("chart \"%s\" is not protected in environment \"%s\"", c, p.name)
+		}
+	}
+	for _, pc := range protectedCharts {
+		if utils.Contains(p.charts, pc.Name) {
+			log.Printf("unprotected chart \"%s\" in environment \"%s\"", pc.Name, p.name)
+		}
+	}
+	return nil
+}
+
+// updateProtectedCharts updates the protected charts of an environment by update while the environment is locked,
+// so they are not changed concurrently (e.g. by deploy env). It returns the protected charts before the update
+func (p *protectedCmd) updateProtectedCharts(clientset kubernetes.Interface, update func(protectedCharts []protectedChart) []protectedChart) ([]protectedChart, error) {
+	if err := lockEnvironment(p.clients.Context, clientset, p.name, time.Duration(p.lockTimeout)*time.Second, false); err != nil {
+		return nil, err
+	}
+	protectedCharts, err := getProtectedCharts(clientset, p.name)
+	if err == nil {
+		if updated := update(protectedCharts); formatProtectedCharts(updated) != formatProtectedCharts(protectedCharts) {
+			err = setProtectedCharts(clientset, p.name, updated, false)
+		}
+	}
+	if err != nil {
+		unlockEnvironment(clientset, p.name, false)
+		return nil, err
+	}
+	return protectedCharts, unlockEnvironment(clientset, p.name, false)
+}
+
+// getProtected prints the protected charts of an environment with their pinned and installed versions
+func (p *protectedCmd) getProtected() error {
+	clientset, err := p.clients.KubeClientSet(p.kubeContext)
+	if err != nil {
+		return err
+	}
+	protectedCharts, err := getProtectedCharts(clientset, p.name)
+	if err != nil {
+		return err
+	}
+	installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     p.name,
+		IncludeFailed: false,
+	})
+	if err != nil {
+		return err
+	}
+
+	type chart struct {
+		Name      string `yaml:"name"`
+		Version   string `yaml:"version,omitempty"`
+		Installed string `yaml:"installed,omitempty"`
+	}
+	charts := []chart{}
+	for _, pc := range protectedCharts {
+		c := chart{Name: pc.Name, Version: pc.Version}
+		if i := utils.GetChartIndex(installedReleases, pc.Name); i != -1 {
+			c.Installed = installedReleases[i].ChartVersion
+		}
+		charts = append(charts, c)
+	}
+	if len(charts) == 0 {
+		return nil
+	}
+
+	switch p.output {
+	case "table":
+		valueOrDash := func(s string) string {
+			if s == "" {
+				return "-"
+			}
+			return s
+		}
+		tbl := uitable.New()
+		tbl.MaxColWidth = 60
+		tbl.AddRow("NAME", "PINNED VERSION", "INSTALLED VERSION")
+		for _, c := range charts {
+			tbl.AddRow(c.Name, valueOrDash(c.Version), valueOrDash(c.Installed))
+		}
+		fmt.Fprintln(p.out, tbl.String())
+	default:
+		data, err := yaml.Marshal(map[string][]chart{"charts": charts})
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(p.out, string(data))
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package orca
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+	"github.com/nuvo/orca/pkg/utils/fake"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func protectedAnnotationValue(t *testing.T, clientset kubernetes.Interface, name string) string {
+	ns, err := clientset.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting namespace: %v", err)
+	}
+	return ns.Annotations[protectedAnnotation]
+}
+
+func TestParseProtectedCharts(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []protectedChart
+		wantErr bool
+	}{
+		{
+			name:    "empty annotation",
+			entries: []string{""},
+			want:    []protectedChart{},
+		},
+		{
+			name:    "empty entries are skipped",
+			entries: []string{",kaa,", " mariadb "},
+			want:    []protectedChart{{Name: "kaa"}, {Name: "mariadb"}},
+		},
+		{
+			name:    "pinned version",
+			entries: []string{"kaa,db=1.2.3"},
+			want:    []protectedChart{{Name: "kaa"}, {Name: "db", Version: "1.2.3"}},
+		},
+		{
+			name:    "chart is pinned by a later entry",
+			entries: []string{"db", "db=1.2.3"},
+			want:    []protectedChart{{Name: "db", Version: "1.2.3"}},
+		},
+		{
+			name:    "empty version",
+			entries: []string{"db="},
+			wantErr: true,
+		},
+		{
+			name:    "empty chart",
+			entries: []string{"=1.2.3"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProtectedCharts(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProtectedCharts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProtectedCharts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployEnv_ProtectedCharts(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotation      string
+		protectedCharts []string
+		chartsFile      string
+		override        []string
+		wantCharts      []string
+		wantAnnotation  string
+	}{
+		{
+			name:           "protected chart keeps its installed version",
+			annotation:     "kaa",
+			chartsFile:     "testdata/charts.yaml",
+			override:       []string{"kaa=0.2.0"},
+			wantCharts:     []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+			wantAnnotation: "kaa",
+		},
+		{
+			name:            "protected chart is overridden by the deployment protecting it",
+			annotation:      "kaa",
+			protectedCharts: []string{"kaa"},
+			chartsFile:      "testdata/charts.yaml",
+			override:        []string{"kaa=0.2.0"},
+			wantCharts:      []string{"cassandra=0.4.0", "kaa=0.2.0", "mariadb=0.5.4"},
+			wantAnnotation:  "kaa",
+		},
+		{
+			name:            "other protected charts keep their installed version",
+			annotation:      "mariadb,kaa",
+			protectedCharts: []string{"mariadb"},
+			chartsFile:      "testdata/charts.yaml",
+			override:        []string{"kaa=0.2.0", "mariadb=0.6.0"},
+			wantCharts:      []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.6.0"},
+			wantAnnotation:  "mariadb,kaa",
+		},
+		{
+			name:            "pinned chart is deployed at its pinned version by the deployment protecting it",
+			annotation:      "kaa=0.1.5",
+			protectedCharts: []string{"kaa"},
+			chartsFile:      "testdata/charts.yaml",
+			override:        []string{"kaa=0.2.0"},
+			wantCharts:      []string{"cassandra=0.4.0", "kaa=0.1.5", "mariadb=0.5.4"},
+			wantAnnotation:  "kaa=0.1.5",
+		},
+		{
+			name:           "pinned chart is deployed at its pinned version",
+			annotation:     "kaa=0.1.5",
+			chartsFile:     "testdata/charts.yaml",
+			override:       []string{"kaa=0.2.0"},
+			wantCharts:     []string{"cassandra=0.4.0", "kaa=0.1.5", "mariadb=0.5.4"},
+			wantAnnotation: "kaa=0.1.5",
+		},
+		{
+			name:            "newly protected chart is deployed at its desired version",
+			protectedCharts: []string{"kaa"},
+			chartsFile:      "testdata/charts.yaml",
+			override:        []string{"kaa=0.2.0"},
+			wantCharts:      []string{"cassandra=0.4.0", "kaa=0.2.0", "mariadb=0.5.4"},
+			wantAnnotation:  "kaa",
+		},
+		{
+			name:            "newly protected chart is deployed at its pinned version",
+			annotation:      "mariadb",
+			protectedCharts: []string{"kaa=0.1.5"},
+			chartsFile:      "testdata/charts.yaml",
+			wantCharts:      []string{"cassandra=0.4.0", "kaa=0.1.5", "mariadb=0.5.4"},
+			wantAnnotation:  "mariadb,kaa=0.1.5",
+		},
+		{
+			name:           "protected chart is not deleted",
+			annotation:     "mariadb",
+			override:       []string{"cassandra=0.4.0"},
+			wantCharts:     []string{"cassandra=0.4.0", "mariadb=0.5.4"},
+			wantAnnotation: "mariadb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientSet()
+			helmClient := fake.NewHelmClient(clientset)
+			e := newTestEnvCmd(clientset, helmClient)
+			e.chartsFile = "testdata/charts.yaml"
+			e.parallel = 0
+			if err := e.deployEnv(); err != nil {
+				t.Fatalf("deployEnv() error = %v", err)
+			}
+			if err := utils.UpdateNamespace(clientset, testEnv, map[string]string{protectedAnnotation: tt.annotation}, map[string]string{}, false); err != nil {
+				t.Fatalf("failed updating namespace: %v", err)
+			}
+
+			e.chartsFile = tt.chartsFile
+			e.override = tt.override
+			e.protectedCharts = tt.protectedCharts
+			if err := e.deployEnv(); err != nil {
+				t.Fatalf("deployEnv() error = %v", err)
+			}
+			if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, tt.wantCharts) {
+				t.Errorf("deployEnv() charts = %v, want %v", got, tt.wantCharts)
+			}
+			if got := protectedAnnotationValue(t, clientset, testEnv); got != tt.wantAnnotation {
+				t.Errorf("deployEnv() protected charts = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func TestDeployEnv_ProtectedChartsFirstDeploy(t *testing.T) {
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.chartsFile = "testdata/charts.yaml"
+	e.protectedCharts = []string{"kaa", "cassandra=0.3.0"}
+	e.parallel = 0
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+
+	want := []string{"cassandra=0.3.0", "kaa=0.1.7", "mariadb=0.5.4"}
+	if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, want) {
+		t.Errorf("deployEnv() charts = %v, want %v", got, want)
+	}
+	if got, want := protectedAnnotationValue(t, clientset, testEnv), "kaa,cassandra=0.3.0"; got != want {
+		t.Errorf("deployEnv() protected charts = %v, want %v", got, want)
+	}
+}
+
+func TestProtectChart(t *testing.T) {
+	clientset := fake.NewClientSet(fake.Namespace(testEnv, map[string]string{protectedAnnotation: "kaa"}))
+	helmClient := fake.NewHelmClient(clientset)
+	clients := fake.NewClients(clientset, helmClient)
+
+	p := &protectedCmd{name: testEnv, charts: []string{"db=1.2.3", "kaa"}, clients: clients}
+	if err := p.protectCharts(); err != nil {
+		t.Fatalf("protectCharts() error = %v", err)
+	}
+	if got, want := protectedAnnotationValue(t, clientset, testEnv), "kaa,db=1.2.3"; got != want {
+		t.Errorf("protectCharts() protected charts = %v, want %v", got, want)
+	}
+
+	p = &protectedCmd{name: testEnv, charts: []string{"kaa", "mariadb"}, clients: clients}
+	if err := p.unprotectCharts(); err != nil {
+		t.Fatalf("unprotectCharts() error = %v", err)
+	}
+	if got, want := protectedAnnotationValue(t, clientset, testEnv), "db=1.2.3"; got != want {
+		t.Errorf("unprotectCharts() protected charts = %v, want %v", got, want)
+	}
+
+	if got := envState(t, clientset, testEnv); got != freeState {
+		t.Errorf("unprotectCharts() state = %v, want %v", got, freeState)
+	}
+
+	// Protected charts are not changed while the environment is busy (e.g. deployed)
+	if err := utils.UpdateNamespace(clientset, testEnv, map[string]string{stateAnnotation: busyState}, map[string]string{}, false); err != nil {
+		t.Fatal(err)
+	}
+	p = &protectedCmd{name: testEnv, charts: []string{"kaa"}, lockTimeout: 1, clients: clients}
+	if err := p.protectCharts(); ExitCode(err) != ExitCodeLocked {
+		t.Errorf("protectCharts() of a busy environment error = %v, exit code = %v, want %v", err, ExitCode(err), ExitCodeLocked)
+	}
+	if got, want := protectedAnnotationValue(t, clientset, testEnv), "db=1.2.3"; got != want {
+		t.Errorf("protectCharts() of a busy environment protected charts = %v, want %v", got, want)
+	}
+
+	p = &protectedCmd{name: "missing", charts: []string{"kaa"}, clients: clients}
+	if err := p.protectCharts(); err == nil {
+		t.Errorf("protectCharts() of a missing environment error = nil, want error")
+	}
+}
+
+func TestGetProtected(t *testing.T) {
+	clientset := fake.NewClientSet()
+	helmClient := fake.NewHelmClient(clientset)
+	e := newTestEnvCmd(clientset, helmClient)
+	e.chartsFile = "testdata/charts.yaml"
+	e.protectedCharts = []string{"kaa", "db=1.2.3"}
+	e.parallel = 0
+	if err := e.deployEnv(); err != nil {
+		t.Fatalf("deployEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{
+			output: "yaml",
+			want: `charts:
+- name: kaa
+  installed: 0.1.7
+- name: db
+  version: 1.2.3
+`,
+		},
+		{
+			output: "table",
+			want: `NAME	PINNED VERSION	INSTALLED VERSION
+kaa 	-             	0.1.7
+db  	1.2.3         	-
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			var out bytes.Buffer
+			p := &protectedCmd{name: testEnv, output: tt.output, clients: e.clients, out: &out}
+			if err := p.getProtected(); err != nil {
+				t.Fatalf("getProtected() error = %v", err)
+			}
+			// Table cells are padded to the width of their column
+			got := regexp.MustCompile(` +\n`).ReplaceAllString(out.String(), "\n")
+			if got != tt.want {
+				t.Errorf("getProtected() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package orca
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+type releaseCmd struct {
+	name            string
+	charts          []string
+	selector        string
+	regex           string
+	chartsFile      string
+	protectedCharts []string
+	kubeContext     string
+	tls             bool
+	helmTLSStore    string
+	helmClient      string
+	parallel        int
+	timeout         int
+	lockTimeout     int
+	logDir          string
+	gracePeriod     int
+
+	clients *utils.Clients
+	out     io.Writer
+}
+
+// NewDeleteReleaseCmd represents the delete release command
+func NewDeleteReleaseCmd(out io.Writer, clients *utils.Clients) *cobra.Command {
+	r := &releaseCmd{clients: clients, out: out}
+
+	cmd := &cobra.Command{
+		Use:   "release",
+		Short: "Delete specific Helm releases from an environment (Kubernetes namespace)",
+		Long:  ``,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if r.name == "" {
+				return errors.New("name can not be empty")
+			}
+			if len(r.charts) == 0 && r.selector == "" && r.regex == "" {
+				return errors.New("at least one of chart, selector or regex has to be set")
+			}
+			if r.selector != "" {
+				if _, err := labels.Parse(r.selector); err != nil {
+					return fmt.Errorf("invalid selector: %v", err)
+				}
+			}
+			if r.regex != "" {
+				if _, err := regexp.Compile(r.regex); err != nil {
+					return fmt.Errorf("invalid regex: %v", err)
+				}
+			}
+			if r.tls && r.helmTLSStore == "" {
+				return errors.New("tls is set to true and helm-tls-store is not defined")
+			}
+			if r.tls && r.kubeContext == "" {
+				return errors.New("kube-context has to be non-empty when tls is set to true")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := r.deleteReleases(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVarP(&r.name, "name", "n", "", "name of environment (namespace) to delete releases from. Overrides $ORCA_NAME")
+	bindEnvVar(f, "name", "ORCA_NAME")
+	f.StringSliceVar(&r.charts, "chart", []string{}, "name of chart to delete the release of (can specify multiple). Overrides $ORCA_CHART")
+	bindEnvVar(f, "chart", "ORCA_CHART")
+	f.StringVarP(&r.selector, "selector", "l", "", "label selector of workloads, services and pods to delete the releases of (by the release or app.kubernetes.io/instance labels). Overrides $ORCA_SELECTOR")
+	bindEnvVar(f, "selector", "ORCA_SELECTOR")
+	f.StringVar(&r.regex, "regex", "", "regular expression of chart names to delete the releases of. Overrides $ORCA_REGEX")
+	bindEnvVar(f, "regex", "ORCA_REGEX")
+	f.StringVarP(&r.chartsFile, "charts-file", "c", "", "path to file with list of Helm charts, used to delete releases in reverse order of their dependencies (default is the dependencies recorded when the environment was deployed). Overrides $ORCA_CHARTS_FILE")
+	bindEnvVar(f, "charts-file", "ORCA_CHARTS_FILE")
+	f.StringSliceVar(&r.protectedCharts, "protected-chart", []string{}, "chart name to protect from being deleted, in addition to the environment's protected charts (can specify multiple). Overrides $ORCA_PROTECTED_CHART")
+	bindEnvVar(f, "protected-chart", "ORCA_PROTECTED_CHART")
+	f.StringVar(&r.kubeContext, "kube-context", "", "name of the kubeconfig context to use. Overrides $ORCA_KUBE_CONTEXT")
+	bindEnvVar(f, "kube-context", "ORCA_KUBE_CONTEXT")
+	f.BoolVar(&r.tls, "tls", false, "enable TLS for request. Overrides $ORCA_TLS")
+	bindEnvVar(f, "tls", "ORCA_TLS")
+	f.StringVar(&r.helmTLSStore, "helm-tls-store", "", "path to TLS certs and keys. Overrides $HELM_TLS_STORE")
+	bindEnvVar(f, "helm-tls-store", "HELM_TLS_STORE")
+	f.StringVar(&r.helmClient, "helm-client", utils.HelmClientSDK, "helm client to use (sdk, exec). exec requires the helm binary. Overrides $ORCA_HELM_CLIENT")
+	bindEnvVar(f, "helm-client", "ORCA_HELM_CLIENT")
+	f.IntVarP(&r.parallel, "parallel", "p", 1, "number of releases to act on in parallel. set this flag to 0 for full parallelism. Overrides $ORCA_PARALLEL")
+	bindEnvVar(f, "parallel", "ORCA_PARALLEL")
+	f.IntVar(&r.timeout, "timeout", 300, "time in seconds to wait for any individual Kubernetes operation (like Jobs for hooks). Overrides $ORCA_TIMEOUT")
+	bindSecondsEnvVar(f, "timeout", "ORCA_TIMEOUT")
+	f.IntVar(&r.lockTimeout, "lock-timeout", 0, "time in seconds to wait for a busy (locked) environment before failing. set this flag to 0 to wait indefinitely. Overrides $ORCA_LOCK_TIMEOUT")
+	bindSecondsEnvVar(f, "lock-timeout", "ORCA_LOCK_TIMEOUT")
+	f.StringVar(&r.logDir, "log-dir", "", "directory to write a log file per release to. Overrides $ORCA_LOG_DIR")
+	bindEnvVar(f, "log-dir", "ORCA_LOG_DIR")
+	f.IntVar(&r.gracePeriod, "grace-period", utils.DefaultGracePeriod, "time in seconds to wait for in-flight releases when interrupted (SIGINT, SIGTERM) before killing them. Overrides $ORCA_GRACE_PERIOD")
+	bindSecondsEnvVar(f, "grace-period", "ORCA_GRACE_PERIOD")
+
+	return cmd
+}
+
+// deleteReleases deletes the selected releases from an environment, dependents first
+func (r *releaseCmd) deleteReleases() error {
+	ctx, cancel := utils.WithGracePeriod(r.clients.Context, time.Duration(r.gracePeriod)*time.Second)
+	defer cancel()
+
+	helmClient, err := r.clients.Helm(r.helmClient)
+	if err != nil {
+		return err
+	}
+	clientset, err := r.clients.KubeClientSet(r.kubeContext)
+	if err != nil {
+		return err
+	}
+	var chartsFileReleases []utils.ReleaseSpec
+	if r.chartsFile != "" {
+		if chartsFileReleases, err = utils.InitReleasesFromChartsFile(r.chartsFile, r.name); err != nil {
+			return err
+		}
+	}
+	nsExists, err := utils.NamespaceExists(clientset, r.name)
+	if err != nil {
+		return err
+	}
+	if !nsExists {
+		return fmt.Errorf("environment \"%s\" not found", r.name)
+	}
+	// The environment is locked before its releases and protected charts are read, so they do not change until deleted
+	if err := lockEnvironment(ctx, clientset, r.name, time.Duration(r.lockTimeout)*time.Second, true); err != nil {
+		return err
+	}
+
+	log.Print("getting currently deployed releases")
+	installedReleases, err := utils.GetInstalledReleases(utils.GetInstalledReleasesOptions{
+		ClientSet:     clientset,
+		Namespace:     r.name,
+		IncludeFailed: true,
+	})
+	if err != nil {
+		unlockEnvironment(clientset, r.name, true)
+		return err
+	}
+	releasesToDelete, err := r.selectReleases(clientset, installedReleases)
+	if err != nil {
+		unlockEnvironment(clientset, r.name, true)
+		return err
+	}
+	if len(releasesToDelete) == 0 {
+		log.Printf("no releases to delete in environment \"%s\"", r.name)
+		return unlockEnvironment(clientset, r.name, true)
+	}
+	// Dependencies are taken from the charts file if set, or from the dependencies recorded in the environment
+	graph := installedReleases
+	if r.chartsFile != "" {
+		graph = chartsFileReleases
+	}
+	releasesToDelete = withDependencies(releasesToDelete, graph)
+	warnRemainingDependents(releasesToDelete, installedReleases, graph)
+
+	log.Print("deleting releases")
+	if err := utils.DeleteReleases(ctx, utils.DeleteReleasesOptions{
+		Helm:             helmClient,
+		ReleasesToDelete: releasesToDelete,
+		KubeContext:      r.kubeContext,
+		TLS:              r.tls,
+		HelmTLSStore:     r.helmTLSStore,
+		Parallel:         r.parallel,
+		Timeout:          r.timeout,
+		Out:              r.out,
+		LogDir:           r.logDir,
+	}); err != nil {
+		markEnvironmentAsFailed(clientset, r.name, true)
+		return interruptedError(ctx, r.name, err)
+	}
+	if err := unlockEnvironment(clientset, r.name, true); err != nil {
+		return err
+	}
+	log.Printf("deleted %d release(s) from environment \"%s\"", len(releasesToDelete), r.name)
+	return nil
+}
+
+// selectReleases returns the installed releases which are selected by chart name, label selector or regex.
+// Selecting a protected chart by name is an error, protected charts matched by the selector or regex are skipped
+func (r *releaseCmd) selectReleases(clientset kubernetes.Interface, installedReleases []utils.ReleaseSpec) ([]utils.ReleaseSpec, error) {
+	protectedCharts, err := getProtectedCharts(clientset, r.name)
+	if err != nil {
+		return nil, err
+	}
+	protectedChartsToAdd, err := parseProtectedCharts(r.protectedCharts)
+	if err != nil {
+		return nil, err
+	}
+	protectedNames := protectedChartNames(mergeProtectedCharts(protectedCharts, protectedChartsToAdd))
+
+	for _, c := range r.charts {
+		if utils.Contains(protectedNames, c) {
+			return nil, fmt.Errorf("%w: chart \"%s\" is protected in environment \"%s\"", ErrEnvironmentProtected, c, r.name)
+		}
+		if utils.GetChartIndex(installedReleases, c) == -1 {
+			log.Printf("chart \"%s\" not found in environment \"%s\"", c, r.name)
+		}
+	}
+	var selectedReleaseNames []string
+	if r.selector != "" {
+		if selectedReleaseNames, err = utils.GetReleaseNamesBySelector(clientset, r.name, r.selector); err != nil {
+			return nil, err
+		}
+	}
+	var re *regexp.Regexp
+	if r.regex != "" {
+		re = regexp.MustCompile(r.regex)
+	}
+
+	var selected []utils.ReleaseSpec
+	for _, ir := range installedReleases {
+		byName := utils.Contains(r.charts, ir.ChartName)
+		bySelector := utils.Contains(selectedReleaseNames, ir.ReleaseName)
+		byRegex := re != nil && re.MatchString(ir.ChartName)
+		if !byName && !bySelector && !byRegex {
+			continue
+		}
+		if utils.Contains(protectedNames, ir.ChartName) {
+			log.Printf("skipping protected chart \"%s\"", ir.ChartName)
+			continue
+		}
+		selected = append(selected, ir)
+	}
+	return selected, nil
+}
+
+// withDependencies sets the dependencies of releases by a graph of releases (e.g. a charts file),
+// including transitive dependencies through releases which are not in the list
+func withDependencies(releases, graph []utils.ReleaseSpec) []utils.ReleaseSpec {
+	dependencies := map[string][]string{}
+	for _, r := range graph {
+		dependencies[r.ChartName] = r.Dependencies
+	}
+	var out []utils.ReleaseSpec
+	for _, r := range releases {
+		r.Dependencies = []string{}
+		visited := map[string]bool{r.ChartName: true}
+		queue := append([]string{}, dependencies[r.ChartName]...)
+		for len(queue) != 0 {
+			d := queue[0]
+			queue = queue[1:]
+			if visited[d] {
+				continue
+			}
+			visited[d] = true
+			if utils.GetChartIndex(releases, d) != -1 {
+				r.Dependencies = append(r.Dependencies, d)
+			}
+			queue = append(queue, dependencies[d]...)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// warnRemainingDependents logs a warning for each release which remains in the environment and depends on a deleted release
+func warnRemainingDependents(releasesToDelete, installedReleases, graph []utils.ReleaseSpec) {
+	for _, cr := range graph {
+		if utils.GetChartIndex(releasesToDelete, cr.ChartName) != -1 || utils.GetChartIndex(installedReleases, cr.ChartName) == -1 {
+			continue
+		}
+		var deleted []string
+		for _, d := range cr.Dependencies {
+			if utils.GetChartIndex(releasesToDelete, d) != -1 {
+				deleted = append(deleted, d)
+			}
+		}
+		if len(deleted) != 0 {
+			log.Printf("WARNING: chart \"%s\" remains in the environment and depends on deleted chart(s) %s", cr.ChartName, strings.Join(deleted, ", "))
+		}
+	}
+}
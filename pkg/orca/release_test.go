@@ -0,0 +1,124 @@
+package orca
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+	"github.com/nuvo/orca/pkg/utils/fake"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeleteReleases(t *testing.T) {
+	database := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:      "mariadb",
+		Namespace: testEnv,
+		Labels:    map[string]string{"tier": "database", "release": testEnv + "-mariadb"},
+	}}
+	tests := []struct {
+		name            string
+		charts          []string
+		selector        string
+		regex           string
+		chartsFile      string
+		protectedCharts []string
+		state           string
+		wantExitCode    int
+		wantDeleted     []string
+		wantCharts      []string
+	}{
+		{
+			name:        "by chart in reverse dependency order",
+			charts:      []string{"cassandra", "kaa"},
+			chartsFile:  "testdata/charts.yaml",
+			wantDeleted: []string{testEnv + "-kaa", testEnv + "-cassandra"},
+			wantCharts:  []string{"mariadb=0.5.4"},
+		},
+		{
+			name:        "by regex",
+			regex:       "^ka",
+			wantDeleted: []string{testEnv + "-kaa"},
+			wantCharts:  []string{"cassandra=0.4.0", "mariadb=0.5.4"},
+		},
+		{
+			name:        "by selector",
+			selector:    "tier=database",
+			wantDeleted: []string{testEnv + "-mariadb"},
+			wantCharts:  []string{"cassandra=0.4.0", "kaa=0.1.7"},
+		},
+		{
+			name:            "protected chart by name",
+			charts:          []string{"kaa"},
+			protectedCharts: []string{"kaa"},
+			wantExitCode:    ExitCodeLocked,
+			wantCharts:      []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+		{
+			name:       "no releases selected",
+			regex:      "^none$",
+			wantCharts: []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+		{
+			name:            "protected chart by regex is skipped",
+			regex:           "^(kaa|mariadb)$",
+			chartsFile:      "testdata/charts.yaml",
+			protectedCharts: []string{"mariadb"},
+			wantDeleted:     []string{testEnv + "-kaa"},
+			wantCharts:      []string{"cassandra=0.4.0", "mariadb=0.5.4"},
+		},
+		{
+			name:         "locked environment",
+			charts:       []string{"kaa"},
+			state:        failedState,
+			wantExitCode: ExitCodeLocked,
+			wantCharts:   []string{"cassandra=0.4.0", "kaa=0.1.7", "mariadb=0.5.4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientSet(database)
+			helmClient := fake.NewHelmClient(clientset)
+			e := newTestEnvCmd(clientset, helmClient)
+			e.chartsFile = "testdata/charts.yaml"
+			e.parallel = 0
+			e.protectedCharts = tt.protectedCharts
+			if err := e.deployEnv(); err != nil {
+				t.Fatalf("deployEnv() error = %v", err)
+			}
+			if tt.state != "" {
+				if err := utils.UpdateNamespace(clientset, testEnv, map[string]string{stateAnnotation: tt.state}, map[string]string{}, false); err != nil {
+					t.Fatalf("failed updating namespace: %v", err)
+				}
+			}
+
+			r := &releaseCmd{
+				name:       testEnv,
+				charts:     tt.charts,
+				selector:   tt.selector,
+				regex:      tt.regex,
+				chartsFile: tt.chartsFile,
+				parallel:   0,
+				clients:    e.clients,
+				out:        e.out,
+			}
+			err := r.deleteReleases()
+			if code := ExitCode(err); code != tt.wantExitCode {
+				t.Fatalf("deleteReleases() error = %v, exit code = %v, want %v", err, code, tt.wantExitCode)
+			}
+			if !reflect.DeepEqual(helmClient.Deleted, tt.wantDeleted) {
+				t.Errorf("deleteReleases() deleted = %v, want %v", helmClient.Deleted, tt.wantDeleted)
+			}
+			if got := installedCharts(t, clientset, testEnv); !reflect.DeepEqual(got, tt.wantCharts) {
+				t.Errorf("deleteReleases() charts = %v, want %v", got, tt.wantCharts)
+			}
+			// The environment is unlocked after failures as well, unless it was locked beforehand
+			if tt.state == "" {
+				if got := envState(t, clientset, testEnv); got != freeState {
+					t.Errorf("deleteReleases() state = %v, want %v", got, freeState)
+				}
+			}
+		})
+	}
+}
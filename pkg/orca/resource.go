@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"os"
 	"strings"
 
 	"github.com/nuvo/orca/pkg/utils"
@@ -40,21 +38,26 @@ func NewCreateResourceCmd(out io.Writer) *cobra.Command {
 			if r.update {
 				method = "PATCH"
 			}
-			utils.PerformRequest(utils.PerformRequestOptions{
+			if _, err := utils.PerformRequest(utils.PerformRequestOptions{
 				Method:             method,
 				URL:                r.url,
 				Headers:            r.headers,
 				ExpectedStatusCode: 201,
 				Data:               nil,
-			})
+			}); err != nil {
+				fatal(err)
+			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&r.url, "url", os.Getenv("ORCA_URL"), "url to send the request to. Overrides $ORCA_URL")
-	f.StringVar(&r.method, "method", utils.GetStringEnvVar("ORCA_METHOD", "POST"), "method to use in the request. Overrides $ORCA_METHOD")
-	f.BoolVar(&r.update, "update", utils.GetBoolEnvVar("ORCA_UPDATE", false), "should method be PUT instead of POST. Overrides $ORCA_UPDATE")
+	f.StringVar(&r.url, "url", "", "url to send the request to. Overrides $ORCA_URL")
+	bindEnvVar(f, "url", "ORCA_URL")
+	f.StringVar(&r.method, "method", "POST", "method to use in the request. Overrides $ORCA_METHOD")
+	bindEnvVar(f, "method", "ORCA_METHOD")
+	f.BoolVar(&r.update, "update", false, "should method be PUT instead of POST. Overrides $ORCA_UPDATE")
+	bindEnvVar(f, "update", "ORCA_UPDATE")
 	f.StringSliceVar(&r.headers, "headers", []string{}, "headers of the request (supports multiple)")
 
 	return cmd
@@ -71,15 +74,18 @@ func NewGetResourceCmd(out io.Writer) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			var data []map[string]interface{}
-			bytes := utils.PerformRequest(utils.PerformRequestOptions{
+			bytes, err := utils.PerformRequest(utils.PerformRequestOptions{
 				Method:             "GET",
 				URL:                r.url,
 				Headers:            r.headers,
 				ExpectedStatusCode: 200,
 				Data:               nil,
 			})
+			if err != nil {
+				fatal(err)
+			}
 			if err := json.Unmarshal(bytes, &data); err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			if r.key == "" {
 				if r.printKey != "" {
@@ -115,13 +121,19 @@ func NewGetResourceCmd(out io.Writer) *cobra.Command {
 
 	f := cmd.Flags()
 
-	f.StringVar(&r.url, "url", os.Getenv("ORCA_URL"), "url to send the request to. Overrides $ORCA_URL")
+	f.StringVar(&r.url, "url", "", "url to send the request to. Overrides $ORCA_URL")
+	bindEnvVar(f, "url", "ORCA_URL")
 	f.StringSliceVar(&r.headers, "headers", []string{}, "headers of the request (supports multiple)")
-	f.StringVar(&r.key, "key", os.Getenv("ORCA_KEY"), "find the desired object according to this key. Overrides $ORCA_KEY")
-	f.StringVar(&r.value, "value", os.Getenv("ORCA_VALUE"), "find the desired object according to to key`s value. Overrides $ORCA_VALUE")
-	f.IntVar(&r.offset, "offset", utils.GetIntEnvVar("ORCA_OFFSET", 0), "offset of the desired object from the reference key. Overrides $ORCA_OFFSET")
-	f.StringVarP(&r.errorIndicator, "error-indicator", "e", utils.GetStringEnvVar("ORCA_ERROR_INDICATOR", "E"), "string indicating an error in the request. Overrides $ORCA_ERROR_INDICATOR")
-	f.StringVarP(&r.printKey, "print-key", "p", os.Getenv("ORCA_PRINT_KEY"), "key to print. If not specified - prints the response. Overrides $ORCA_PRINT_KEY")
+	f.StringVar(&r.key, "key", "", "find the desired object according to this key. Overrides $ORCA_KEY")
+	bindEnvVar(f, "key", "ORCA_KEY")
+	f.StringVar(&r.value, "value", "", "find the desired object according to to key`s value. Overrides $ORCA_VALUE")
+	bindEnvVar(f, "value", "ORCA_VALUE")
+	f.IntVar(&r.offset, "offset", 0, "offset of the desired object from the reference key. Overrides $ORCA_OFFSET")
+	bindEnvVar(f, "offset", "ORCA_OFFSET")
+	f.StringVarP(&r.errorIndicator, "error-indicator", "e", "E", "string indicating an error in the request. Overrides $ORCA_ERROR_INDICATOR")
+	bindEnvVar(f, "error-indicator", "ORCA_ERROR_INDICATOR")
+	f.StringVarP(&r.printKey, "print-key", "p", "", "key to print. If not specified - prints the response. Overrides $ORCA_PRINT_KEY")
+	bindEnvVar(f, "print-key", "ORCA_PRINT_KEY")
 
 	return cmd
 }
@@ -135,19 +147,22 @@ func NewDeleteResourceCmd(out io.Writer) *cobra.Command {
 		Short: "Delete a resource via REST API",
 		Long:  ``,
 		Run: func(cmd *cobra.Command, args []string) {
-			utils.PerformRequest(utils.PerformRequestOptions{
+			if _, err := utils.PerformRequest(utils.PerformRequestOptions{
 				Method:             "DELETE",
 				URL:                r.url,
 				Headers:            r.headers,
 				ExpectedStatusCode: 204,
 				Data:               nil,
-			})
+			}); err != nil {
+				fatal(err)
+			}
 		},
 	}
 
 	f := cmd.Flags()
 
-	f.StringVar(&r.url, "url", os.Getenv("ORCA_URL"), "url to send the request to. Overrides $ORCA_URL")
+	f.StringVar(&r.url, "url", "", "url to send the request to. Overrides $ORCA_URL")
+	bindEnvVar(f, "url", "ORCA_URL")
 	f.StringSliceVar(&r.headers, "headers", []string{}, "headers of the request (supports multiple)")
 
 	return cmd
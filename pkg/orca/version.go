@@ -0,0 +1,143 @@
+package orca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nuvo/orca/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// versionOptions are options of commands which calculate versions from the git history
+type versionOptions struct {
+	tagPrefix  string
+	mainRef    string
+	releaseRef string
+	currentRef string
+	imageTag   string
+}
+
+// chartVersion is a version calculated from the git history, along with the appVersion of the chart
+type chartVersion struct {
+	utils.CalculatedVersion `yaml:",inline"`
+	AppVersion              string `json:"appVersion,omitempty" yaml:"appVersion,omitempty"`
+}
+
+// addFlags adds the flags of the options to a command
+func (v *versionOptions) addFlags(f *pflag.FlagSet) {
+	f.StringVar(&v.tagPrefix, "tag-prefix", "v", "prefix of version tags. Overrides $ORCA_TAG_PREFIX")
+	bindEnvVar(f, "tag-prefix", "ORCA_TAG_PREFIX")
+	f.StringVar(&v.mainRef, "main-ref", "", "name of the reference which is the main line, versioned without pre-release identifiers. Overrides $ORCA_MAIN_REF")
+	bindEnvVar(f, "main-ref", "ORCA_MAIN_REF")
+	f.StringVar(&v.releaseRef, "rel-ref", "", "release reference name (or regex), versioned without pre-release identifiers. Overrides $ORCA_REL_REF")
+	bindEnvVar(f, "rel-ref", "ORCA_REL_REF")
+	f.StringVar(&v.currentRef, "curr-ref", "", "current reference name (default is the branch of HEAD). Overrides $ORCA_CURR_REF")
+	bindEnvVar(f, "curr-ref", "ORCA_CURR_REF")
+	f.StringVar(&v.imageTag, "image-tag", "", "image tag to set as the appVersion of the chart. Overrides $ORCA_IMAGE_TAG")
+	bindEnvVar(f, "image-tag", "ORCA_IMAGE_TAG")
+}
+
+// calculate calculates the version of a chart from the git history of the repository containing its path (only commits
+// which changed the path count). The version of the chart is bumped if there is no version tag in the history
+func (v *versionOptions) calculate(path string) (*chartVersion, error) {
+	baseVersion := ""
+	if path != "" {
+		var err error
+		if baseVersion, err = utils.GetChartVersion(path); err != nil {
+			return nil, err
+		}
+	}
+	calculated, err := utils.CalculateVersion(utils.CalculateVersionOptions{
+		RepoPath:    path,
+		Path:        path,
+		TagPrefix:   v.tagPrefix,
+		BaseVersion: baseVersion,
+		Ref:         v.currentRef,
+		MainRef:     v.mainRef,
+		ReleaseRef:  v.releaseRef,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &chartVersion{CalculatedVersion: *calculated, AppVersion: v.imageTag}, nil
+}
+
+type versionCmd struct {
+	path    string
+	version versionOptions
+	output  string
+
+	out io.Writer
+}
+
+// NewDetermineVersion represents the determine version command
+func NewDetermineVersion(out io.Writer) *cobra.Command {
+	v := &versionCmd{out: out}
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Determine the next version based on the git history",
+		Long: `Determine the next version based on the git history, without modifying any files.
+The latest version tag in the history of HEAD is bumped by the conventional commits since it:
+breaking changes bump the major version, features bump the minor version and any other commit bumps the patch version.
+On references other than the main line and release references (none if not set), pre-release identifiers of the reference, the number of commits and the short SHA are added.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			switch v.output {
+			case "", "yaml", "json":
+			default:
+				return errors.New("output can be one of: yaml, json")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := v.determineVersion(); err != nil {
+				fatal(err)
+			}
+		},
+	}
+
+	f := cmd.Flags()
+
+	f.StringVar(&v.path, "path", "", "path to chart, only commits which changed it count and its version is bumped if there is no version tag (default is to count all commits and bump 0.0.0). Overrides $ORCA_PATH")
+	bindEnvVar(f, "path", "ORCA_PATH")
+	v.version.addFlags(f)
+	f.StringVarP(&v.output, "output", "o", "", "output format (yaml, json), default is to print the version only. Overrides $ORCA_OUTPUT")
+	bindEnvVar(f, "output", "ORCA_OUTPUT")
+
+	return cmd
+}
+
+// determineVersion prints the next version
+func (v *versionCmd) determineVersion() error {
+	version, err := v.version.calculate(v.path)
+	if err != nil {
+		return err
+	}
+	return v.printVersion(version)
+}
+
+// printVersion prints a version, or all details of its calculation as yaml or json
+func (v *versionCmd) printVersion(version *chartVersion) error {
+	switch v.output {
+	case "yaml":
+		data, err := yaml.Marshal(version)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(v.out, string(data))
+	case "json":
+		data, err := json.MarshalIndent(version, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(v.out, string(data))
+	default:
+		fmt.Fprintln(v.out, version.Version)
+	}
+	return nil
+}
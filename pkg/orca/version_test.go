@@ -0,0 +1,73 @@
+package orca
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nuvo/orca/pkg/utils"
+)
+
+func TestPrintVersion(t *testing.T) {
+	version := &chartVersion{
+		CalculatedVersion: utils.CalculatedVersion{
+			Version:         "1.3.0-feature-login.2.gabc1234",
+			PreviousVersion: "1.2.0",
+			PreviousTag:     "v1.2.0",
+			Bump:            utils.VersionBumpMinor,
+			Commits:         2,
+			Commit:          "abc1234",
+			Prerelease:      "feature-login.2.gabc1234",
+		},
+		AppVersion: "abc1234",
+	}
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name: "version",
+			want: "1.3.0-feature-login.2.gabc1234\n",
+		},
+		{
+			name:   "yaml",
+			output: "yaml",
+			want: `version: 1.3.0-feature-login.2.gabc1234
+previousVersion: 1.2.0
+previousTag: v1.2.0
+bump: minor
+commits: 2
+commit: abc1234
+prerelease: feature-login.2.gabc1234
+appVersion: abc1234
+`,
+		},
+		{
+			name:   "json",
+			output: "json",
+			want: `{
+  "version": "1.3.0-feature-login.2.gabc1234",
+  "previousVersion": "1.2.0",
+  "previousTag": "v1.2.0",
+  "bump": "minor",
+  "commits": 2,
+  "commit": "abc1234",
+  "prerelease": "feature-login.2.gabc1234",
+  "appVersion": "abc1234"
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			v := &versionCmd{output: tt.output, out: &out}
+			if err := v.printVersion(version); err != nil {
+				t.Fatalf("printVersion() error = %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("printVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
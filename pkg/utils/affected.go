@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// AffectedReasonChanged is the reason of a service which is affected by changed paths
+	AffectedReasonChanged = "changed"
+	// AffectedReasonDependency is the reason of a service which is affected because a service it depends on is affected
+	AffectedReasonDependency = "dependency"
+	// AffectedReasonAll is the reason of all services being affected, when changed paths can not be determined
+	AffectedReasonAll = "all"
+)
+
+// ServicesFile describes the services of a repository, the paths they are built from and the Helm charts they are deployed by
+type ServicesFile struct {
+	Services []Service `yaml:"services"`
+}
+
+// Service is a service (or a library) in a repository. A service without a chart is not deployed by itself,
+// but services which depend on it are affected by its changes
+type Service struct {
+	Name string `yaml:"name"`
+	// Paths are globs of the paths the service is built from
+	Paths []string `yaml:"paths"`
+	// Chart is the name of the Helm chart the service is deployed by
+	Chart string `yaml:"chart,omitempty"`
+	// ChartPath is the path to the chart, which its version is read from
+	ChartPath string   `yaml:"chartPath,omitempty"`
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+
+	paths []*regexp.Regexp
+}
+
+// AffectedService is a service which is affected by changed paths
+type AffectedService struct {
+	Name    string   `json:"name"`
+	Chart   string   `json:"chart,omitempty"`
+	Version string   `json:"version,omitempty"`
+	Reason  string   `json:"reason"`
+	Paths   []string `json:"paths,omitempty"`
+	// AffectedBy are the affected services this service depends on
+	AffectedBy []string `json:"affectedBy,omitempty"`
+}
+
+// LoadServicesFile reads and validates a services file
+func LoadServicesFile(file string) (*ServicesFile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidServicesFile, err)
+	}
+	var s ServicesFile
+	if err := yaml.UnmarshalStrict(data, &s); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidServicesFile, file, err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidServicesFile, file, err)
+	}
+	return &s, nil
+}
+
+// compile validates the services and compiles their path globs
+func (s *ServicesFile) compile() error {
+	names := map[string]bool{}
+	for i := range s.Services {
+		svc := &s.Services[i]
+		if svc.Name == "" {
+			return fmt.Errorf("service %d has no name", i+1)
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("service \"%s\" is defined more than once", svc.Name)
+		}
+		names[svc.Name] = true
+		if len(svc.Paths) == 0 {
+			return fmt.Errorf("service \"%s\" has no paths", svc.Name)
+		}
+		svc.paths = nil
+		for _, g := range svc.Paths {
+			re, err := compileGlob(g)
+			if err != nil {
+				return fmt.Errorf("service \"%s\": %v", svc.Name, err)
+			}
+			svc.paths = append(svc.paths, re)
+		}
+	}
+	for _, svc := range s.Services {
+		for _, d := range svc.DependsOn {
+			if !names[d] {
+				return fmt.Errorf("service \"%s\" depends on undefined service \"%s\"", svc.Name, d)
+			}
+		}
+	}
+	return nil
+}
+
+// GetAffectedServices returns the services which are affected by changed paths, and the services which depend on them
+// (directly or transitively), in the order of the services file. If all is set, all services are affected
+func GetAffectedServices(s *ServicesFile, changedPaths []string, all bool) ([]AffectedService, error) {
+	if err := s.compile(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidServicesFile, err)
+	}
+
+	changed := map[string][]string{}
+	for _, svc := range s.Services {
+		for _, path := range changedPaths {
+			for _, re := range svc.paths {
+				if re.MatchString(path) {
+					changed[svc.Name] = append(changed[svc.Name], path)
+					break
+				}
+			}
+		}
+	}
+
+	// Follow the dependency graph from changed services to their dependents
+	dependents := map[string][]string{}
+	for _, svc := range s.Services {
+		for _, d := range svc.DependsOn {
+			dependents[d] = append(dependents[d], svc.Name)
+		}
+	}
+	affectedBy := map[string][]string{}
+	affected := map[string]bool{}
+	queue := []string{}
+	for _, svc := range s.Services {
+		if _, ok := changed[svc.Name]; ok || all {
+			affected[svc.Name] = true
+			queue = append(queue, svc.Name)
+		}
+	}
+	for len(queue) != 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, d := range dependents[name] {
+			affectedBy[d] = AddIfNotContained(affectedBy[d], name)
+			if !affected[d] {
+				affected[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+
+	services := []AffectedService{}
+	for _, svc := range s.Services {
+		if !affected[svc.Name] {
+			continue
+		}
+		a := AffectedService{Name: svc.Name, Chart: svc.Chart, Paths: changed[svc.Name], AffectedBy: affectedBy[svc.Name]}
+		sort.Strings(a.AffectedBy)
+		switch {
+		case all:
+			a.Reason = AffectedReasonAll
+		case len(a.Paths) != 0:
+			a.Reason = AffectedReasonChanged
+		default:
+			a.Reason = AffectedReasonDependency
+		}
+		if svc.ChartPath != "" {
+			version, err := GetChartVersion(svc.ChartPath)
+			if err != nil {
+				return nil, err
+			}
+			a.Version = version
+		}
+		services = append(services, a)
+	}
+	return services, nil
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLoadServicesFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		wantServices int
+		wantErr      bool
+	}{
+		{
+			name:         "valid services file",
+			file:         "testdata/affected/services.yaml",
+			wantServices: 4,
+		},
+		{
+			name:    "dependency on undefined service",
+			file:    "testdata/affected/invalid.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			file:    "testdata/affected/missing.yaml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadServicesFile(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadServicesFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidServicesFile) {
+					t.Errorf("LoadServicesFile() error = %v, want %v", err, ErrInvalidServicesFile)
+				}
+				return
+			}
+			if len(got.Services) != tt.wantServices {
+				t.Errorf("LoadServicesFile() services = %v, want %v", len(got.Services), tt.wantServices)
+			}
+		})
+	}
+}
+
+func TestGetAffectedServices(t *testing.T) {
+	tests := []struct {
+		name         string
+		changedPaths []string
+		all          bool
+		want         []AffectedService
+	}{
+		{
+			name:         "no affected services",
+			changedPaths: []string{"README.md"},
+			want:         []AffectedService{},
+		},
+		{
+			name:         "changed service and its dependents",
+			changedPaths: []string{"services/api/main.go"},
+			want: []AffectedService{
+				{Name: "api", Chart: "api", Version: "1.2.3", Reason: AffectedReasonChanged, Paths: []string{"services/api/main.go"}},
+				{Name: "web", Chart: "web", Version: "0.4.0", Reason: AffectedReasonDependency, AffectedBy: []string{"api"}},
+			},
+		},
+		{
+			name:         "changed library and its transitive dependents",
+			changedPaths: []string{"libs/common/log.go", "services/web/index.js"},
+			want: []AffectedService{
+				{Name: "common", Reason: AffectedReasonChanged, Paths: []string{"libs/common/log.go"}},
+				{Name: "api", Chart: "api", Version: "1.2.3", Reason: AffectedReasonDependency, AffectedBy: []string{"common"}},
+				{Name: "web", Chart: "web", Version: "0.4.0", Reason: AffectedReasonChanged, Paths: []string{"services/web/index.js"}, AffectedBy: []string{"api"}},
+			},
+		},
+		{
+			name: "all services",
+			all:  true,
+			want: []AffectedService{
+				{Name: "common", Reason: AffectedReasonAll},
+				{Name: "api", Chart: "api", Version: "1.2.3", Reason: AffectedReasonAll, AffectedBy: []string{"common"}},
+				{Name: "web", Chart: "web", Version: "0.4.0", Reason: AffectedReasonAll, AffectedBy: []string{"api"}},
+				{Name: "worker", Chart: "worker", Reason: AffectedReasonAll},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := LoadServicesFile("testdata/affected/services.yaml")
+			if err != nil {
+				t.Fatalf("LoadServicesFile() error = %v", err)
+			}
+			got, err := GetAffectedServices(s, tt.changedPaths, tt.all)
+			if err != nil {
+				t.Fatalf("GetAffectedServices() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAffectedServices() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// BuildTypeModeSingle determines a single build type, or the default type if changed paths match multiple types
+	BuildTypeModeSingle = "single"
+	// BuildTypeModeMultiple determines all matched build types, as long as all changed paths are matched
+	BuildTypeModeMultiple = "multiple"
+	// BuildTypeModeAll determines all matched build types, changed paths which are not matched do not affect the build type
+	BuildTypeModeAll = "all"
+)
+
+// Decisions which explain how a build type was determined by rules
+const (
+	// BuildTypeDecisionMatched is the decision when the build type was determined by matched rules
+	BuildTypeDecisionMatched = "matched"
+	// BuildTypeDecisionNoChanges is the decision when no paths changed
+	BuildTypeDecisionNoChanges = "no-changes"
+	// BuildTypeDecisionNoTypes is the decision when changed paths matched no types (e.g. only ignored paths changed)
+	BuildTypeDecisionNoTypes = "no-types"
+	// BuildTypeDecisionUnmatchedPaths is the decision when changed paths are not matched by any rule
+	BuildTypeDecisionUnmatchedPaths = "unmatched-paths"
+	// BuildTypeDecisionMultipleTypes is the decision when changed paths matched multiple types in single mode
+	BuildTypeDecisionMultipleTypes = "multiple-types"
+)
+
+// BuildTypeRules are rules to determine the build type by changed paths
+type BuildTypeRules struct {
+	// DefaultType is the build type when the build type can not be determined by the rules
+	DefaultType string `yaml:"defaultType,omitempty"`
+	// Mode is one of single (default), multiple and all
+	Mode  string          `yaml:"mode,omitempty"`
+	Rules []BuildTypeRule `yaml:"rules"`
+}
+
+// BuildTypeRule matches changed paths to a build type. A path matches a rule if it matches any of its include globs
+// (or its regex, like a path filter) and none of its exclude globs. Paths matched by an ignore rule do not affect the build type
+type BuildTypeRule struct {
+	Name     string   `yaml:"name,omitempty"`
+	Type     string   `yaml:"type,omitempty"`
+	Include  []string `yaml:"include,omitempty"`
+	Regex    string   `yaml:"regex,omitempty"`
+	Exclude  []string `yaml:"exclude,omitempty"`
+	Priority int      `yaml:"priority,omitempty"`
+	Ignore   bool     `yaml:"ignore,omitempty"`
+
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// BuildTypeMatch is the rule a changed path matched
+type BuildTypeMatch struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Type    string `json:"type,omitempty"`
+	Ignored bool   `json:"ignored,omitempty"`
+}
+
+// BuildTypeResult is the build type determined by rules, along with the rule each changed path matched
+type BuildTypeResult struct {
+	// Types are the determined build types
+	Types []string
+	// MatchedTypes are the types matched by changed paths, in the order they were matched
+	MatchedTypes []string
+	Matches      []BuildTypeMatch
+	Unmatched    []string
+	// Decision and Reason explain how the build types were determined
+	Decision string
+	Reason   string
+}
+
+// String returns the determined build types separated by semicolons
+func (r *BuildTypeResult) String() string {
+	return strings.Join(r.Types, ";")
+}
+
+// LoadBuildTypeRules reads and validates build type rules from a YAML file
+func LoadBuildTypeRules(file string) (*BuildTypeRules, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBuildTypeRules, err)
+	}
+	var rules BuildTypeRules
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBuildTypeRules, file, err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBuildTypeRules, file, err)
+	}
+	return &rules, nil
+}
+
+// compile validates the rules and compiles their globs
+func (b *BuildTypeRules) compile() error {
+	switch b.Mode {
+	case "", BuildTypeModeSingle, BuildTypeModeMultiple, BuildTypeModeAll:
+	default:
+		return fmt.Errorf("mode should be one of %s, %s, %s", BuildTypeModeSingle, BuildTypeModeMultiple, BuildTypeModeAll)
+	}
+	for i := range b.Rules {
+		r := &b.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if len(r.Include) == 0 && r.Regex == "" {
+			return fmt.Errorf("%s has no include globs or regex", r.Name)
+		}
+		if r.Type == "" && !r.Ignore {
+			return fmt.Errorf("%s has no type and is not an ignore rule", r.Name)
+		}
+		r.include, r.exclude = nil, nil
+		if r.Regex != "" {
+			re, err := regexp.Compile(r.Regex)
+			if err != nil {
+				return fmt.Errorf("%s: invalid regex \"%s\": %v", r.Name, r.Regex, err)
+			}
+			r.include = append(r.include, re)
+		}
+		for _, g := range r.Include {
+			re, err := compileGlob(g)
+			if err != nil {
+				return fmt.Errorf("%s: %v", r.Name, err)
+			}
+			r.include = append(r.include, re)
+		}
+		for _, g := range r.Exclude {
+			re, err := compileGlob(g)
+			if err != nil {
+				return fmt.Errorf("%s: %v", r.Name, err)
+			}
+			r.exclude = append(r.exclude, re)
+		}
+	}
+	return nil
+}
+
+// matches returns true if a path matches any of the include globs of the rule and none of its exclude globs
+func (r *BuildTypeRule) matches(path string) bool {
+	included := false
+	for _, re := range r.include {
+		if re.MatchString(path) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range r.exclude {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// DetermineBuildType determines the build type of changed paths by rules. Each path is matched by the rule with the
+// highest priority which matches it, or by the first such rule in case of a tie. The default type is determined when no
+// type is matched, when a path is not matched (unless the mode is all) or when multiple types are matched in single mode
+func DetermineBuildType(rules *BuildTypeRules, defaultType string, changedPaths []string) (*BuildTypeResult, error) {
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBuildTypeRules, err)
+	}
+	if rules.DefaultType != "" {
+		defaultType = rules.DefaultType
+	}
+	mode := rules.Mode
+	if mode == "" {
+		mode = BuildTypeModeSingle
+	}
+
+	ordered := make([]*BuildTypeRule, 0, len(rules.Rules))
+	for i := range rules.Rules {
+		ordered = append(ordered, &rules.Rules[i])
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+
+	result := &BuildTypeResult{MatchedTypes: []string{}, Matches: []BuildTypeMatch{}, Unmatched: []string{}}
+	for _, path := range changedPaths {
+		matched := false
+		for _, r := range ordered {
+			if !r.matches(path) {
+				continue
+			}
+			matched = true
+			result.Matches = append(result.Matches, BuildTypeMatch{Path: path, Rule: r.Name, Type: r.Type, Ignored: r.Ignore})
+			if !r.Ignore {
+				result.MatchedTypes = AddIfNotContained(result.MatchedTypes, r.Type)
+			}
+			break
+		}
+		if !matched {
+			result.Unmatched = append(result.Unmatched, path)
+		}
+	}
+
+	result.Types = []string{defaultType}
+	switch {
+	case len(changedPaths) == 0:
+		result.Decision, result.Reason = BuildTypeDecisionNoChanges, "no paths changed"
+	case len(result.MatchedTypes) == 0:
+		result.Decision, result.Reason = BuildTypeDecisionNoTypes, "changed paths matched no build types"
+	case len(result.Unmatched) != 0 && mode != BuildTypeModeAll:
+		result.Decision = BuildTypeDecisionUnmatchedPaths
+		result.Reason = fmt.Sprintf("%d changed path(s) are not matched by any rule (%s mode)", len(result.Unmatched), mode)
+	case len(result.MatchedTypes) > 1 && mode == BuildTypeModeSingle:
+		result.Decision = BuildTypeDecisionMultipleTypes
+		result.Reason = fmt.Sprintf("changed paths matched multiple build types (%s) in %s mode", strings.Join(result.MatchedTypes, ", "), mode)
+	default:
+		result.Types = result.MatchedTypes
+		result.Decision = BuildTypeDecisionMatched
+		result.Reason = fmt.Sprintf("changed paths matched build type(s) %s (%s mode)", strings.Join(result.MatchedTypes, ", "), mode)
+	}
+	return result, nil
+}
+
+// compileGlob compiles a glob to a regular expression matching the whole path.
+// * matches any sequence of characters except /, ? matches a single character except / and ** matches across directories
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob \"%s\": %v", glob, err)
+	}
+	return re, nil
+}
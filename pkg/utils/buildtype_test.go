@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLoadBuildTypeRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		wantRules int
+		wantErr   bool
+	}{
+		{
+			name:      "valid rules",
+			file:      "testdata/buildtype/rules.yaml",
+			wantRules: 5,
+		},
+		{
+			name:    "rule without type",
+			file:    "testdata/buildtype/invalid.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			file:    "testdata/buildtype/missing.yaml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadBuildTypeRules(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadBuildTypeRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidBuildTypeRules) {
+					t.Errorf("LoadBuildTypeRules() error = %v, want %v", err, ErrInvalidBuildTypeRules)
+				}
+				return
+			}
+			if len(got.Rules) != tt.wantRules {
+				t.Errorf("LoadBuildTypeRules() rules = %v, want %v", len(got.Rules), tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestDetermineBuildType(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		changedPaths  []string
+		want          string
+		wantUnmatched []string
+		wantDecision  string
+	}{
+		{
+			name:         "no changed paths",
+			changedPaths: []string{},
+			want:         "full",
+			wantDecision: BuildTypeDecisionNoChanges,
+		},
+		{
+			name:         "single type",
+			changedPaths: []string{"services/api/main.go", "services/api/handlers/users.go"},
+			want:         "api",
+			wantDecision: BuildTypeDecisionMatched,
+		},
+		{
+			name:         "ignored paths do not affect the build type",
+			changedPaths: []string{"services/api/main.go", "README.md", "docs/api/users.md"},
+			want:         "api",
+			wantDecision: BuildTypeDecisionMatched,
+		},
+		{
+			name:         "only ignored paths",
+			changedPaths: []string{"README.md"},
+			want:         "full",
+			wantDecision: BuildTypeDecisionNoTypes,
+		},
+		{
+			name:         "excluded path is matched by a later rule",
+			changedPaths: []string{"services/api/handlers/users_test.go"},
+			want:         "test",
+			wantDecision: BuildTypeDecisionMatched,
+		},
+		{
+			name:         "rule with higher priority is matched first",
+			changedPaths: []string{"services/web/chart/values.yaml"},
+			want:         "chart",
+			wantDecision: BuildTypeDecisionMatched,
+		},
+		{
+			name:         "multiple types in single mode",
+			changedPaths: []string{"services/api/main.go", "services/web/index.js"},
+			want:         "full",
+			wantDecision: BuildTypeDecisionMultipleTypes,
+		},
+		{
+			name:         "multiple types in multiple mode",
+			mode:         BuildTypeModeMultiple,
+			changedPaths: []string{"services/api/main.go", "services/web/index.js"},
+			want:         "api;web",
+			wantDecision: BuildTypeDecisionMatched,
+		},
+		{
+			name:          "unmatched path in multiple mode",
+			mode:          BuildTypeModeMultiple,
+			changedPaths:  []string{"services/api/main.go", "Makefile"},
+			want:          "full",
+			wantUnmatched: []string{"Makefile"},
+			wantDecision:  BuildTypeDecisionUnmatchedPaths,
+		},
+		{
+			name:          "unmatched path in all mode",
+			mode:          BuildTypeModeAll,
+			changedPaths:  []string{"services/web/index.js", "Makefile", "services/api/main.go"},
+			want:          "web;api",
+			wantUnmatched: []string{"Makefile"},
+			wantDecision:  BuildTypeDecisionMatched,
+		},
+		{
+			name:          "only unmatched paths in all mode",
+			mode:          BuildTypeModeAll,
+			changedPaths:  []string{"Makefile"},
+			want:          "full",
+			wantUnmatched: []string{"Makefile"},
+			wantDecision:  BuildTypeDecisionNoTypes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadBuildTypeRules("testdata/buildtype/rules.yaml")
+			if err != nil {
+				t.Fatalf("LoadBuildTypeRules() error = %v", err)
+			}
+			rules.Mode = tt.mode
+			got, err := DetermineBuildType(rules, "default", tt.changedPaths)
+			if err != nil {
+				t.Fatalf("DetermineBuildType() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("DetermineBuildType() = %v, want %v", got, tt.want)
+			}
+			if tt.wantUnmatched == nil {
+				tt.wantUnmatched = []string{}
+			}
+			if !reflect.DeepEqual(got.Unmatched, tt.wantUnmatched) {
+				t.Errorf("DetermineBuildType() unmatched = %v, want %v", got.Unmatched, tt.wantUnmatched)
+			}
+			if got.Decision != tt.wantDecision {
+				t.Errorf("DetermineBuildType() decision = %v (%v), want %v", got.Decision, got.Reason, tt.wantDecision)
+			}
+		})
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{glob: "*.md", path: "README.md", want: true},
+		{glob: "*.md", path: "docs/README.md", want: false},
+		{glob: "**/*.md", path: "README.md", want: true},
+		{glob: "**/*.md", path: "docs/api/README.md", want: true},
+		{glob: "docs/**", path: "docs/api/README.md", want: true},
+		{glob: "docs/**", path: "src/docs/README.md", want: false},
+		{glob: "services/*/chart/**", path: "services/api/chart/Chart.yaml", want: true},
+		{glob: "services/*/chart/**", path: "services/api/v1/chart/Chart.yaml", want: false},
+		{glob: "file?.go", path: "file1.go", want: true},
+		{glob: "file.go", path: "file_go", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			re, err := compileGlob(tt.glob)
+			if err != nil {
+				t.Fatalf("compileGlob() error = %v", err)
+			}
+			if got := re.MatchString(tt.path); got != tt.want {
+				t.Errorf("compileGlob(%s).MatchString(%s) = %v, want %v", tt.glob, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// LatestTag is the value of ChangedPathsOptions.SinceTag to compare with the latest tag reachable from HEAD
+const LatestTag = "latest"
+
+// ChangedPathsOptions are options passed to DetectChangedPaths. One of PreviousCommit, TargetBranch, Range and SinceTag should be set
+type ChangedPathsOptions struct {
+	// RepoPath is a path in the repository (defaults to the current directory)
+	RepoPath string
+	// PreviousCommit is a commit (or any revision) to compare HEAD with
+	PreviousCommit string
+	// TargetBranch is a branch to compare HEAD with the merge-base of, like the target branch of a pull request
+	TargetBranch string
+	// Range is a range of commits: from..to compares to with from, from...to compares to with the merge-base of both.
+	// to defaults to HEAD
+	Range string
+	// SinceTag is a tag to compare HEAD with, or latest to compare HEAD with the latest tag reachable from it
+	SinceTag string
+}
+
+// ChangedPaths are the paths changed between a base commit and a head commit
+type ChangedPaths struct {
+	Paths []string
+	Base  string
+	Head  string
+	// FallbackReason is the reason the changed paths could not be determined, in which case Paths is empty
+	FallbackReason string
+}
+
+// errFallback is returned when changed paths can not be determined, e.g. when objects are missing from a shallow clone
+type errFallback struct {
+	reason string
+}
+
+func (e *errFallback) Error() string {
+	return e.reason
+}
+
+// DetectChangedPaths returns the paths changed between HEAD and a commit determined by the options. When the commit
+// can not be determined (a revision or an object is missing, e.g. in a shallow clone or after a force push),
+// the changed paths are returned with a fallback reason instead of an error
+func DetectChangedPaths(o ChangedPathsOptions) (*ChangedPaths, error) {
+	if o.RepoPath == "" {
+		o.RepoPath = "."
+	}
+	r, err := git.PlainOpenWithOptions(o.RepoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+
+	base, head, err := resolveBaseAndHead(r, o)
+	var fallback *errFallback
+	if errors.As(err, &fallback) {
+		return &ChangedPaths{Paths: []string{}, FallbackReason: fallback.reason}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changed := &ChangedPaths{Paths: []string{}, Base: base.Hash.String(), Head: head.Hash.String()}
+	headTree, err := head.Tree()
+	if err == nil {
+		var baseTree *object.Tree
+		if baseTree, err = base.Tree(); err == nil {
+			changed.Paths, err = diffTrees(headTree, baseTree)
+		}
+	}
+	if err == plumbing.ErrObjectNotFound {
+		changed.FallbackReason = fmt.Sprintf("objects of commit %s or %s are missing%s", base.Hash, head.Hash, shallowHint(r))
+		changed.Paths = []string{}
+		return changed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if changed.Paths == nil {
+		changed.Paths = []string{}
+	}
+	return changed, nil
+}
+
+// resolveBaseAndHead returns the commits to compare by the options
+func resolveBaseAndHead(r *git.Repository, o ChangedPathsOptions) (*object.Commit, *object.Commit, error) {
+	set := 0
+	for _, v := range []string{o.PreviousCommit, o.TargetBranch, o.Range, o.SinceTag} {
+		if v != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return nil, nil, &errFallback{"no previous commit, target branch, range or tag to compare with"}
+	}
+	if set > 1 {
+		return nil, nil, errors.New("only one of previous commit, target branch, range and tag can be set")
+	}
+
+	head, err := resolveCommit(r, "HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case o.PreviousCommit != "":
+		base, err := resolveCommit(r, o.PreviousCommit)
+		return base, head, err
+	case o.TargetBranch != "":
+		target, err := resolveCommit(r, o.TargetBranch)
+		if err != nil {
+			return nil, nil, err
+		}
+		base, err := mergeBase(r, head, target)
+		return base, head, err
+	case o.Range != "":
+		return resolveRange(r, o.Range)
+	default:
+		if o.SinceTag != LatestTag {
+			base, err := resolveCommit(r, o.SinceTag)
+			return base, head, err
+		}
+		base, err := latestTag(r, head)
+		return base, head, err
+	}
+}
+
+// resolveCommit returns the commit of a revision (a hash, branch, remote branch, tag or e.g. HEAD~1)
+func resolveCommit(r *git.Repository, revision string) (*object.Commit, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err == plumbing.ErrReferenceNotFound || err == plumbing.ErrObjectNotFound {
+		return nil, &errFallback{fmt.Sprintf("revision \"%s\" not found%s", revision, shallowHint(r))}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed resolving revision \"%s\": %v", revision, err)
+	}
+	c, err := r.CommitObject(*hash)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, &errFallback{fmt.Sprintf("commit %s of revision \"%s\" not found%s", hash, revision, shallowHint(r))}
+	}
+	return c, err
+}
+
+// resolveRange returns the commits to compare of a from..to or from...to range
+func resolveRange(r *git.Repository, commitRange string) (*object.Commit, *object.Commit, error) {
+	sep := ".."
+	if strings.Contains(commitRange, "...") {
+		sep = "..."
+	}
+	from, to, err := SplitInTwo(commitRange, sep)
+	if err != nil || from == "" {
+		return nil, nil, fmt.Errorf("%w: range \"%s\" should be of the form from..to or from...to", ErrInvalidKeyValue, commitRange)
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	base, err := resolveCommit(r, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	head, err := resolveCommit(r, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sep == "..." {
+		base, err = mergeBase(r, base, head)
+	}
+	return base, head, err
+}
+
+// mergeBase returns the most recent common ancestor of two commits
+func mergeBase(r *git.Repository, a, b *object.Commit) (*object.Commit, error) {
+	ancestorsOfA, missingA, err := ancestors(r, a)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the history of b, most recent commits first, until a commit which is an ancestor of a is found
+	visited := map[plumbing.Hash]bool{b.Hash: true}
+	queue := []*object.Commit{b}
+	missingB := false
+	for len(queue) != 0 {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].Committer.When.After(queue[j].Committer.When)
+		})
+		c := queue[0]
+		queue = queue[1:]
+		if ancestorsOfA[c.Hash] {
+			return c, nil
+		}
+		for _, p := range c.ParentHashes {
+			if visited[p] {
+				continue
+			}
+			visited[p] = true
+			parent, err := r.CommitObject(p)
+			if err == plumbing.ErrObjectNotFound {
+				missingB = true
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, parent)
+		}
+	}
+	if missingA || missingB {
+		return nil, &errFallback{fmt.Sprintf("merge-base of %s and %s not found, history is incomplete%s", a.Hash, b.Hash, shallowHint(r))}
+	}
+	return nil, &errFallback{fmt.Sprintf("commits %s and %s have no common ancestor", a.Hash, b.Hash)}
+}
+
+// ancestors returns the commits reachable from a commit (including it), and whether any of them are missing
+func ancestors(r *git.Repository, c *object.Commit) (map[plumbing.Hash]bool, bool, error) {
+	seen := map[plumbing.Hash]bool{c.Hash: true}
+	queue := []*object.Commit{c}
+	missing := false
+	for len(queue) != 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, p := range c.ParentHashes {
+			if seen[p] {
+				continue
+			}
+			parent, err := r.CommitObject(p)
+			if err == plumbing.ErrObjectNotFound {
+				missing = true
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			seen[p] = true
+			queue = append(queue, parent)
+		}
+	}
+	return seen, missing, nil
+}
+
+// latestTag returns the most recent tagged commit which is reachable from head, excluding head itself
+func latestTag(r *git.Repository, head *object.Commit) (*object.Commit, error) {
+	tagged, err := tagsByCommit(r)
+	if err != nil {
+		return nil, err
+	}
+	var found *object.Commit
+	missing, err := walkHistory(r, head, func(c *object.Commit) bool {
+		if c.Hash != head.Hash && len(tagged[c.Hash]) != 0 {
+			found = c
+			return true
+		}
+		return false
+	})
+	if err != nil || found != nil {
+		return found, err
+	}
+	if missing {
+		return nil, &errFallback{"no tag found in the history of HEAD, history is incomplete" + shallowHint(r)}
+	}
+	return nil, &errFallback{"no tag found in the history of HEAD"}
+}
+
+// tagsByCommit returns the names of the tags of each tagged commit
+func tagsByCommit(r *git.Repository) (map[plumbing.Hash][]string, error) {
+	tags, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+	tagged := map[plumbing.Hash][]string{}
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := r.TagObject(hash); err == nil {
+			c, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+			hash = c.Hash
+		}
+		tagged[hash] = append(tagged[hash], ref.Name().Short())
+		return nil
+	})
+	return tagged, err
+}
+
+// walkHistory walks the history of head, most recent commits first, until visit returns true.
+// It returns true if any commits are missing from the history (e.g. in a shallow clone)
+func walkHistory(r *git.Repository, head *object.Commit, visit func(c *object.Commit) bool) (bool, error) {
+	visited := map[plumbing.Hash]bool{head.Hash: true}
+	queue := []*object.Commit{head}
+	missing := false
+	for len(queue) != 0 {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].Committer.When.After(queue[j].Committer.When)
+		})
+		c := queue[0]
+		queue = queue[1:]
+		if visit(c) {
+			return missing, nil
+		}
+		for _, p := range c.ParentHashes {
+			if visited[p] {
+				continue
+			}
+			visited[p] = true
+			parent, err := r.CommitObject(p)
+			if err == plumbing.ErrObjectNotFound {
+				missing = true
+				continue
+			}
+			if err != nil {
+				return missing, err
+			}
+			queue = append(queue, parent)
+		}
+	}
+	return missing, nil
+}
+
+// shallowHint returns a hint to fetch more history if the repository is a shallow clone
+func shallowHint(r *git.Repository) string {
+	shallow, err := r.Storer.Shallow()
+	if err != nil || len(shallow) == 0 {
+		return ""
+	}
+	return " (the repository is a shallow clone, fetch more history, e.g. git fetch --unshallow)"
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// testRepo is a git repository for change detection tests:
+//
+//	c1 (v1.0.0) - c2 - c4       master
+//	                \
+//	                 c3          feature (HEAD)
+//	fake - c5                    truncated (the parent of c5 is missing, like in a shallow clone)
+type testRepo struct {
+	dir     string
+	repo    *git.Repository
+	commits map[string]plumbing.Hash
+	when    time.Time
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	dir, err := ioutil.TempDir("", "orca-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &testRepo{dir: dir, repo: r, commits: map[string]plumbing.Hash{}, when: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	tr.commit(t, "c1", nil, "README.md", "src/a.go")
+	if _, err := r.CreateTag("v1.0.0", tr.commits["c1"], &git.CreateTagOptions{Tagger: tr.signature(), Message: "v1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	tr.commit(t, "c2", nil, "src/a.go")
+	tr.checkout(t, "feature", true)
+	tr.commit(t, "c3", nil, "chart/Chart.yaml")
+	tr.checkout(t, "master", false)
+	tr.commit(t, "c4", nil, "docs/guide.md")
+	tr.checkout(t, "truncated", true)
+	fake := plumbing.NewHash("0123456789012345678901234567890123456789")
+	tr.commit(t, "c5", []plumbing.Hash{fake}, "src/b.go")
+	if err := r.Storer.SetShallow([]plumbing.Hash{fake}); err != nil {
+		t.Fatal(err)
+	}
+	tr.checkout(t, "feature", false)
+	return tr
+}
+
+func (tr *testRepo) signature() *object.Signature {
+	tr.when = tr.when.Add(time.Hour)
+	return &object.Signature{Name: "orca", Email: "orca@example.com", When: tr.when}
+}
+
+func (tr *testRepo) commit(t *testing.T, name string, parents []plumbing.Hash, files ...string) {
+	w, err := tr.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		path := filepath.Join(tr.dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := w.Commit(name, &git.CommitOptions{Author: tr.signature(), Parents: parents})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.commits[name] = hash
+}
+
+func (tr *testRepo) checkout(t *testing.T, branch string, create bool) {
+	w, err := tr.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Create: create, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectChangedPaths(t *testing.T) {
+	tr := newTestRepo(t)
+	defer os.RemoveAll(tr.dir)
+
+	tests := []struct {
+		name         string
+		options      ChangedPathsOptions
+		wantPaths    []string
+		wantBase     string
+		wantFallback string
+		wantErr      bool
+	}{
+		{
+			name:      "previous commit",
+			options:   ChangedPathsOptions{PreviousCommit: tr.commits["c1"].String()},
+			wantPaths: []string{"chart/Chart.yaml", "src/a.go"},
+			wantBase:  "c1",
+		},
+		{
+			name:      "previous commit by revision",
+			options:   ChangedPathsOptions{PreviousCommit: "HEAD~1"},
+			wantPaths: []string{"chart/Chart.yaml"},
+			wantBase:  "c2",
+		},
+		{
+			name:      "merge-base with target branch",
+			options:   ChangedPathsOptions{TargetBranch: "master"},
+			wantPaths: []string{"chart/Chart.yaml"},
+			wantBase:  "c2",
+		},
+		{
+			name:      "range",
+			options:   ChangedPathsOptions{Range: "master..feature"},
+			wantPaths: []string{"chart/Chart.yaml", "docs/guide.md"},
+			wantBase:  "c4",
+		},
+		{
+			name:      "range from merge-base",
+			options:   ChangedPathsOptions{Range: "master...feature"},
+			wantPaths: []string{"chart/Chart.yaml"},
+			wantBase:  "c2",
+		},
+		{
+			name:      "range to HEAD",
+			options:   ChangedPathsOptions{Range: "v1.0.0.."},
+			wantPaths: []string{"chart/Chart.yaml", "src/a.go"},
+			wantBase:  "c1",
+		},
+		{
+			name:      "since tag",
+			options:   ChangedPathsOptions{SinceTag: "v1.0.0"},
+			wantPaths: []string{"chart/Chart.yaml", "src/a.go"},
+			wantBase:  "c1",
+		},
+		{
+			name:      "since latest tag",
+			options:   ChangedPathsOptions{SinceTag: LatestTag},
+			wantPaths: []string{"chart/Chart.yaml", "src/a.go"},
+			wantBase:  "c1",
+		},
+		{
+			name:         "missing previous commit",
+			options:      ChangedPathsOptions{PreviousCommit: "9876543210987654321098765432109876543210"},
+			wantFallback: "revision \"9876543210987654321098765432109876543210\" not found",
+		},
+		{
+			name:         "missing target branch",
+			options:      ChangedPathsOptions{TargetBranch: "origin/master"},
+			wantFallback: "revision \"origin/master\" not found",
+		},
+		{
+			name:         "missing history of shallow clone",
+			options:      ChangedPathsOptions{Range: "master...truncated"},
+			wantFallback: "history is incomplete",
+		},
+		{
+			name:         "nothing to compare with",
+			options:      ChangedPathsOptions{},
+			wantFallback: "no previous commit, target branch, range or tag to compare with",
+		},
+		{
+			name:    "invalid range",
+			options: ChangedPathsOptions{Range: "master"},
+			wantErr: true,
+		},
+		{
+			name:    "multiple options",
+			options: ChangedPathsOptions{PreviousCommit: "HEAD~1", SinceTag: LatestTag},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.RepoPath = filepath.Join(tr.dir, "src")
+			got, err := DetectChangedPaths(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectChangedPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantFallback != "" {
+				if !strings.Contains(got.FallbackReason, tt.wantFallback) {
+					t.Errorf("DetectChangedPaths() fallback reason = %v, want %v", got.FallbackReason, tt.wantFallback)
+				}
+				if len(got.Paths) != 0 {
+					t.Errorf("DetectChangedPaths() paths = %v, want none", got.Paths)
+				}
+				return
+			}
+			if got.FallbackReason != "" {
+				t.Fatalf("DetectChangedPaths() fallback reason = %v", got.FallbackReason)
+			}
+			sort.Strings(got.Paths)
+			if !reflect.DeepEqual(got.Paths, tt.wantPaths) {
+				t.Errorf("DetectChangedPaths() paths = %v, want %v", got.Paths, tt.wantPaths)
+			}
+			if got.Base != tr.commits[tt.wantBase].String() {
+				t.Errorf("DetectChangedPaths() base = %v, want %v (%v)", got.Base, tr.commits[tt.wantBase], tt.wantBase)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package utils
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"sort"
 	"strings"
 
@@ -51,21 +50,24 @@ func GetReleasesDelta(fromReleases, toReleases []ReleaseSpec) []ReleaseSpec {
 }
 
 // InitReleasesFromChartsFile initializes a slice of ReleaseSpec from a yaml formatted charts file
-func InitReleasesFromChartsFile(file, env string) []ReleaseSpec {
+func InitReleasesFromChartsFile(file, env string) ([]ReleaseSpec, error) {
 	var releases []ReleaseSpec
 
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChartsFile, err)
 	}
 
 	v := ChartsFile{}
 	err = yaml.Unmarshal(data, &v)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidChartsFile, file, err)
 	}
 
-	for _, chart := range v.Releases {
+	for i, chart := range v.Releases {
+		if chart.ChartName == "" || chart.ChartVersion == "" {
+			return nil, fmt.Errorf("%w: %s: chart at index %d must have a name and a version", ErrInvalidChartsFile, file, i)
+		}
 
 		c := ReleaseSpec{
 			ReleaseName:  env + "-" + chart.ChartName,
@@ -81,15 +83,18 @@ func InitReleasesFromChartsFile(file, env string) []ReleaseSpec {
 		releases = append(releases, c)
 	}
 
-	return releases
+	return releases, nil
 }
 
 // InitReleases initializes a slice of ReleaseSpec from a string slice
-func InitReleases(env string, releases []string) []ReleaseSpec {
+func InitReleases(env string, releases []string) ([]ReleaseSpec, error) {
 	var outReleases []ReleaseSpec
 
 	for _, release := range releases {
-		chartName, chartVersion := SplitInTwo(release, "=")
+		chartName, chartVersion, err := SplitInTwo(release, "=")
+		if err != nil {
+			return nil, err
+		}
 
 		r := ReleaseSpec{
 			ReleaseName:  env + "-" + chartName,
@@ -100,7 +105,7 @@ func InitReleases(env string, releases []string) []ReleaseSpec {
 		outReleases = append(outReleases, r)
 	}
 
-	return outReleases
+	return outReleases, nil
 }
 
 // CheckCircularDependencies verifies that there are no circular dependencies between ReleaseSpecs
@@ -136,17 +141,22 @@ func CheckCircularDependencies(releases []ReleaseSpec) bool {
 }
 
 // OverrideReleases overrides versions of specified overrides
-func OverrideReleases(releases []ReleaseSpec, overrides []string, env string) []ReleaseSpec {
+func OverrideReleases(releases []ReleaseSpec, overrides []string, env string) ([]ReleaseSpec, error) {
 	if len(overrides) == 0 {
-		return releases
+		return releases, nil
+	}
+
+	overrideReleases, err := InitReleases(env, overrides)
+	if err != nil {
+		return nil, err
 	}
 
 	var outReleases []ReleaseSpec
 	var overrideFound = make([]bool, len(overrides))
 
 	for _, r := range releases {
-		for i := 0; i < len(overrides); i++ {
-			oChartName, oChartVersion := SplitInTwo(overrides[i], "=")
+		for i := 0; i < len(overrideReleases); i++ {
+			oChartName, oChartVersion := overrideReleases[i].ChartName, overrideReleases[i].ChartVersion
 
 			if r.ChartName == oChartName && r.ChartVersion != oChartVersion {
 				overrideFound[i] = true
@@ -157,20 +167,14 @@ func OverrideReleases(releases []ReleaseSpec, overrides []string, env string) []
 		outReleases = append(outReleases, r)
 	}
 
-	for i := 0; i < len(overrides); i++ {
+	for i := 0; i < len(overrideReleases); i++ {
 		if overrideFound[i] {
 			continue
 		}
-		oChartName, oChartVersion := SplitInTwo(overrides[i], "=")
-		r := ReleaseSpec{
-			ReleaseName:  env + "-" + oChartName,
-			ChartName:    oChartName,
-			ChartVersion: oChartVersion,
-		}
-		outReleases = append(outReleases, r)
+		outReleases = append(outReleases, overrideReleases[i])
 	}
 
-	return outReleases
+	return outReleases, nil
 }
 
 // RemoveChartFromDependencies removes a release from other releases ReleaseSpec depends_on field
@@ -181,15 +185,13 @@ func RemoveChartFromDependencies(charts []ReleaseSpec, name string) []ReleaseSpe
 	for _, dependant := range charts {
 		if Contains(dependant.Dependencies, name) {
 
+			// The element is contained, so it is always found
 			index := -1
 			for i, elem := range dependant.Dependencies {
 				if elem == name {
 					index = i
 				}
 			}
-			if index == -1 {
-				log.Fatal("Could not find element in dependencies")
-			}
 
 			dependant.Dependencies[index] = dependant.Dependencies[len(dependant.Dependencies)-1]
 			dependant.Dependencies[len(dependant.Dependencies)-1] = ""
@@ -219,56 +221,21 @@ func RemoveChartFromCharts(charts []ReleaseSpec, index int) []ReleaseSpec {
 }
 
 // UpdateChartVersion updates a chart version with desired append value
-func UpdateChartVersion(path, append string) string {
-	filePath := path + "Chart.yaml"
-	data, err := ioutil.ReadFile(filePath)
+func UpdateChartVersion(path, append string) (string, error) {
+	version, err := GetChartVersion(path)
 	if err != nil {
-		log.Fatalln(err)
+		return "", err
 	}
-
-	var v map[string]interface{}
-	err = yaml.Unmarshal(data, &v)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	version := v["version"].(string)
 	if append == "" {
-		return version
-	}
-	newVersion := fmt.Sprintf("%s-%s", version, append)
-	v["version"] = newVersion
-
-	data, err = yaml.Marshal(v)
-	if err != nil {
-		log.Fatalln(err)
+		return version, nil
 	}
-	ioutil.WriteFile(filePath, data, 0755)
-
-	return newVersion
+	return EditChart(path, ChartEdit{Version: fmt.Sprintf("%s-%s", version, append)})
 }
 
 // ResetChartVersion resets a chart version to a desired value
-func ResetChartVersion(path, version string) {
-	filePath := path + "Chart.yaml"
-	data, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	var v map[string]interface{}
-	err = yaml.Unmarshal(data, &v)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	v["version"] = version
-
-	data, err = yaml.Marshal(v)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	ioutil.WriteFile(filePath, data, 0755)
+func ResetChartVersion(path, version string) error {
+	_, err := EditChart(path, ChartEdit{Version: version})
+	return err
 }
 
 // Print prints a ReleaseSpec
@@ -342,14 +309,14 @@ type diff struct {
 	versionRight string
 }
 
-// PrintDiff prints a table of differences between two environments
-func PrintDiff(o DiffOptions) {
+// PrintDiff prints a table of differences between two environments and reports whether there are any
+func PrintDiff(o DiffOptions) bool {
 	if len(o.ReleasesSpecLeft) == 0 && len(o.ReleasesSpecRight) == 0 {
-		return
+		return false
 	}
 	diffs := getDiffs(o.ReleasesSpecLeft, o.ReleasesSpecRight)
 	if len(diffs) == 0 {
-		return
+		return false
 	}
 
 	switch o.Output {
@@ -361,6 +328,7 @@ func PrintDiff(o DiffOptions) {
 		printDiffYaml(diffs)
 	}
 
+	return true
 }
 
 func printDiffYaml(diffs []diff) {
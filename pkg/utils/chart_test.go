@@ -1,9 +1,19 @@
 package utils
 
 import (
+	"errors"
 	"testing"
 )
 
+// releasesFromChartsFile initializes releases from a test charts file
+func releasesFromChartsFile(file, env string) []ReleaseSpec {
+	releases, err := InitReleasesFromChartsFile(file, env)
+	if err != nil {
+		panic(err)
+	}
+	return releases
+}
+
 func TestCheckCircularDependencies(t *testing.T) {
 	type args struct {
 		releases []ReleaseSpec
@@ -15,12 +25,12 @@ func TestCheckCircularDependencies(t *testing.T) {
 	}{
 		{
 			name: "no circular dependencies",
-			args: args{InitReleasesFromChartsFile("./testdata/charts.yaml", "test")},
+			args: args{releasesFromChartsFile("./testdata/charts.yaml", "test")},
 			want: false,
 		},
 		{
 			name: "circular dependencies",
-			args: args{InitReleasesFromChartsFile("./testdata/circular.yaml", "test")},
+			args: args{releasesFromChartsFile("./testdata/circular.yaml", "test")},
 			want: true,
 		},
 	}
@@ -45,12 +55,12 @@ func TestGetChartIndex(t *testing.T) {
 	}{
 		{
 			name: "charts file has this chart",
-			args: args{InitReleasesFromChartsFile("./testdata/charts.yaml", "test"), "kaa"},
+			args: args{releasesFromChartsFile("./testdata/charts.yaml", "test"), "kaa"},
 			want: 2,
 		},
 		{
 			name: "charts file doesn't have this chart",
-			args: args{InitReleasesFromChartsFile("./testdata/charts.yaml", "test"), "rabbitmq"},
+			args: args{releasesFromChartsFile("./testdata/charts.yaml", "test"), "rabbitmq"},
 			want: -1,
 		},
 	}
@@ -86,7 +96,10 @@ func TestChartsYamlToReleases(t *testing.T) {
 	rel1 := ReleaseSpec{ChartName: "mariadb", ChartVersion: "0.5.4", ReleaseName: "test-mariadb"}
 	rel2 := ReleaseSpec{ChartName: "kaa", ChartVersion: "0.1.7", ReleaseName: "test-kaa"}
 
-	releases := InitReleasesFromChartsFile("testdata/charts.yaml", "test")
+	releases, err := InitReleasesFromChartsFile("testdata/charts.yaml", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if len(releases) != 3 {
 		t.Errorf("Expected: 3, Actual: " + (string)(len(releases)))
@@ -145,3 +158,27 @@ func TestReleaseSpec_Equals(t *testing.T) {
 		})
 	}
 }
+
+func TestInitReleasesFromChartsFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{
+			name: "charts file does not exist",
+			file: "./testdata/missing.yaml",
+		},
+		{
+			name: "chart without version",
+			file: "./testdata/invalid.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := InitReleasesFromChartsFile(tt.file, "test")
+			if !errors.Is(err, ErrInvalidChartsFile) {
+				t.Errorf("InitReleasesFromChartsFile() error = %v, want %v", err, ErrInvalidChartsFile)
+			}
+		})
+	}
+}
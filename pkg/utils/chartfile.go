@@ -0,0 +1,384 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ChartEdit are edits of a chart's metadata passed to EditChart. Empty fields are not edited
+type ChartEdit struct {
+	Version    string
+	AppVersion string
+	// Dependencies are versions of dependencies by name, edited in requirements.yaml or in the dependencies of Chart.yaml
+	Dependencies map[string]string
+	// Annotations are set in the annotations of Chart.yaml
+	Annotations map[string]string
+}
+
+// chartMetadata is the part of Chart.yaml and requirements.yaml which is edited by EditChart
+type chartMetadata struct {
+	Version      string            `yaml:"version"`
+	AppVersion   string            `yaml:"appVersion"`
+	Annotations  map[string]string `yaml:"annotations"`
+	Dependencies []struct {
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+	} `yaml:"dependencies"`
+}
+
+// yamlKeyPattern matches a mapping entry: indentation, an optional list item dash, the key, the value and a comment
+var yamlKeyPattern = regexp.MustCompile(`^(\s*)(- +)?("[^"]*"|'[^']*'|[^\s#'"][^:#]*?):(?:\s+|$)(.*?)(\s+#.*)?$`)
+
+// EditChart edits the metadata of a chart in place and returns its version. Only the edited values are changed,
+// the order of keys, comments and formatting of Chart.yaml and requirements.yaml are preserved
+func EditChart(path string, e ChartEdit) (string, error) {
+	chartFile := filepath.Join(path, "Chart.yaml")
+	chart, err := readYAMLLines(chartFile)
+	if err != nil {
+		return "", err
+	}
+	if e.Version != "" {
+		chart.set(e.Version, "version")
+	}
+	if e.AppVersion != "" {
+		chart.set(e.AppVersion, "appVersion")
+	}
+	for _, k := range sortedKeys(e.Annotations) {
+		chart.set(e.Annotations[k], "annotations", k)
+	}
+
+	// Dependencies are edited in requirements.yaml if it exists, in Chart.yaml otherwise
+	deps := chart
+	if len(e.Dependencies) != 0 {
+		requirementsFile := filepath.Join(path, "requirements.yaml")
+		if _, err := os.Stat(requirementsFile); err == nil {
+			if deps, err = readYAMLLines(requirementsFile); err != nil {
+				return "", err
+			}
+		}
+		for _, name := range sortedKeys(e.Dependencies) {
+			if !deps.setDependencyVersion(name, e.Dependencies[name]) {
+				return "", fmt.Errorf("%w: %s: dependency \"%s\" not found", ErrInvalidChart, deps.file, name)
+			}
+		}
+	}
+
+	// Verify the edits before writing, to not corrupt files the line based editing does not support
+	metadata, err := chart.verify(e)
+	if err != nil {
+		return "", err
+	}
+	if deps != chart {
+		if _, err := deps.verify(ChartEdit{Dependencies: e.Dependencies}); err != nil {
+			return "", err
+		}
+		if err := deps.write(); err != nil {
+			return "", err
+		}
+	}
+	if err := chart.write(); err != nil {
+		return "", err
+	}
+	if metadata.Version == "" {
+		return "", fmt.Errorf("%w: %s: version is missing", ErrInvalidChart, chartFile)
+	}
+	return metadata.Version, nil
+}
+
+// GetChartVersion returns the version of a chart
+func GetChartVersion(path string) (string, error) {
+	chartFile := filepath.Join(path, "Chart.yaml")
+	data, err := ioutil.ReadFile(chartFile)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	var v chartMetadata
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrInvalidChart, chartFile, err)
+	}
+	if v.Version == "" {
+		return "", fmt.Errorf("%w: %s: version is missing", ErrInvalidChart, chartFile)
+	}
+	return v.Version, nil
+}
+
+// yamlLines is a YAML file which is edited line by line
+type yamlLines struct {
+	file  string
+	lines []string
+	crlf  bool
+}
+
+// readYAMLLines reads a YAML file to edit
+func readYAMLLines(file string) (*yamlLines, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	var v chartMetadata
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidChart, file, err)
+	}
+	content := string(data)
+	y := &yamlLines{file: file, crlf: strings.Contains(content, "\r\n")}
+	content = strings.TrimSuffix(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	if content != "" {
+		y.lines = strings.Split(content, "\n")
+	}
+	return y, nil
+}
+
+// write writes the edited YAML file
+func (y *yamlLines) write() error {
+	newline := "\n"
+	if y.crlf {
+		newline = "\r\n"
+	}
+	return ioutil.WriteFile(y.file, []byte(strings.Join(y.lines, newline)+newline), 0644)
+}
+
+// verify returns the metadata of the edited file, or an error if the edits are not reflected in it
+func (y *yamlLines) verify(e ChartEdit) (*chartMetadata, error) {
+	var v chartMetadata
+	if err := yaml.Unmarshal([]byte(strings.Join(y.lines, "\n")), &v); err != nil {
+		return nil, fmt.Errorf("%w: %s: failed editing: %v", ErrInvalidChart, y.file, err)
+	}
+	mismatch := (e.Version != "" && v.Version != e.Version) || (e.AppVersion != "" && v.AppVersion != e.AppVersion)
+	for k, value := range e.Annotations {
+		mismatch = mismatch || v.Annotations[k] != value
+	}
+	for name, version := range e.Dependencies {
+		for _, d := range v.Dependencies {
+			mismatch = mismatch || (d.Name == name && d.Version != version)
+		}
+	}
+	if mismatch {
+		return nil, fmt.Errorf("%w: %s: failed editing, unsupported formatting", ErrInvalidChart, y.file)
+	}
+	return &v, nil
+}
+
+// entry is a mapping entry in a YAML line
+type entry struct {
+	// prefix is the line up to the colon after the key
+	prefix string
+	// indent is the indentation of the key, including the dash of a list item
+	indent  int
+	item    bool
+	key     string
+	value   string
+	comment string
+}
+
+// parse parses the mapping entry of a line, it returns false for lines which are not mapping entries
+func (y *yamlLines) parse(i int) (entry, bool) {
+	if !y.isContent(i) {
+		return entry{}, false
+	}
+	m := yamlKeyPattern.FindStringSubmatch(y.lines[i])
+	if m == nil {
+		return entry{}, false
+	}
+	return entry{
+		prefix:  m[1] + m[2] + m[3] + ":",
+		indent:  len(m[1]) + len(m[2]),
+		item:    m[2] != "",
+		key:     strings.Trim(m[3], `"'`),
+		value:   strings.TrimSpace(m[4]),
+		comment: m[5],
+	}, true
+}
+
+// setValue replaces the value of the mapping entry in a line, keeping its comment
+func (y *yamlLines) setValue(i int, e entry, value string) {
+	y.lines[i] = e.prefix + " " + formatYAMLValue(value, e.value) + e.comment
+}
+
+// isContent returns true if a line is not empty and not a comment
+func (y *yamlLines) isContent(i int) bool {
+	trimmed := strings.TrimSpace(y.lines[i])
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+// indentOf returns the indentation of a line
+func (y *yamlLines) indentOf(i int) int {
+	return len(y.lines[i]) - len(strings.TrimLeft(y.lines[i], " "))
+}
+
+// blockEnd returns the index after the last line of the value of the mapping entry in a line: the following lines
+// which are indented more than it, or list items which are indented the same
+func (y *yamlLines) blockEnd(start int) int {
+	indent := y.indentOf(start)
+	end := start + 1
+	for i := start + 1; i < len(y.lines); i++ {
+		if !y.isContent(i) {
+			continue
+		}
+		if y.indentOf(i) < indent || (y.indentOf(i) == indent && !strings.HasPrefix(strings.TrimSpace(y.lines[i]), "- ")) {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// lastContent returns the index after the last content line in a range, or start if there is none
+func (y *yamlLines) lastContent(start, end int) int {
+	for i := end - 1; i >= start; i-- {
+		if y.isContent(i) {
+			return i + 1
+		}
+	}
+	return start
+}
+
+// insert inserts lines at an index
+func (y *yamlLines) insert(at int, lines ...string) {
+	y.lines = append(y.lines[:at], append(lines, y.lines[at:]...)...)
+}
+
+// set sets the value of a key in a mapping of the file. Keys are a path in nested mappings (e.g. annotations, key).
+// Missing keys are added at the end of their mapping
+func (y *yamlLines) set(value string, keys ...string) {
+	start, end, indent := 0, len(y.lines), 0
+	for depth, key := range keys {
+		// The entries of the mapping are the lines with the indentation of its first entry
+		childIndent := -1
+		found := -1
+		for i := start; i < end && found == -1; i++ {
+			if !y.isContent(i) {
+				continue
+			}
+			if childIndent == -1 {
+				childIndent = y.indentOf(i)
+			}
+			if e, ok := y.parse(i); ok && !e.item && e.indent == childIndent && e.key == key {
+				found = i
+			}
+		}
+		if childIndent == -1 {
+			childIndent = indent
+		}
+
+		if found == -1 {
+			lines := []string{}
+			for j, k := range keys[depth:] {
+				line := strings.Repeat(" ", childIndent+2*j) + formatYAMLKey(k) + ":"
+				if depth+j == len(keys)-1 {
+					line += " " + formatYAMLValue(value, "")
+				}
+				lines = append(lines, line)
+			}
+			y.insert(y.lastContent(start, end), lines...)
+			return
+		}
+
+		e, _ := y.parse(found)
+		if depth == len(keys)-1 {
+			y.setValue(found, e, value)
+			return
+		}
+		switch e.value {
+		case "":
+		case "{}":
+			// An empty flow mapping is replaced by a block mapping
+			y.lines[found] = e.prefix + e.comment
+		default:
+			// Not a block mapping, the edit fails verification
+			return
+		}
+		start, end, indent = found+1, y.blockEnd(found), childIndent+2
+	}
+}
+
+// setDependencyVersion sets the version of a dependency in the dependencies list of the file,
+// it returns false if the dependency is not found
+func (y *yamlLines) setDependencyVersion(name, version string) bool {
+	deps := -1
+	for i := range y.lines {
+		if e, ok := y.parse(i); ok && e.indent == 0 && e.key == "dependencies" {
+			deps = i
+			break
+		}
+	}
+	if deps == -1 {
+		return false
+	}
+	end := y.blockEnd(deps)
+
+	for i := deps + 1; i < end; i++ {
+		first, ok := y.parse(i)
+		if !ok || !first.item {
+			continue
+		}
+		// The entries of a list item are indented like its first entry, until the next item
+		itemEnd := end
+		for j := i + 1; j < end; j++ {
+			if e, ok := y.parse(j); ok && e.item && e.indent == first.indent {
+				itemEnd = j
+				break
+			}
+		}
+		matched, versionLine := false, -1
+		for j := i; j < itemEnd; j++ {
+			if e, ok := y.parse(j); ok && e.indent == first.indent {
+				switch e.key {
+				case "name":
+					matched = strings.Trim(e.value, `"'`) == name
+				case "version":
+					versionLine = j
+				}
+			}
+		}
+		if !matched {
+			i = itemEnd - 1
+			continue
+		}
+		if versionLine == -1 {
+			y.insert(y.lastContent(i, itemEnd), strings.Repeat(" ", first.indent)+"version: "+formatYAMLValue(version, ""))
+			return true
+		}
+		e, _ := y.parse(versionLine)
+		y.setValue(versionLine, e, version)
+		return true
+	}
+	return false
+}
+
+// formatYAMLKey returns a key as a YAML scalar
+func formatYAMLKey(key string) string {
+	return formatYAMLValue(key, "")
+}
+
+// formatYAMLValue returns a string as a YAML scalar, in the quoting style of the previous value if it was quoted
+func formatYAMLValue(value, previous string) string {
+	previous = strings.TrimSpace(previous)
+	switch {
+	case strings.HasPrefix(previous, `"`):
+		return fmt.Sprintf("%q", value)
+	case strings.HasPrefix(previous, "'"):
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%q", value)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditChart(t *testing.T) {
+	chart := `# The chart of the api service
+apiVersion: v1
+name: api
+version: 1.2.3 # bumped by CI
+appVersion: "1.0"
+description: The api service
+`
+	tests := []struct {
+		name             string
+		chart            string
+		requirements     string
+		edit             ChartEdit
+		wantVersion      string
+		wantChart        string
+		wantRequirements string
+		wantErr          bool
+	}{
+		{
+			name:        "version and appVersion",
+			chart:       chart,
+			edit:        ChartEdit{Version: "1.3.0", AppVersion: "abc1234"},
+			wantVersion: "1.3.0",
+			wantChart: `# The chart of the api service
+apiVersion: v1
+name: api
+version: 1.3.0 # bumped by CI
+appVersion: "abc1234"
+description: The api service
+`,
+		},
+		{
+			name:        "missing appVersion and annotations are added",
+			chart:       "name: api\nversion: 1.2.3\r\n",
+			edit:        ChartEdit{AppVersion: "1.0", Annotations: map[string]string{"orca.nuvocares.com/commit": "abc1234"}},
+			wantVersion: "1.2.3",
+			wantChart:   "name: api\r\nversion: 1.2.3\r\nappVersion: \"1.0\"\r\nannotations:\r\n  orca.nuvocares.com/commit: abc1234\r\n",
+		},
+		{
+			name: "annotations",
+			chart: `name: api
+version: 1.2.3
+annotations:
+    # set by the team
+    team: core
+    "build": "17"
+sources:
+- https://github.com/nuvo/api
+`,
+			edit:        ChartEdit{Annotations: map[string]string{"build": "18", "commit": "abc1234"}},
+			wantVersion: "1.2.3",
+			wantChart: `name: api
+version: 1.2.3
+annotations:
+    # set by the team
+    team: core
+    "build": "18"
+    commit: abc1234
+sources:
+- https://github.com/nuvo/api
+`,
+		},
+		{
+			name:        "empty annotations",
+			chart:       "name: api\nannotations: {}\nversion: 1.2.3\n",
+			edit:        ChartEdit{Annotations: map[string]string{"commit": "abc1234"}},
+			wantVersion: "1.2.3",
+			wantChart:   "name: api\nannotations:\n  commit: abc1234\nversion: 1.2.3\n",
+		},
+		{
+			name:  "dependencies in requirements.yaml",
+			chart: chart,
+			requirements: `dependencies:
+- name: common
+  version: 0.1.0
+  repository: "@nuvo"
+# the database
+- repository: "@stable"
+  name: mariadb
+  condition: mariadb.enabled
+`,
+			edit:        ChartEdit{Dependencies: map[string]string{"common": "0.2.0", "mariadb": "5.2.3"}},
+			wantVersion: "1.2.3",
+			wantChart:   chart,
+			wantRequirements: `dependencies:
+- name: common
+  version: 0.2.0
+  repository: "@nuvo"
+# the database
+- repository: "@stable"
+  name: mariadb
+  condition: mariadb.enabled
+  version: 5.2.3
+`,
+		},
+		{
+			name: "dependencies in Chart.yaml",
+			chart: `apiVersion: v2
+name: api
+version: 1.2.3
+dependencies:
+  - name: common
+    version: '0.1.0'
+    repository: "@nuvo"
+`,
+			edit:        ChartEdit{Dependencies: map[string]string{"common": "0.2.0"}},
+			wantVersion: "1.2.3",
+			wantChart: `apiVersion: v2
+name: api
+version: 1.2.3
+dependencies:
+  - name: common
+    version: '0.2.0'
+    repository: "@nuvo"
+`,
+		},
+		{
+			name:    "missing dependency",
+			chart:   chart,
+			edit:    ChartEdit{Dependencies: map[string]string{"redis": "1.0.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported formatting",
+			chart:   "name: api\nversion: 1.2.3\nannotations: {team: core}\n",
+			edit:    ChartEdit{Annotations: map[string]string{"commit": "abc1234"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "orca-chart")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(tt.chart), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.requirements != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, "requirements.yaml"), []byte(tt.requirements), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := EditChart(dir, tt.edit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EditChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "Chart.yaml"))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidChart) {
+					t.Errorf("EditChart() error = %v, want %v", err, ErrInvalidChart)
+				}
+				if string(data) != tt.chart {
+					t.Errorf("EditChart() modified Chart.yaml on error: %q", data)
+				}
+				return
+			}
+			if got != tt.wantVersion {
+				t.Errorf("EditChart() = %v, want %v", got, tt.wantVersion)
+			}
+			if string(data) != tt.wantChart {
+				t.Errorf("EditChart() Chart.yaml = %q, want %q", data, tt.wantChart)
+			}
+			if tt.requirements != "" {
+				data, _ := ioutil.ReadFile(filepath.Join(dir, "requirements.yaml"))
+				if string(data) != tt.wantRequirements {
+					t.Errorf("EditChart() requirements.yaml = %q, want %q", data, tt.wantRequirements)
+				}
+			}
+		})
+	}
+}
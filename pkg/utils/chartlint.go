@@ -0,0 +1,369 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/lint"
+	"k8s.io/helm/pkg/lint/support"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/releaseutil"
+	"k8s.io/helm/pkg/renderutil"
+)
+
+// Chart policies, which are checked by LintChart
+const (
+	// PolicySemverVersion requires the version in Chart.yaml to be a valid semantic version
+	PolicySemverVersion = "semver-version"
+	// PolicyRequiredLabels requires rendered objects to have the required labels
+	PolicyRequiredLabels = "labels"
+	// PolicyImageTag requires container images to have a tag which is not latest (or a digest)
+	PolicyImageTag = "image-tag"
+	// PolicyResources requires containers to have resource requests and limits
+	PolicyResources = "resources"
+	// PolicyProbes requires containers of long running workloads to have readiness and liveness probes
+	PolicyProbes = "probes"
+)
+
+// ChartPolicies are all the chart policies, in the order they are checked
+var ChartPolicies = []string{PolicySemverVersion, PolicyRequiredLabels, PolicyImageTag, PolicyResources, PolicyProbes}
+
+// Checks of a ChartLintResult which are not policies
+const (
+	// PolicyHelmLint holds the messages of helm lint
+	PolicyHelmLint = "helm-lint"
+	// PolicyRender holds the error of rendering the templates, in which case no policy is checked
+	PolicyRender = "render"
+)
+
+// Severities of lint findings
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityInfo    = "info"
+)
+
+// semverPattern matches a semantic version (https://semver.org)
+var semverPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// lintReleaseName is the name of the release charts are rendered with
+const lintReleaseName = "RELEASE-NAME"
+
+// PolicyOptions configure the policies checked by LintChart
+type PolicyOptions struct {
+	// RequiredLabels are labels every rendered object should have
+	RequiredLabels []string
+	// Skip are policies which are not checked
+	Skip []string
+}
+
+// LintChartOptions are options passed to LintChart
+type LintChartOptions struct {
+	Path        string
+	ValuesFiles []string
+	Set         []string
+	Policies    PolicyOptions
+	// HelmLint adds the messages of helm lint to the result
+	HelmLint bool
+}
+
+// LintFinding is a violation of a policy (or a message of helm lint)
+type LintFinding struct {
+	Policy   string `json:"policy"`
+	Severity string `json:"severity"`
+	// Object is the object which violates the policy (Kind/name), if any
+	Object string `json:"object,omitempty"`
+	// Template is the template the object was rendered from, if any
+	Template string `json:"template,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ChartLintResult is the result of LintChart
+type ChartLintResult struct {
+	Chart   string `json:"chart"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	// Policies are the checks which were run: helm-lint (if helm lint was run), render and the checked policies
+	Policies []string      `json:"policies"`
+	Findings []LintFinding `json:"findings"`
+}
+
+// Passed returns true if there are no findings with an error severity
+func (r *ChartLintResult) Passed() bool {
+	return r.Errors() == 0
+}
+
+// Errors returns the number of findings with an error severity
+func (r *ChartLintResult) Errors() int {
+	errors := 0
+	for _, f := range r.Findings {
+		if f.Severity == LintSeverityError {
+			errors++
+		}
+	}
+	return errors
+}
+
+// Err returns an error which summarizes the findings with an error severity, or nil if the chart passed
+func (r *ChartLintResult) Err() error {
+	if r.Passed() {
+		return nil
+	}
+	msgs := []string{fmt.Sprintf("%s: chart %s version %s has %d errors:", ErrLintFailed, r.Chart, r.Version, r.Errors())}
+	for _, f := range r.Findings {
+		if f.Severity != LintSeverityError {
+			continue
+		}
+		msg := f.Policy + ": "
+		if f.Object != "" {
+			msg += f.Object + ": "
+		}
+		msgs = append(msgs, "    "+msg+f.Message)
+	}
+	return &lintError{msg: strings.Join(msgs, "\n")}
+}
+
+// lintError is returned when a chart failed lint
+type lintError struct {
+	msg string
+}
+
+func (e *lintError) Error() string {
+	return e.msg
+}
+
+func (e *lintError) Unwrap() error {
+	return ErrLintFailed
+}
+
+// renderedObject is the part of a rendered object which is checked by the policies
+type renderedObject struct {
+	Kind     string            `json:"kind"`
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		// Containers and InitContainers are set in Pods
+		Containers     []v1.Container `json:"containers"`
+		InitContainers []v1.Container `json:"initContainers"`
+		// Template is set in Deployments, StatefulSets, DaemonSets, ReplicaSets and Jobs
+		Template *v1.PodTemplateSpec `json:"template"`
+		// JobTemplate is set in CronJobs
+		JobTemplate *struct {
+			Spec struct {
+				Template v1.PodTemplateSpec `json:"template"`
+			} `json:"spec"`
+		} `json:"jobTemplate"`
+	} `json:"spec"`
+
+	template string
+}
+
+// podSpec returns the containers and init containers of a workload, and whether it is a workload
+func (o *renderedObject) podSpec() ([]v1.Container, []v1.Container, bool) {
+	switch {
+	case o.Kind == "Pod":
+		return o.Spec.Containers, o.Spec.InitContainers, true
+	case o.Spec.Template != nil:
+		return o.Spec.Template.Spec.Containers, o.Spec.Template.Spec.InitContainers, true
+	case o.Spec.JobTemplate != nil:
+		return o.Spec.JobTemplate.Spec.Template.Spec.Containers, o.Spec.JobTemplate.Spec.Template.Spec.InitContainers, true
+	}
+	return nil, nil, false
+}
+
+// isLongRunning returns true if an object runs its containers until they are removed
+func (o *renderedObject) isLongRunning() bool {
+	return Contains([]string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet"}, o.Kind)
+}
+
+// LintChart renders the templates of a chart with values files and checks the chart and the rendered objects against policies
+func LintChart(o LintChartOptions) (*ChartLintResult, error) {
+	c, err := chartutil.LoadDir(o.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	values, err := mergeValues(o.ValuesFiles, o.Set)
+	if err != nil {
+		return nil, err
+	}
+	result := &ChartLintResult{
+		Chart:    c.Metadata.Name,
+		Version:  c.Metadata.Version,
+		Path:     o.Path,
+		Findings: []LintFinding{},
+	}
+
+	if o.HelmLint {
+		result.Policies = append(result.Policies, PolicyHelmLint)
+		linter := lint.All(o.Path, values, "", false)
+		for _, msg := range linter.Messages {
+			result.Findings = append(result.Findings, LintFinding{
+				Policy:   PolicyHelmLint,
+				Severity: lintSeverity(msg.Severity),
+				Template: msg.Path,
+				Message:  msg.Err.Error(),
+			})
+		}
+	}
+
+	var policies []string
+	for _, policy := range ChartPolicies {
+		if !Contains(o.Policies.Skip, policy) {
+			policies = append(policies, policy)
+		}
+	}
+	// The templates are only rendered if a policy of rendered objects is checked
+	var objects []renderedObject
+	if len(policies) > 1 || (len(policies) == 1 && policies[0] != PolicySemverVersion) {
+		result.Policies = append(result.Policies, PolicyRender)
+		if objects, err = renderObjects(c, values); err != nil {
+			result.Findings = append(result.Findings, LintFinding{Policy: PolicyRender, Severity: LintSeverityError, Message: err.Error()})
+			return result, nil
+		}
+	}
+	for _, policy := range policies {
+		result.Policies = append(result.Policies, policy)
+		result.Findings = append(result.Findings, checkPolicy(policy, c.Metadata, objects, o.Policies)...)
+	}
+	return result, nil
+}
+
+// lintSeverity returns the severity of a helm lint message
+func lintSeverity(severity int) string {
+	switch {
+	case severity >= support.ErrorSev:
+		return LintSeverityError
+	case severity >= support.WarningSev:
+		return LintSeverityWarning
+	}
+	return LintSeverityInfo
+}
+
+// renderObjects renders the templates of a chart and returns the rendered objects, sorted by template
+func renderObjects(c *chart.Chart, values []byte) ([]renderedObject, error) {
+	rendered, err := renderutil.Render(c, &chart.Config{Raw: string(values)}, renderutil.Options{
+		ReleaseOptions: chartutil.ReleaseOptions{Name: lintReleaseName, IsInstall: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]string, 0, len(rendered))
+	for t := range rendered {
+		templates = append(templates, t)
+	}
+	sort.Strings(templates)
+
+	var objects []renderedObject
+	for _, t := range templates {
+		if base := path.Base(t); strings.HasPrefix(base, "_") || base == "NOTES.txt" {
+			continue
+		}
+		docs := releaseutil.SplitManifests(rendered[t])
+		keys := make([]string, 0, len(docs))
+		for k := range docs {
+			keys = append(keys, k)
+		}
+		// Documents are keyed by their index (manifest-0, manifest-1, ...)
+		sort.Slice(keys, func(i, j int) bool {
+			return len(keys[i]) < len(keys[j]) || len(keys[i]) == len(keys[j]) && keys[i] < keys[j]
+		})
+		for _, k := range keys {
+			if strings.TrimSpace(docs[k]) == "" {
+				continue
+			}
+			var obj renderedObject
+			if err := yaml.Unmarshal([]byte(docs[k]), &obj); err != nil {
+				return nil, fmt.Errorf("%s: %v", t, err)
+			}
+			if obj.Kind == "" {
+				continue
+			}
+			obj.template = t
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+// checkPolicy checks a chart and its rendered objects against a policy and returns the violations
+func checkPolicy(policy string, metadata *chart.Metadata, objects []renderedObject, o PolicyOptions) []LintFinding {
+	var findings []LintFinding
+	add := func(obj *renderedObject, format string, args ...interface{}) {
+		f := LintFinding{Policy: policy, Severity: LintSeverityError, Message: fmt.Sprintf(format, args...)}
+		if obj != nil {
+			f.Object = obj.Kind + "/" + obj.Metadata.Name
+			f.Template = obj.template
+		}
+		findings = append(findings, f)
+	}
+
+	if policy == PolicySemverVersion {
+		if !semverPattern.MatchString(metadata.Version) {
+			add(nil, "version \"%s\" in Chart.yaml is not a valid semantic version", metadata.Version)
+		}
+		return findings
+	}
+	for i := range objects {
+		obj := &objects[i]
+		if policy == PolicyRequiredLabels {
+			for _, label := range o.RequiredLabels {
+				if _, ok := obj.Metadata.Labels[label]; !ok {
+					add(obj, "label \"%s\" is missing", label)
+				}
+			}
+			continue
+		}
+
+		containers, initContainers, ok := obj.podSpec()
+		if !ok {
+			continue
+		}
+		for _, container := range append(append([]v1.Container{}, initContainers...), containers...) {
+			switch policy {
+			case PolicyImageTag:
+				if tag := imageTag(container.Image); tag == "" {
+					add(obj, "image \"%s\" of container %s has no tag (latest is used)", container.Image, container.Name)
+				} else if tag == "latest" {
+					add(obj, "image \"%s\" of container %s uses the latest tag", container.Image, container.Name)
+				}
+			case PolicyResources:
+				if len(container.Resources.Requests) == 0 {
+					add(obj, "container %s has no resource requests", container.Name)
+				}
+				if len(container.Resources.Limits) == 0 {
+					add(obj, "container %s has no resource limits", container.Name)
+				}
+			}
+		}
+		if policy != PolicyProbes || !obj.isLongRunning() {
+			continue
+		}
+		for _, container := range containers {
+			if container.ReadinessProbe == nil {
+				add(obj, "container %s has no readiness probe", container.Name)
+			}
+			if container.LivenessProbe == nil {
+				add(obj, "container %s has no liveness probe", container.Name)
+			}
+		}
+	}
+	return findings
+}
+
+// imageTag returns the tag of an image, or its digest if it is referenced by digest
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		return image[i+1:]
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		return name[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+func TestLintChart(t *testing.T) {
+	deployment := "Deployment/RELEASE-NAME-api"
+	tests := []struct {
+		name         string
+		options      LintChartOptions
+		wantPolicies []string
+		wantFindings []LintFinding
+		wantPassed   bool
+	}{
+		{
+			name:         "chart passes",
+			options:      LintChartOptions{Policies: PolicyOptions{RequiredLabels: []string{"app"}}},
+			wantPolicies: []string{PolicyRender, PolicySemverVersion, PolicyRequiredLabels, PolicyImageTag, PolicyResources, PolicyProbes},
+			wantFindings: []LintFinding{},
+			wantPassed:   true,
+		},
+		{
+			name:         "required labels",
+			options:      LintChartOptions{Policies: PolicyOptions{RequiredLabels: []string{"app", "release"}}},
+			wantPolicies: []string{PolicyRender, PolicySemverVersion, PolicyRequiredLabels, PolicyImageTag, PolicyResources, PolicyProbes},
+			wantFindings: []LintFinding{
+				{Policy: PolicyRequiredLabels, Severity: LintSeverityError, Object: "Service/RELEASE-NAME-api", Template: "api/templates/service.yaml", Message: "label \"release\" is missing"},
+			},
+		},
+		{
+			name: "values files and set values are rendered",
+			options: LintChartOptions{
+				ValuesFiles: []string{"testdata/lint/no-resources.yaml"},
+				Set:         []string{"image.tag=latest", "probes.enabled=false"},
+			},
+			wantPolicies: []string{PolicyRender, PolicySemverVersion, PolicyRequiredLabels, PolicyImageTag, PolicyResources, PolicyProbes},
+			wantFindings: []LintFinding{
+				{Policy: PolicyImageTag, Severity: LintSeverityError, Object: deployment, Template: "api/templates/deployment.yaml", Message: "image \"nuvo/api:latest\" of container api uses the latest tag"},
+				{Policy: PolicyResources, Severity: LintSeverityError, Object: deployment, Template: "api/templates/deployment.yaml", Message: "container api has no resource requests"},
+				{Policy: PolicyResources, Severity: LintSeverityError, Object: deployment, Template: "api/templates/deployment.yaml", Message: "container api has no resource limits"},
+				{Policy: PolicyProbes, Severity: LintSeverityError, Object: deployment, Template: "api/templates/deployment.yaml", Message: "container api has no readiness probe"},
+				{Policy: PolicyProbes, Severity: LintSeverityError, Object: deployment, Template: "api/templates/deployment.yaml", Message: "container api has no liveness probe"},
+			},
+		},
+		{
+			name: "skipped policies",
+			options: LintChartOptions{
+				Set:      []string{"image.tag=latest", "probes.enabled=false"},
+				Policies: PolicyOptions{Skip: []string{PolicyImageTag, PolicyProbes}},
+			},
+			wantPolicies: []string{PolicyRender, PolicySemverVersion, PolicyRequiredLabels, PolicyResources},
+			wantFindings: []LintFinding{},
+			wantPassed:   true,
+		},
+		{
+			name:         "helm lint",
+			options:      LintChartOptions{HelmLint: true},
+			wantPolicies: []string{PolicyHelmLint, PolicyRender, PolicySemverVersion, PolicyRequiredLabels, PolicyImageTag, PolicyResources, PolicyProbes},
+			wantFindings: []LintFinding{
+				{Policy: PolicyHelmLint, Severity: LintSeverityInfo, Template: "Chart.yaml", Message: "icon is recommended"},
+			},
+			wantPassed: true,
+		},
+		{
+			name: "templates are not rendered for the semver-version policy only",
+			options: LintChartOptions{
+				Set:      []string{"probes=null"},
+				Policies: PolicyOptions{Skip: []string{PolicyRequiredLabels, PolicyImageTag, PolicyResources, PolicyProbes}},
+			},
+			wantPolicies: []string{PolicySemverVersion},
+			wantFindings: []LintFinding{},
+			wantPassed:   true,
+		},
+		{
+			name:         "render error",
+			options:      LintChartOptions{Set: []string{"probes=null"}},
+			wantPolicies: []string{PolicyRender},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Path = "testdata/charts/api"
+			got, err := LintChart(tt.options)
+			if err != nil {
+				t.Fatalf("LintChart() error = %v", err)
+			}
+			if got.Chart != "api" || got.Version != "0.1.0" {
+				t.Errorf("LintChart() chart = %v-%v, want api-0.1.0", got.Chart, got.Version)
+			}
+			if !reflect.DeepEqual(got.Policies, tt.wantPolicies) {
+				t.Errorf("LintChart() policies = %v, want %v", got.Policies, tt.wantPolicies)
+			}
+			if tt.wantFindings != nil && !reflect.DeepEqual(got.Findings, tt.wantFindings) {
+				t.Errorf("LintChart() findings = %+v, want %+v", got.Findings, tt.wantFindings)
+			}
+			if got.Passed() != tt.wantPassed {
+				t.Errorf("LintChart() passed = %v, want %v", got.Passed(), tt.wantPassed)
+			}
+			if err := got.Err(); (err == nil) != tt.wantPassed || err != nil && !errors.Is(err, ErrLintFailed) {
+				t.Errorf("LintChart() Err() = %v, want %v", err, ErrLintFailed)
+			}
+		})
+	}
+}
+
+func TestCheckPolicy_SemverVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "1.2.3", want: true},
+		{version: "1.2.3-feature-login.2.gabc1234", want: true},
+		{version: "1.2.3+build.5", want: true},
+		{version: "1.2"},
+		{version: "v1.2.3"},
+		{version: "01.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			findings := checkPolicy(PolicySemverVersion, &chart.Metadata{Version: tt.version}, nil, PolicyOptions{})
+			if got := len(findings) == 0; got != tt.want {
+				t.Errorf("checkPolicy() = %v, want valid %v", findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx", want: ""},
+		{image: "nginx:1.15", want: "1.15"},
+		{image: "registry:5000/nuvo/api", want: ""},
+		{image: "registry:5000/nuvo/api:latest", want: "latest"},
+		{image: "nuvo/api@sha256:abc", want: "sha256:abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageTag(tt.image); got != tt.want {
+				t.Errorf("imageTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
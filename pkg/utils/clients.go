@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Clients provides the Kubernetes and Helm clients orca operates with
+type Clients struct {
+	// Context is done when orca is interrupted
+	Context context.Context
+	// NewKubeClientSet creates a Kubernetes clientset for a kube context
+	NewKubeClientSet func(kubeContext string) (kubernetes.Interface, error)
+	// NewHelmClient creates a Helm client by its name
+	NewHelmClient func(name string) (HelmClient, error)
+
+	mutex          sync.Mutex
+	kubeClientSets map[string]kubernetes.Interface
+}
+
+// NewClients returns Clients which connect to the clusters defined in the kubeconfig
+func NewClients(ctx context.Context) *Clients {
+	return &Clients{
+		Context:          ctx,
+		NewKubeClientSet: GetClientSet,
+		NewHelmClient:    NewHelmClient,
+	}
+}
+
+// KubeClientSet returns a Kubernetes clientset for a kube context, creating it only once per context
+func (c *Clients) KubeClientSet(kubeContext string) (kubernetes.Interface, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if clientset, ok := c.kubeClientSets[kubeContext]; ok {
+		return clientset, nil
+	}
+	clientset, err := c.NewKubeClientSet(kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	if c.kubeClientSets == nil {
+		c.kubeClientSets = map[string]kubernetes.Interface{}
+	}
+	c.kubeClientSets[kubeContext] = clientset
+
+	return clientset, nil
+}
+
+// Helm returns a Helm client by its name
+func (c *Clients) Helm(name string) (HelmClient, error) {
+	return c.NewHelmClient(name)
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigFileName is the name of the configuration file orca discovers
+const ConfigFileName = ".orca.yaml"
+
+// Config represents the structure of an orca configuration file
+type Config struct {
+	// Profile is the profile to use when no profile is selected
+	Profile string `yaml:"profile,omitempty"`
+	// Profiles are named sets of flag values (e.g. kube-context, parallel)
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile holds flag values by flag name
+type Profile map[string]interface{}
+
+// LoadConfig reads a configuration file
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, file, err)
+	}
+	return &config, nil
+}
+
+// FindConfigFile looks for a configuration file in a directory and its parents,
+// up to the root of the git repository containing it. It returns an empty string if none is found
+func FindConfigFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		file := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// GetProfile returns a profile by its name, or the default profile of the configuration if name is empty.
+// It returns nil if no profile is selected
+func (c *Config) GetProfile(name string) (Profile, error) {
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: profile \"%s\" not found", ErrInvalidConfig, name)
+	}
+	return profile, nil
+}
+
+// Keys returns the flag names set in a profile, sorted
+func (p Profile) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns the values of a flag set in a profile as strings (a list holds multiple values)
+func (p Profile) Values(key string) ([]string, error) {
+	switch v := p[key].(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		values := []string{}
+		for _, e := range v {
+			switch e.(type) {
+			case []interface{}, map[interface{}]interface{}:
+				return nil, fmt.Errorf("%w: invalid value of \"%s\": %v", ErrInvalidConfig, key, v)
+			}
+			values = append(values, fmt.Sprint(e))
+		}
+		return values, nil
+	case map[interface{}]interface{}:
+		return nil, fmt.Errorf("%w: invalid value of \"%s\": %v", ErrInvalidConfig, key, v)
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
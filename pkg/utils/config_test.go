@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orca-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// dir/repo is a git repository with a configuration file in dir/repo/service
+	repo := filepath.Join(dir, "repo")
+	for _, d := range []string{filepath.Join(repo, ".git"), filepath.Join(repo, "service", "chart"), filepath.Join(repo, "other")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(dir, ConfigFileName), filepath.Join(repo, "service", ConfigFileName)} {
+		if err := ioutil.WriteFile(f, []byte("profiles: {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{
+			name: "config file in directory",
+			dir:  filepath.Join(repo, "service"),
+			want: filepath.Join(repo, "service", ConfigFileName),
+		},
+		{
+			name: "config file in parent directory",
+			dir:  filepath.Join(repo, "service", "chart"),
+			want: filepath.Join(repo, "service", ConfigFileName),
+		},
+		{
+			name: "config file outside of git repository",
+			dir:  filepath.Join(repo, "other"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindConfigFile(tt.dir)
+			if err != nil {
+				t.Fatalf("FindConfigFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FindConfigFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultGracePeriod is the time in seconds in-flight operations are given to complete once orca is interrupted
+const DefaultGracePeriod = 30
+
+type killKey struct{}
+
+// NewSignalContext returns a context which is done when SIGINT or SIGTERM is received.
+// In-flight operations are killed immediately if a second signal is received
+func NewSignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	kill := make(chan struct{})
+	ctx = context.WithValue(ctx, killKey{}, (<-chan struct{})(kill))
+
+	sigc := make(chan os.Signal, 2)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigc)
+		select {
+		case sig := <-sigc:
+			log.Printf("received %s, no new releases will be started (send it again to kill in-flight operations)", sig)
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+		if sig, ok := <-sigc; ok {
+			log.Printf("received %s, killing in-flight operations", sig)
+			close(kill)
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// WithGracePeriod returns a context whose in-flight operations are given gracePeriod to complete
+// once it is done, after which they are killed
+func WithGracePeriod(parent context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	// A nil channel never fires, in case the parent has no means to kill in-flight operations
+	parentKill, _ := parent.Value(killKey{}).(<-chan struct{})
+	kill := make(chan struct{})
+	go func() {
+		defer close(kill)
+		<-ctx.Done()
+		if parent.Err() == nil {
+			// Cancelled by the caller, not interrupted
+			return
+		}
+		log.Printf("waiting up to %s for in-flight operations to complete", gracePeriod)
+		select {
+		case <-time.After(gracePeriod):
+		case <-parentKill:
+		}
+	}()
+
+	return context.WithValue(ctx, killKey{}, (<-chan struct{})(kill)), cancel
+}
+
+// killed returns a channel which is closed once in-flight operations of a context should be killed.
+// Operations of a context without a grace period are killed as soon as it is done
+func killed(ctx context.Context) <-chan struct{} {
+	if kill, ok := ctx.Value(killKey{}).(<-chan struct{}); ok {
+		return kill
+	}
+	return ctx.Done()
+}
+
+// Sleep pauses for a duration or until the context is done, in which case the context's error is returned
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runGracefully runs an operation which can not be cancelled, and stops waiting for it once it should be killed
+func runGracefully(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- f()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-killed(ctx):
+		return ctx.Err()
+	}
+}
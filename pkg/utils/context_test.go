@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExec_Interrupted(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmd         []string
+		gracePeriod time.Duration
+		wantErr     bool
+		maxDuration time.Duration
+	}{
+		{
+			name:        "command completes within grace period",
+			cmd:         []string{"sleep", "0.2"},
+			gracePeriod: 5 * time.Second,
+			wantErr:     false,
+			maxDuration: 2 * time.Second,
+		},
+		{
+			name:        "command is killed after grace period",
+			cmd:         []string{"sleep", "10"},
+			gracePeriod: 200 * time.Millisecond,
+			wantErr:     true,
+			maxDuration: 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, interrupt := context.WithCancel(context.Background())
+			ctx, cancel := WithGracePeriod(parent, tt.gracePeriod)
+			defer cancel()
+
+			start := time.Now()
+			errc := make(chan error, 1)
+			go func() {
+				_, err := Exec(ctx, tt.cmd)
+				errc <- err
+			}()
+			time.Sleep(50 * time.Millisecond)
+			interrupt()
+
+			err := <-errc
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if d := time.Since(start); d > tt.maxDuration {
+				t.Errorf("Exec() took %s, want at most %s", d, tt.maxDuration)
+			}
+		})
+	}
+}
+
+func TestExec_KilledWhenTerminateIgnored(t *testing.T) {
+	defer func(d time.Duration) { terminateTimeout = d }(terminateTimeout)
+	terminateTimeout = 300 * time.Millisecond
+
+	ctx, cancel := WithGracePeriod(context.Background(), 0)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	// SIGTERM is ignored by the shell and inherited by sleep
+	if _, err := Exec(ctx, []string{"sh", "-c", "trap '' TERM; sleep 10"}); err == nil {
+		t.Error("Exec() expected an error")
+	}
+	if d := time.Since(start); d < terminateTimeout || d > 2*time.Second {
+		t.Errorf("Exec() took %s, want between %s and 2s", d, terminateTimeout)
+	}
+}
+
+func TestExec_NotStartedWhenDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Exec(ctx, []string{"true"}); err != context.Canceled {
+		t.Errorf("Exec() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if err := Sleep(ctx, 10*time.Second); err != context.Canceled {
+		t.Errorf("Sleep() error = %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Sleep() took %s after the context was done", d)
+	}
+}
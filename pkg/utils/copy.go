@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// CopiedFromAnnotation is the source (namespace/name) of an object orca copied into an environment
+	CopiedFromAnnotation = "orca.nuvocares.com/copied-from"
+	// CopiedLabel labels the objects orca copied into an environment
+	CopiedLabel = "orca.nuvocares.com/copied"
+
+	defaultServiceAccount = "default"
+)
+
+// CopyObjectsOptions are options passed to CopyObjects
+type CopyObjectsOptions struct {
+	ClientSet  kubernetes.Interface
+	Namespace  string
+	Secrets    []string
+	ConfigMaps []string
+	Print      bool
+}
+
+// CopyObjects copies Secrets and ConfigMaps (namespace/name) into a namespace, updates copies which already exist,
+// and deletes copies of objects which are no longer copied. Objects in the namespace which are not copies of the
+// same source are not overwritten. It returns the names of the copied image pull Secrets and the names of the deleted Secrets
+func CopyObjects(o CopyObjectsOptions) ([]string, []string, error) {
+	pullSecrets := []string{}
+	prunedSecrets := []string{}
+	for _, kind := range []string{"Secret", "ConfigMap"} {
+		sources := o.Secrets
+		if kind == "ConfigMap" {
+			sources = o.ConfigMaps
+		}
+		// Sources are validated before any of them is copied
+		sourcesByName := map[string]string{}
+		for _, source := range sources {
+			sourceNamespace, name, err := SplitInTwo(source, "/")
+			if err != nil {
+				return nil, nil, err
+			}
+			if sourceNamespace == o.Namespace {
+				return nil, nil, fmt.Errorf("%w: can not copy %s \"%s\" into its own namespace", ErrInvalidKeyValue, kind, source)
+			}
+			if other, ok := sourcesByName[name]; ok && other != source {
+				return nil, nil, fmt.Errorf("%w: %s \"%s\" and \"%s\" are copied to the same name", ErrInvalidKeyValue, kind, other, source)
+			}
+			sourcesByName[name] = source
+		}
+
+		c := newObjectClient(o.ClientSet, o.Namespace, kind)
+		keep := map[string]bool{}
+		for _, source := range sources {
+			sourceNamespace, name, _ := SplitInTwo(source, "/")
+			if err := checkCopyTarget(c, kind, name, source); err != nil {
+				return nil, nil, err
+			}
+			obj, err := newObjectClient(o.ClientSet, sourceNamespace, kind).get(name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed getting %s \"%s\": %v", kind, source, err)
+			}
+			if err := applyObject(o.ClientSet, o.Namespace, copyObject(obj, source), o.Print); err != nil {
+				return nil, nil, err
+			}
+			keep[name] = true
+			if secret, ok := obj.(*v1.Secret); ok && (secret.Type == v1.SecretTypeDockerConfigJson || secret.Type == v1.SecretTypeDockercfg) {
+				pullSecrets = append(pullSecrets, name)
+			}
+		}
+		pruned, err := pruneObjects(o.ClientSet, o.Namespace, kind, CopiedLabel+"=true", keep, o.Print)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kind == "Secret" {
+			prunedSecrets = pruned
+		}
+	}
+	return pullSecrets, prunedSecrets, nil
+}
+
+// checkCopyTarget returns an error if an object which would be overwritten by a copy of source exists,
+// and is not a copy of the same source
+func checkCopyTarget(c *objectClient, kind, name, source string) error {
+	existing, err := c.get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed getting %s \"%s\": %v", kind, name, err)
+	}
+	accessor, err := meta.Accessor(existing)
+	if err != nil {
+		return err
+	}
+	if accessor.GetLabels()[CopiedLabel] != "true" || accessor.GetAnnotations()[CopiedFromAnnotation] != source {
+		return fmt.Errorf("can not copy %s \"%s\": %s \"%s\" exists in the environment and is not a copy of it", kind, source, kind, name)
+	}
+	return nil
+}
+
+// copyObject returns a copy of a Secret or a ConfigMap stripped of its namespace specific metadata
+func copyObject(obj runtime.Object, source string) runtime.Object {
+	meta := func(m metav1.ObjectMeta) metav1.ObjectMeta {
+		labels := map[string]string{CopiedLabel: "true"}
+		for k, v := range m.Labels {
+			labels[k] = v
+		}
+		annotations := map[string]string{CopiedFromAnnotation: source}
+		for k, v := range m.Annotations {
+			if k == v1.LastAppliedConfigAnnotation {
+				continue
+			}
+			annotations[k] = v
+		}
+		return metav1.ObjectMeta{Name: m.Name, Labels: labels, Annotations: annotations}
+	}
+	switch o := obj.(type) {
+	case *v1.Secret:
+		return &v1.Secret{ObjectMeta: meta(o.ObjectMeta), Type: o.Type, Data: o.Data}
+	case *v1.ConfigMap:
+		return &v1.ConfigMap{ObjectMeta: meta(o.ObjectMeta), Data: o.Data, BinaryData: o.BinaryData}
+	}
+	return obj
+}
+
+// UpdateImagePullSecrets adds secrets to the imagePullSecrets of the default ServiceAccount of a namespace, and removes
+// references to deleted secrets from them. The ServiceAccount is created if it was not yet created by Kubernetes
+func UpdateImagePullSecrets(clientset kubernetes.Interface, namespace string, secrets, deletedSecrets []string, print bool) error {
+	if len(secrets) == 0 && len(deletedSecrets) == 0 {
+		return nil
+	}
+	serviceAccounts := clientset.CoreV1().ServiceAccounts(namespace)
+	sa, err := serviceAccounts.Get(defaultServiceAccount, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) && len(secrets) == 0 {
+		return nil
+	}
+	if apierrors.IsNotFound(err) {
+		sa = &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: defaultServiceAccount, Namespace: namespace}}
+		for _, s := range secrets {
+			sa.ImagePullSecrets = append(sa.ImagePullSecrets, v1.LocalObjectReference{Name: s})
+		}
+		_, err = serviceAccounts.Create(sa)
+		if err == nil {
+			if print {
+				log.Printf("created ServiceAccount \"%s\" in environment \"%s\" with image pull secrets %v", defaultServiceAccount, namespace, secrets)
+			}
+			return nil
+		}
+		// The ServiceAccount was created by Kubernetes in the meantime
+		if apierrors.IsAlreadyExists(err) {
+			sa, err = serviceAccounts.Get(defaultServiceAccount, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed getting ServiceAccount \"%s\": %v", defaultServiceAccount, err)
+	}
+
+	existing := map[string]bool{}
+	imagePullSecrets := []v1.LocalObjectReference{}
+	removed := []string{}
+	for _, s := range sa.ImagePullSecrets {
+		if Contains(deletedSecrets, s.Name) {
+			removed = append(removed, s.Name)
+			continue
+		}
+		existing[s.Name] = true
+		imagePullSecrets = append(imagePullSecrets, s)
+	}
+	added := []string{}
+	for _, s := range secrets {
+		if existing[s] {
+			continue
+		}
+		imagePullSecrets = append(imagePullSecrets, v1.LocalObjectReference{Name: s})
+		added = append(added, s)
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	sa.ImagePullSecrets = imagePullSecrets
+	if _, err := serviceAccounts.Update(sa); err != nil {
+		return fmt.Errorf("failed updating ServiceAccount \"%s\": %v", defaultServiceAccount, err)
+	}
+	if print && len(added) != 0 {
+		log.Printf("added image pull secrets %v to ServiceAccount \"%s\" in environment \"%s\"", added, defaultServiceAccount, namespace)
+	}
+	if print && len(removed) != 0 {
+		log.Printf("removed deleted image pull secrets %v from ServiceAccount \"%s\" in environment \"%s\"", removed, defaultServiceAccount, namespace)
+	}
+	return nil
+}
@@ -1,41 +1,104 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// GetIntEnvVar returns 0 if the variable is empty or not int, else the value
-func GetIntEnvVar(name string, defVal int) int {
+// GetStringEnvVar returns the default value if the variable is empty, else the value
+func GetStringEnvVar(name, defVal string) string {
 	val := os.Getenv(name)
 	if val == "" {
 		return defVal
 	}
-	iVal, err := strconv.Atoi(val)
-	if err != nil {
-		return defVal
-	}
-	return iVal
+	return val
 }
 
-// GetStringEnvVar returns the default value if the variable is empty, else the value
-func GetStringEnvVar(name, defVal string) string {
+// tillerNamespace returns the namespace of Tiller, set by $TILLER_NAMESPACE (default is kube-system)
+func tillerNamespace() string {
+	return GetStringEnvVar("TILLER_NAMESPACE", "kube-system")
+}
+
+// EnvVar is an environment variable which orca reads directly, and not through a flag
+type EnvVar struct {
+	Name  string
+	Usage string
+	// Parse validates the value of the variable, nil if any value is valid
+	Parse func(val string) error
+}
+
+// EnvVars are the environment variables orca reads directly, sorted by name
+var EnvVars = []EnvVar{
+	{Name: "HELM_HOME", Usage: "path to the Helm home directory (sdk helm client)"},
+	{Name: "HELM_HOST", Usage: "address of Tiller, instead of forwarding a port to the Tiller pod (sdk helm client)"},
+	{Name: "HELM_REPO_PASSWORD", Usage: "password of the chart repository, unless set by a flag or the Helm repositories file (push chart, push charts)"},
+	{Name: "HELM_REPO_USERNAME", Usage: "username of the chart repository, unless set by a flag or the Helm repositories file (push chart, push charts)"},
+	{Name: "HELM_TILLER_CONNECTION_TIMEOUT", Usage: "time in seconds to wait for a connection to Tiller (sdk helm client)", Parse: func(val string) error {
+		_, err := ParseSeconds(val)
+		return err
+	}},
+	{Name: "KUBECONFIG", Usage: "path to the kubeconfig file (default is ~/.kube/config)"},
+	{Name: "TILLER_NAMESPACE", Usage: "namespace of Tiller (sdk helm client, reading installed releases)"},
+}
+
+// getSecondsEnvVar returns the default value if the variable is empty, else the value parsed by ParseSeconds
+func getSecondsEnvVar(name string, defVal int) (int, error) {
 	val := os.Getenv(name)
 	if val == "" {
-		return defVal
+		return defVal, nil
 	}
-	return val
+	seconds, err := ParseSeconds(val)
+	if err != nil {
+		return 0, fmt.Errorf("%w: $%s=\"%s\": %v", ErrInvalidEnvVar, name, val, err)
+	}
+	return seconds, nil
 }
 
-// GetBoolEnvVar returns the default value if the variable is empty or not true or false, else the value
-func GetBoolEnvVar(name string, defVal bool) bool {
-	val := os.Getenv(name)
-	if strings.ToLower(val) == "true" {
-		return true
+// ParseBool parses a boolean value: true/false, 1/0, yes/no, y/n or on/off (case insensitive)
+func ParseBool(val string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "true", "1", "yes", "y", "on":
+		return true, nil
+	case "false", "0", "no", "n", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean \"%s\" (expected true/false, 1/0, yes/no, on/off)", val)
+}
+
+// ParseInt parses an integer value
+func ParseInt(val string) (int, error) {
+	iVal, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer \"%s\"", val)
+	}
+	return iVal, nil
+}
+
+// ParseSeconds parses a number of seconds or a duration (e.g. 90, 90s, 5m, 1h30m) to whole seconds
+func ParseSeconds(val string) (int, error) {
+	val = strings.TrimSpace(val)
+	if iVal, err := strconv.Atoi(val); err == nil {
+		return iVal, nil
 	}
-	if strings.ToLower(val) == "false" {
-		return false
+	d, err := time.ParseDuration(val)
+	if err != nil || d%time.Second != 0 {
+		return 0, fmt.Errorf("invalid duration \"%s\" (expected seconds or a duration such as 90s, 5m, 1h30m)", val)
+	}
+	return int(d / time.Second), nil
+}
+
+// ParseList parses a comma separated list, ignoring whitespace around elements
+func ParseList(val string) ([]string, error) {
+	list := []string{}
+	for _, e := range strings.Split(val, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			return nil, fmt.Errorf("invalid list \"%s\" (expected comma separated values)", val)
+		}
+		list = append(list, e)
 	}
-	return defVal
+	return list, nil
 }
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    bool
+		wantErr bool
+	}{
+		{val: "true", want: true},
+		{val: "1", want: true},
+		{val: "Yes", want: true},
+		{val: "on", want: true},
+		{val: "FALSE", want: false},
+		{val: "0", want: false},
+		{val: "no", want: false},
+		{val: "off", want: false},
+		{val: "maybe", wantErr: true},
+		{val: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := ParseBool(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeconds(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    int
+		wantErr bool
+	}{
+		{val: "300", want: 300},
+		{val: "90s", want: 90},
+		{val: "5m", want: 300},
+		{val: "1h30m", want: 5400},
+		{val: "1.5s", wantErr: true},
+		{val: "five minutes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := ParseSeconds(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeconds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeconds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    []string
+		wantErr bool
+	}{
+		{val: "kaa", want: []string{"kaa"}},
+		{val: "kaa=0.1.7, mariadb=0.5.4", want: []string{"kaa=0.1.7", "mariadb=0.5.4"}},
+		{val: "kaa,,mariadb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := ParseList(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetInstalledReleases_TillerNamespace(t *testing.T) {
+	tiller := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "tiller-deploy", Namespace: "tiller", Labels: map[string]string{"name": "tiller"}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "tiller", Command: []string{"/tiller"}}}},
+	}
+	clientset := k8sfake.NewSimpleClientset(tiller)
+	o := GetInstalledReleasesOptions{ClientSet: clientset, Namespace: "env"}
+
+	if _, err := GetInstalledReleases(o); err == nil {
+		t.Errorf("GetInstalledReleases() without $TILLER_NAMESPACE found Tiller in namespace tiller, want error")
+	}
+	os.Setenv("TILLER_NAMESPACE", "tiller")
+	defer os.Unsetenv("TILLER_NAMESPACE")
+	if _, err := GetInstalledReleases(o); err != nil {
+		t.Errorf("GetInstalledReleases() with $TILLER_NAMESPACE=tiller error = %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// EnvTemplateLabel labels the objects orca creates in an environment from an environment template
+const EnvTemplateLabel = "orca.nuvocares.com/env-template"
+
+// envTemplateKinds are the kinds an environment template may hold
+var envTemplateKinds = []string{"ResourceQuota", "LimitRange", "NetworkPolicy", "Role", "RoleBinding"}
+
+// LoadEnvTemplate reads the objects of an environment template. The template is a manifest file,
+// or a directory of manifest files (.yaml, .yml, .json), each of which may hold multiple documents
+func LoadEnvTemplate(path string) ([]runtime.Object, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvTemplate, err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = []string{}
+		for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+	}
+
+	objects := []runtime.Object{}
+	names := map[string]bool{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEnvTemplate, err)
+		}
+		reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEnvTemplate, file, err)
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEnvTemplate, file, err)
+			}
+			kind, err := objectKind(obj)
+			if err != nil || !Contains(envTemplateKinds, kind) {
+				return nil, fmt.Errorf("%w: %s: unsupported kind %s (supported kinds: %s)", ErrInvalidEnvTemplate, file, obj.GetObjectKind().GroupVersionKind().Kind, strings.Join(envTemplateKinds, ", "))
+			}
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				return nil, err
+			}
+			if accessor.GetName() == "" {
+				return nil, fmt.Errorf("%w: %s: %s without a name", ErrInvalidEnvTemplate, file, kind)
+			}
+			if names[kind+"/"+accessor.GetName()] {
+				return nil, fmt.Errorf("%w: %s: %s \"%s\" is defined more than once", ErrInvalidEnvTemplate, file, kind, accessor.GetName())
+			}
+			names[kind+"/"+accessor.GetName()] = true
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+// ApplyEnvTemplate creates or updates the objects of an environment template in a namespace,
+// and deletes objects which were created from the template and were removed from it since
+func ApplyEnvTemplate(clientset kubernetes.Interface, namespace string, objects []runtime.Object, print bool) error {
+	keep := map[string]map[string]bool{}
+	for _, kind := range envTemplateKinds {
+		keep[kind] = map[string]bool{}
+	}
+	for _, obj := range objects {
+		obj = obj.DeepCopyObject()
+		kind, err := objectKind(obj)
+		if err != nil {
+			return err
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		labels := accessor.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[EnvTemplateLabel] = "true"
+		accessor.SetLabels(labels)
+		if err := applyObject(clientset, namespace, obj, print); err != nil {
+			return err
+		}
+		keep[kind][accessor.GetName()] = true
+	}
+	for _, kind := range envTemplateKinds {
+		if _, err := pruneObjects(clientset, namespace, kind, EnvTemplateLabel+"=true", keep[kind], print); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidChartsFile is returned when a charts file can not be read or parsed
+	ErrInvalidChartsFile = errors.New("invalid charts file")
+	// ErrCircularDependency is returned when releases depend on each other in a cycle
+	ErrCircularDependency = errors.New("circular dependency found")
+	// ErrInvalidKeyValue is returned when a key-value argument (e.g. chart=version) is malformed
+	ErrInvalidKeyValue = errors.New("invalid key-value pair")
+	// ErrInvalidChart is returned when a chart's Chart.yaml can not be read, parsed or written
+	ErrInvalidChart = errors.New("invalid chart")
+	// ErrInvalidConfig is returned when a configuration file can not be read or parsed, or a profile is invalid
+	ErrInvalidConfig = errors.New("invalid configuration")
+	// ErrInvalidEnvVar is returned when an environment variable has a value which is invalid for its type
+	ErrInvalidEnvVar = errors.New("invalid environment variable")
+	// ErrInvalidEnvTemplate is returned when an environment template can not be read or holds unsupported objects
+	ErrInvalidEnvTemplate = errors.New("invalid environment template")
+	// ErrInvalidBuildTypeRules is returned when a build type rules file can not be read, parsed or holds invalid rules
+	ErrInvalidBuildTypeRules = errors.New("invalid build type rules")
+	// ErrInvalidServicesFile is returned when a services file can not be read, parsed or holds invalid services
+	ErrInvalidServicesFile = errors.New("invalid services file")
+	// ErrClusterUnreachable is returned when a Kubernetes cluster can not be configured or reached
+	ErrClusterUnreachable = errors.New("cluster unreachable")
+	// ErrLintFailed is returned when a chart violates policies or fails helm lint
+	ErrLintFailed = errors.New("chart lint failed")
+	// ErrChartVersionExists is returned when a chart version which is pushed already exists in the chart repository
+	ErrChartVersionExists = errors.New("chart version already exists")
+	// ErrRequestFailed is returned when an HTTP request can not be sent or its response can not be read
+	ErrRequestFailed = errors.New("request failed")
+)
+
+// HTTPError is returned when an HTTP request returns an unexpected status code
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s returned %d (expected status code mismatch): %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
@@ -0,0 +1,275 @@
+package fake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/nuvo/orca/pkg/utils"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	rspb "k8s.io/helm/pkg/proto/hapi/release"
+)
+
+// HelmClient is a Helm client which records operations and stores releases
+// in the fake clientset the same way Tiller does (ConfigMaps in the Tiller namespace)
+type HelmClient struct {
+	// Charts holds the versions of charts available in the repository, by chart name
+	Charts map[string][]string
+	// UpgradeErrors holds errors to return when upgrading a release, by release name
+	UpgradeErrors map[string]error
+	// DeleteErrors holds errors to return when deleting a release, by release name
+	DeleteErrors map[string]error
+	// OnUpgrade is called with the release name when a release is upgraded (optional)
+	OnUpgrade func(releaseName string)
+
+	// Upgraded holds the names of upgraded releases, in order
+	Upgraded []string
+	// Deleted holds the names of deleted releases, in order
+	Deleted []string
+	// Repositories holds the added repositories, in order
+	Repositories []string
+	// Pushed holds the pushed charts (name-version), in order
+	Pushed []string
+	// Forced holds the charts pushed with force (name-version), in order
+	Forced []string
+
+	clientset kubernetes.Interface
+	mutex     sync.Mutex
+}
+
+// NewHelmClient returns a fake Helm client which stores releases in the given clientset
+func NewHelmClient(clientset kubernetes.Interface) *HelmClient {
+	return &HelmClient{
+		Charts:        map[string][]string{},
+		UpgradeErrors: map[string]error{},
+		DeleteErrors:  map[string]error{},
+		clientset:     clientset,
+	}
+}
+
+// NewClients returns Clients which always return the given clientset and Helm client
+func NewClients(clientset kubernetes.Interface, helmClient utils.HelmClient) *utils.Clients {
+	return &utils.Clients{
+		Context: context.Background(),
+		NewKubeClientSet: func(kubeContext string) (kubernetes.Interface, error) {
+			return clientset, nil
+		},
+		NewHelmClient: func(name string) (utils.HelmClient, error) {
+			return helmClient, nil
+		},
+	}
+}
+
+// AddRepository records the added repository
+func (c *HelmClient) AddRepository(ctx context.Context, o utils.AddRepositoryOptions) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Repositories = append(c.Repositories, o.Repo)
+	return nil
+}
+
+// UpdateRepositories does nothing
+func (c *HelmClient) UpdateRepositories(ctx context.Context, print bool) error {
+	return nil
+}
+
+// FetchChart writes a Chart.yaml of the requested chart to the directory
+func (c *HelmClient) FetchChart(ctx context.Context, o utils.FetchChartOptions) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if versions, ok := c.Charts[o.Name]; ok && !utils.Contains(versions, o.Version) {
+		return &utils.HelmError{Op: "fetch", Target: o.Name, Err: fmt.Errorf("chart \"%s\" version \"%s\" not found", o.Name, o.Version)}
+	}
+	dir := filepath.Join(o.Dir, o.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(map[string]string{"name": o.Name, "version": o.Version})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), data, 0644)
+}
+
+// UpdateChartDependencies does nothing
+func (c *HelmClient) UpdateChartDependencies(ctx context.Context, o utils.UpdateChartDependenciesOptions) error {
+	return nil
+}
+
+// UpgradeRelease stores a deployed release of the fetched chart
+func (c *HelmClient) UpgradeRelease(ctx context.Context, o utils.UpgradeReleaseOptions) (*utils.HelmRelease, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	out := output(o.Out)
+	fmt.Fprintf(out, "upgrading release %s\n", o.ReleaseName)
+	if c.OnUpgrade != nil {
+		c.OnUpgrade(o.ReleaseName)
+	}
+	if err := c.UpgradeErrors[o.ReleaseName]; err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return nil, &utils.HelmError{Op: "upgrade", Target: o.ReleaseName, Err: err}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(o.Dir, o.Name, "Chart.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var metadata chart.Metadata
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	configMaps := c.clientset.CoreV1().ConfigMaps(TillerNamespace)
+	revision := int32(1)
+	if existing, err := configMaps.Get(o.ReleaseName, metav1.GetOptions{}); err == nil {
+		if r, err := decodeRelease(existing.Data["release"]); err == nil {
+			revision = r.Version + 1
+		}
+		configMaps.Delete(o.ReleaseName, &metav1.DeleteOptions{})
+	}
+
+	rel := &rspb.Release{
+		Name:      o.ReleaseName,
+		Namespace: o.Namespace,
+		Version:   revision,
+		Info: &rspb.Info{
+			Status:       &rspb.Status{Code: rspb.Status_DEPLOYED},
+			LastDeployed: &timestamp.Timestamp{},
+		},
+		Chart: &chart.Chart{Metadata: &metadata},
+	}
+	encoded, err := encodeRelease(rel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := configMaps.Create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      o.ReleaseName,
+			Namespace: TillerNamespace,
+			Labels: map[string]string{
+				"NAME":    o.ReleaseName,
+				"OWNER":   "TILLER",
+				"STATUS":  "DEPLOYED",
+				"VERSION": fmt.Sprintf("%d", revision),
+			},
+		},
+		Data: map[string]string{"release": encoded},
+	}); err != nil {
+		return nil, err
+	}
+	c.Upgraded = append(c.Upgraded, o.ReleaseName)
+	fmt.Fprintf(out, "Release \"%s\" has been upgraded (revision %d)\n", o.ReleaseName, revision)
+
+	return &utils.HelmRelease{
+		Name:         o.ReleaseName,
+		Namespace:    o.Namespace,
+		Revision:     revision,
+		Status:       rspb.Status_DEPLOYED.String(),
+		ChartName:    metadata.Name,
+		ChartVersion: metadata.Version,
+	}, nil
+}
+
+// DeleteRelease purges a stored release
+func (c *HelmClient) DeleteRelease(ctx context.Context, o utils.DeleteReleaseOptions) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	out := output(o.Out)
+	fmt.Fprintf(out, "deleting release %s\n", o.ReleaseName)
+	if err := c.DeleteErrors[o.ReleaseName]; err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return &utils.HelmError{Op: "delete", Target: o.ReleaseName, Err: err}
+	}
+	if err := c.clientset.CoreV1().ConfigMaps(TillerNamespace).Delete(o.ReleaseName, &metav1.DeleteOptions{}); err != nil {
+		return &utils.HelmError{Op: "delete", Target: o.ReleaseName, Err: err}
+	}
+	c.Deleted = append(c.Deleted, o.ReleaseName)
+
+	return nil
+}
+
+// Lint does nothing
+func (c *HelmClient) Lint(ctx context.Context, o utils.LintOptions) error {
+	return nil
+}
+
+// PushChart records the pushed chart and makes its version available in the repository
+func (c *HelmClient) PushChart(ctx context.Context, o utils.PushChartOptions) error {
+	data, err := ioutil.ReadFile(filepath.Join(o.Path, "Chart.yaml"))
+	if err != nil {
+		return err
+	}
+	var metadata chart.Metadata
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Pushed = append(c.Pushed, metadata.Name+"-"+metadata.Version)
+	if o.Force {
+		c.Forced = append(c.Forced, metadata.Name+"-"+metadata.Version)
+	}
+	c.Charts[metadata.Name] = append(c.Charts[metadata.Name], metadata.Version)
+	return nil
+}
+
+func output(out io.Writer) io.Writer {
+	if out == nil {
+		return ioutil.Discard
+	}
+	return out
+}
+
+func encodeRelease(rel *rspb.Release) (string, error) {
+	b, err := proto.Marshal(rel)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return "", err
+	}
+	w.Close()
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodeRelease(data string) (*rspb.Release, error) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var rel rspb.Release
+	if err := proto.Unmarshal(b, &rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
@@ -0,0 +1,88 @@
+package fake
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TillerNamespace is the namespace in which the fake Tiller runs
+const TillerNamespace = "kube-system"
+
+// NewClientSet returns a fake Kubernetes clientset holding the given objects and a Tiller pod.
+// Namespaces are created in the Active phase, as they are by the API server.
+func NewClientSet(objects ...runtime.Object) *k8sfake.Clientset {
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	for _, obj := range append(objects, TillerPod()) {
+		if err := tracker.Add(obj); err != nil {
+			panic(err)
+		}
+	}
+	react := k8stesting.ObjectReaction(tracker)
+
+	clientset := k8sfake.NewSimpleClientset()
+	clientset.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.Matches("create", "namespaces") {
+			ns := action.(k8stesting.CreateAction).GetObject().(*v1.Namespace)
+			ns.Status.Phase = v1.NamespaceActive
+		}
+		return react(action)
+	})
+	clientset.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := tracker.Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		return true, w, nil
+	})
+
+	return clientset
+}
+
+// Namespace returns an Active namespace with the given annotations
+func Namespace(name string, annotations map[string]string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+		},
+		Status: v1.NamespaceStatus{Phase: v1.NamespaceActive},
+	}
+}
+
+// TillerPod returns a Tiller pod which stores releases in ConfigMaps
+func TillerPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tiller-deploy",
+			Namespace: TillerNamespace,
+			Labels:    map[string]string{"app": "helm", "name": "tiller"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "tiller", Command: []string{"/tiller"}}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+// Pod returns a pod in the given namespace, which is running and ready when healthy is true
+func Pod(namespace, name string, healthy bool) *v1.Pod {
+	phase := v1.PodRunning
+	if !healthy {
+		phase = v1.PodPending
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: v1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: []v1.ContainerStatus{{Name: name, Ready: healthy}},
+		},
+	}
+}
@@ -1,19 +1,25 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"log"
+	"io"
 	exec "os/exec"
 	"sort"
 	"strings"
+	"time"
 )
 
+// terminateTimeout is the time a killed command has to exit after SIGTERM, before it is sent SIGKILL
+var terminateTimeout = 10 * time.Second
+
 // PrintExec takes a command and executes it, with or without printing
-func PrintExec(cmd []string, print bool) error {
+func PrintExec(ctx context.Context, cmd []string, print bool) error {
 	if print {
 		fmt.Println(cmd)
 	}
-	output, err := Exec(cmd)
+	output, err := Exec(ctx, cmd)
 	if err != nil {
 		return err
 	}
@@ -23,19 +29,61 @@ func PrintExec(cmd []string, print bool) error {
 	return nil
 }
 
-// Exec takes a command as a string and executes it
-func Exec(cmd []string) (string, error) {
+// Exec takes a command as a string and executes it.
+// Once the context is done and its grace period has passed, the command is terminated (and killed if it does not exit)
+func Exec(ctx context.Context, cmd []string) (string, error) {
+	var output bytes.Buffer
+	if err := run(ctx, cmd, &output); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf(output.String())
+		}
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// ExecStream executes a command and streams its combined output to a writer.
+// Once the context is done and its grace period has passed, the command is terminated (and killed if it does not exit)
+func ExecStream(ctx context.Context, cmd []string, out io.Writer) error {
+	return run(ctx, cmd, out)
+}
+
+func run(ctx context.Context, cmd []string, out io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	binary := cmd[0]
 	_, err := exec.LookPath(binary)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	output, err := exec.Command(binary, cmd[1:]...).CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf(string(output))
+	c := exec.Command(binary, cmd[1:]...)
+	c.Stdout = out
+	c.Stderr = out
+	setProcessGroup(c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-killed(ctx):
+		// helm is terminated first, so that it can release the Tiller lock of the release
+		terminateProcessGroup(c.Process)
+		select {
+		case <-done:
+		case <-time.After(terminateTimeout):
+			killProcessGroup(c.Process)
+			<-done
+		}
+		return fmt.Errorf("%s killed: %v", binary, ctx.Err())
 	}
-	return string(output), nil
 }
 
 // AddIfNotContained adds a string to a slice if it is not contained in it and not empty
@@ -57,13 +105,13 @@ func Contains(s []string, e string) bool {
 	return false
 }
 
-// SplitInTwo splits a string to two parts by a delimeter
-func SplitInTwo(s, sep string) (string, string) {
+// SplitInTwo splits a string to two parts by the first occurrence of a delimeter
+func SplitInTwo(s, sep string) (string, string, error) {
 	if !strings.Contains(s, sep) {
-		log.Fatal(s, "does not contain", sep)
+		return "", "", fmt.Errorf("%w: \"%s\" does not contain \"%s\"", ErrInvalidKeyValue, s, sep)
 	}
-	split := strings.Split(s, sep)
-	return split[0], split[1]
+	split := strings.SplitN(s, sep, 2)
+	return split[0], split[1], nil
 }
 
 // MapToString returns a string representation of a map
@@ -1,9 +1,7 @@
 package utils
 
 import (
-	"log"
 	"regexp"
-	"strings"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 
@@ -11,64 +9,26 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
-// GetBuildTypeByPathFilters determines the build type according to path filters
-func GetBuildTypeByPathFilters(defaultType string, changedPaths, pathFilter []string, allowMultipleTypes bool) string {
-
-	// If no paths were changed - default type
-	if len(changedPaths) == 0 {
-		return defaultType
-	}
-
-	// Count lines per path filter
-	changedPathsPerFilter, changedPathsPerFilterCount := CountLinesPerPathFilter(pathFilter, changedPaths)
-
-	// If not all paths matched filters - default type
-	if changedPathsPerFilterCount != len(changedPaths) {
-		return defaultType
-	}
-
-	multipleTypes := ""
-	for bt, btPathCount := range changedPathsPerFilter {
-		if (!strings.Contains(multipleTypes, bt)) && (btPathCount != 0) {
-			multipleTypes = multipleTypes + bt + ";"
-		}
-	}
-	multipleTypes = strings.TrimRight(multipleTypes, ";")
-
-	// If multiple is not allowed and there are multiple - default type
-	if (allowMultipleTypes == false) && (strings.Contains(multipleTypes, ";")) {
-		return defaultType
-	}
-
-	return multipleTypes
-}
-
 // GetChangedPaths compares the current commit (HEAD) with the given commit and returns a list of the paths that were changed between them
-func GetChangedPaths(previousCommit string) []string {
+func GetChangedPaths(previousCommit string) ([]string, error) {
 	r, err := git.PlainOpen(".")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	head, err := r.Head()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	currentCommitTree := getTreeFromHash(head.Hash(), r)
-	previousCommitTree := getTreeFromStr(previousCommit, r)
-	changes, err := currentCommitTree.Diff(previousCommitTree)
+	currentCommitTree, err := getTreeFromHash(head.Hash(), r)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
-	var changedFiles []string
-
-	for _, change := range changes {
-		changedFiles = AddIfNotContained(changedFiles, change.From.Name)
-		changedFiles = AddIfNotContained(changedFiles, change.To.Name)
+	previousCommitTree, err := getTreeFromStr(previousCommit, r)
+	if err != nil {
+		return nil, err
 	}
-
-	return changedFiles
+	return diffTrees(currentCommitTree, previousCommitTree)
 }
 
 // IsMainlineOrReleaseRef returns true if this is the mainline or a release branch
@@ -82,44 +42,33 @@ func IsCommitError(commit, commitErrorIndicator string) bool {
 	return commit == commitErrorIndicator
 }
 
-// CountLinesPerPathFilter get a list of path filters (regex=type) and counts matches from the paths that were changed
-func CountLinesPerPathFilter(pathFilter []string, changedPaths []string) (changedPathsPerFilter map[string]int, changedPathsPerFilterCount int) {
-
-	changedPathsPerFilter = map[string]int{}
-	changedPathsPerFilterCount = 0
-
-	for _, pf := range pathFilter {
-		pfPathRegex, pfBuildtype := SplitInTwo(pf, "=")
-		pfPath, _ := regexp.Compile(pfPathRegex)
-
-		changedPathsPerFilter[pfBuildtype] = 0
-
-		for _, path := range changedPaths {
-			if pfPath.MatchString(path) {
-				changedPathsPerFilter[pfBuildtype]++
-				changedPathsPerFilterCount++
-			}
-		}
-	}
-
-	return changedPathsPerFilter, changedPathsPerFilterCount
-}
-
-func getTreeFromStr(hash string, r *git.Repository) *object.Tree {
+func getTreeFromStr(hash string, r *git.Repository) (*object.Tree, error) {
 	commitHash := plumbing.NewHash(hash)
 
 	return getTreeFromHash(commitHash, r)
 }
 
-func getTreeFromHash(hash plumbing.Hash, r *git.Repository) *object.Tree {
+func getTreeFromHash(hash plumbing.Hash, r *git.Repository) (*object.Tree, error) {
 	commitObject, err := r.CommitObject(hash)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	commitTree, err := commitObject.Tree()
+	return commitObject.Tree()
+}
+
+// diffTrees returns the paths that were changed between two trees
+func diffTrees(currentTree, previousTree *object.Tree) ([]string, error) {
+	changes, err := currentTree.Diff(previousTree)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	var changedFiles []string
+
+	for _, change := range changes {
+		changedFiles = AddIfNotContained(changedFiles, change.From.Name)
+		changedFiles = AddIfNotContained(changedFiles, change.To.Name)
 	}
 
-	return commitTree
+	return changedFiles, nil
 }
@@ -69,50 +69,3 @@ func TestIsMainlineOrReleaseRef(t *testing.T) {
 		})
 	}
 }
-
-func TestGetBuildTypeByPathFilters(t *testing.T) {
-	type args struct {
-		defaultType        string
-		changedPaths       []string
-		pathFilter         []string
-		allowMultipleTypes bool
-	}
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		{
-			name: "multiple not allowed, no changed paths",
-			args: args{"default", []string{}, []string{"^src.*$=code", "^kubernetes.*$=chart"}, false},
-			want: "default",
-		},
-		{
-			name: "multiple allows, all paths match",
-			args: args{"default", []string{"src/file1.go", "kubernetes/Chart.yaml"}, []string{"^src.*$=code", "^kubernetes.*$=chart"}, true},
-			want: "code;chart",
-		},
-		{
-			name: "multiple not allowed, not all paths match",
-			args: args{"default", []string{"src/file1.go", "kubernetes/Chart.yaml", "other/file"}, []string{"^src.*$=code", "^kubernetes.*$=chart"}, false},
-			want: "default",
-		},
-		{
-			name: "multiple allowed, not all paths match",
-			args: args{"default", []string{"src/file1.go", "kubernetes/Chart.yaml", "other/file"}, []string{"^src.*$=code", "^kubernetes.*$=chart"}, true},
-			want: "default",
-		},
-		{
-			name: "multiple not allowed, all paths match",
-			args: args{"default", []string{"src/file1.go", "kubernetes/Chart.yaml"}, []string{"^src.*$=code", "^kubernetes.*$=chart"}, false},
-			want: "default",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := GetBuildTypeByPathFilters(tt.args.defaultType, tt.args.changedPaths, tt.args.pathFilter, tt.args.allowMultipleTypes); got != tt.want {
-				t.Errorf("GetBuildTypeByPathFilters() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReverseDependencies returns the releases with their dependencies reversed, so that each release
+// depends on the releases which depend on it. Dependencies on releases which are not in the list are dropped
+func ReverseDependencies(releases []ReleaseSpec) []ReleaseSpec {
+	names := map[string]bool{}
+	for _, r := range releases {
+		names[r.ChartName] = true
+	}
+	dependents := map[string][]string{}
+	for _, r := range releases {
+		for _, d := range r.Dependencies {
+			if !names[d] || Contains(dependents[d], r.ChartName) {
+				continue
+			}
+			dependents[d] = append(dependents[d], r.ChartName)
+		}
+	}
+	reversed := make([]ReleaseSpec, 0, len(releases))
+	for _, r := range releases {
+		r.Dependencies = dependents[r.ChartName]
+		reversed = append(reversed, r)
+	}
+	return reversed
+}
+
+// runInDependencyOrder runs an operation on each release once the operation completed on all releases it depends on,
+// with up to parallel operations at a time (0 for full parallelism). Dependencies on releases which are not in the list
+// are ignored. Once an operation fails no more operations are started, unless keepGoing is set, in which case only the operations
+// on releases which depend on the failed release (directly or transitively) are not started. Once the context is done no more
+// operations are started. In any case the running operations are waited for, and the errors of all failed operations are returned
+func runInDependencyOrder(ctx context.Context, releases []ReleaseSpec, parallel int, keepGoing bool, run func(r ReleaseSpec) error) error {
+	if len(releases) == 0 {
+		return nil
+	}
+	if parallel == 0 || parallel > len(releases) {
+		parallel = len(releases)
+	}
+
+	names := map[string]bool{}
+	for _, r := range releases {
+		names[r.ChartName] = true
+	}
+	waitingFor := map[string]int{}
+	dependents := map[string][]ReleaseSpec{}
+	var ready []ReleaseSpec
+	for _, r := range releases {
+		for _, d := range r.Dependencies {
+			if !names[d] {
+				continue
+			}
+			waitingFor[r.ChartName]++
+			dependents[d] = append(dependents[d], r)
+		}
+		if waitingFor[r.ChartName] == 0 {
+			ready = append(ready, r)
+		}
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(releases))
+	errc := make(chan error, len(releases))
+	running, completed := 0, 0
+	for {
+		for len(ready) != 0 && running < parallel && (keepGoing || len(errc) == 0) && ctx.Err() == nil {
+			r := ready[0]
+			ready = ready[1:]
+			running++
+			go func(r ReleaseSpec) {
+				results <- result{name: r.ChartName, err: run(r)}
+			}(r)
+		}
+		if running == 0 {
+			break
+		}
+		res := <-results
+		running--
+		if res.err != nil {
+			errc <- res.err
+			continue
+		}
+		completed++
+		for _, d := range dependents[res.name] {
+			waitingFor[d.ChartName]--
+			if waitingFor[d.ChartName] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	close(errc)
+
+	if err := collectErrors(errc); err != nil {
+		return err
+	}
+	if completed != len(releases) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d releases could not be ordered", ErrCircularDependency, len(releases)-completed)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestReverseDependencies(t *testing.T) {
+	releases := []ReleaseSpec{
+		{ChartName: "cassandra"},
+		{ChartName: "mariadb"},
+		{ChartName: "kaa", Dependencies: []string{"cassandra", "mariadb", "redis"}},
+		{ChartName: "api", Dependencies: []string{"kaa", "cassandra"}},
+	}
+	want := map[string][]string{
+		"cassandra": {"kaa", "api"},
+		"mariadb":   {"kaa"},
+		"kaa":       {"api"},
+		"api":       nil,
+	}
+	for _, r := range ReverseDependencies(releases) {
+		if !reflect.DeepEqual(r.Dependencies, want[r.ChartName]) {
+			t.Errorf("ReverseDependencies() %s depends on %v, want %v", r.ChartName, r.Dependencies, want[r.ChartName])
+		}
+	}
+	if len(releases[2].Dependencies) != 3 {
+		t.Errorf("ReverseDependencies() modified the dependencies of its input: %v", releases[2].Dependencies)
+	}
+}
+
+func TestRunInDependencyOrder(t *testing.T) {
+	releases := []ReleaseSpec{
+		{ChartName: "kaa", Dependencies: []string{"cassandra", "mariadb"}},
+		{ChartName: "cassandra"},
+		{ChartName: "mariadb", Dependencies: []string{"redis"}},
+		{ChartName: "api", Dependencies: []string{"kaa"}},
+	}
+	tests := []struct {
+		name      string
+		releases  []ReleaseSpec
+		parallel  int
+		keepGoing bool
+		fail      string
+		wantRun   []string
+		wantErr   error
+	}{
+		{
+			name:     "sequential",
+			releases: releases,
+			parallel: 1,
+			wantRun:  []string{"cassandra", "mariadb", "kaa", "api"},
+		},
+		{
+			name:     "full parallelism",
+			releases: releases,
+			wantRun:  []string{"cassandra", "mariadb", "kaa", "api"},
+		},
+		{
+			name:     "failure stops dependents",
+			releases: releases,
+			parallel: 1,
+			fail:     "mariadb",
+			wantRun:  []string{"cassandra", "mariadb"},
+		},
+		{
+			name:     "failure stops independent releases",
+			releases: releases,
+			parallel: 1,
+			fail:     "cassandra",
+			wantRun:  []string{"cassandra"},
+		},
+		{
+			name:      "keep going after failure",
+			releases:  releases,
+			parallel:  1,
+			keepGoing: true,
+			fail:      "cassandra",
+			wantRun:   []string{"cassandra", "mariadb"},
+		},
+		{
+			name:     "circular dependency",
+			releases: []ReleaseSpec{{ChartName: "a", Dependencies: []string{"b"}}, {ChartName: "b", Dependencies: []string{"a"}}},
+			wantErr:  ErrCircularDependency,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var run []string
+			err := runInDependencyOrder(context.Background(), tt.releases, tt.parallel, tt.keepGoing, func(r ReleaseSpec) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				for _, d := range r.Dependencies {
+					if GetChartIndex(tt.releases, d) != -1 && !Contains(run, d) {
+						t.Errorf("%s ran before its dependency %s", r.ChartName, d)
+					}
+				}
+				run = append(run, r.ChartName)
+				if r.ChartName == tt.fail {
+					return errors.New("failed")
+				}
+				return nil
+			})
+			if tt.fail != "" {
+				if err == nil {
+					t.Fatal("runInDependencyOrder() expected an error")
+				}
+			} else if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("runInDependencyOrder() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("runInDependencyOrder() error = %v", err)
+			}
+			if tt.wantRun != nil && len(run) != len(tt.wantRun) {
+				t.Errorf("runInDependencyOrder() ran %v, want %v", run, tt.wantRun)
+			}
+			if tt.parallel == 1 && tt.wantRun != nil && !reflect.DeepEqual(run, tt.wantRun) {
+				t.Errorf("runInDependencyOrder() ran %v, want %v", run, tt.wantRun)
+			}
+		})
+	}
+}
+
+func TestRunInDependencyOrder_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	releases := []ReleaseSpec{{ChartName: "cassandra"}, {ChartName: "kaa", Dependencies: []string{"cassandra"}}}
+	var run []string
+	err := runInDependencyOrder(ctx, releases, 1, false, func(r ReleaseSpec) error {
+		run = append(run, r.ChartName)
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("runInDependencyOrder() error = %v, want %v", err, context.Canceled)
+	}
+	if want := []string{"cassandra"}; !reflect.DeepEqual(run, want) {
+		t.Errorf("runInDependencyOrder() ran %v, want %v", run, want)
+	}
+}
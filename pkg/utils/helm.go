@@ -1,17 +1,22 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
-	"math"
 	"os"
+	"sort"
+	"strings"
 	"sync"
-	"time"
+
+	"k8s.io/client-go/kubernetes"
 )
 
 // DeployChartsFromRepositoryOptions are options passed to DeployChartsFromRepository
 type DeployChartsFromRepositoryOptions struct {
+	Helm              HelmClient
 	ReleasesToInstall []ReleaseSpec
 	KubeContext       string
 	Namespace         string
@@ -23,172 +28,116 @@ type DeployChartsFromRepositoryOptions struct {
 	Inject            bool
 	Parallel          int
 	Timeout           int
+	Out               io.Writer
+	LogDir            string
 }
 
-// DeployChartsFromRepository deploys a list of Helm charts from a repository in parallel
-func DeployChartsFromRepository(o DeployChartsFromRepositoryOptions) error {
-	releasesToInstall := o.ReleasesToInstall
-	if len(releasesToInstall) == 0 {
-		return nil
-	}
-	parallel := o.Parallel
-
-	totalReleases := len(releasesToInstall)
-	if parallel == 0 {
-		parallel = totalReleases
-	}
-	bwgSize := int(math.Min(float64(parallel), float64(totalReleases))) // Very stingy :)
-	bwg := NewBoundedWaitGroup(bwgSize)
-	errc := make(chan error, 1)
-	var mutex = &sync.Mutex{}
-
-	for len(releasesToInstall) > 0 && len(errc) == 0 {
-
-		for _, r := range releasesToInstall {
-
-			if len(errc) != 0 {
-				break
-			}
-
-			bwg.Add(1)
-			go func(r ReleaseSpec) {
-				defer bwg.Done()
-
-				// If there are (still) any dependencies - leave this chart for a later iteration
-				if len(r.Dependencies) != 0 {
-					return
-				}
-
-				mutex.Lock()
-				// If there has been an error in a concurrent deployment - don`t deploy anymore
-				if len(errc) != 0 {
-					mutex.Unlock()
-					return
-				}
-				// Find index of chart in slice
-				// may have changed by now since we are using go routines
-				// If chart was not found - another routine is taking care of it
-				index := GetChartIndex(releasesToInstall, r.ChartName)
-				if index == -1 {
-					mutex.Unlock()
-					return
-				}
-				releasesToInstall = RemoveChartFromCharts(releasesToInstall, index)
-				mutex.Unlock()
-
-				// deploy chart
-				log.Println("deploying chart", r.ChartName, "version", r.ChartVersion)
-				if err := DeployChartFromRepository(DeployChartFromRepositoryOptions{
-					ReleaseName:  r.ReleaseName,
-					Name:         r.ChartName,
-					Version:      r.ChartVersion,
-					KubeContext:  o.KubeContext,
-					Namespace:    o.Namespace,
-					Repo:         o.Repo,
-					TLS:          o.TLS,
-					HelmTLSStore: o.HelmTLSStore,
-					PackedValues: o.PackedValues,
-					SetValues:    o.SetValues,
-					IsIsolated:   false,
-					Inject:       o.Inject,
-					Timeout:      o.Timeout,
-				}); err != nil {
-					log.Println("failed deploying chart", r.ChartName, "version", r.ChartVersion)
-					errc <- err
-					return
-				}
-				log.Println("deployed chart", r.ChartName, "version", r.ChartVersion)
-
-				// Deployment is done, remove chart from dependencies
-				mutex.Lock()
-				releasesToInstall = RemoveChartFromDependencies(releasesToInstall, r.ChartName)
-				mutex.Unlock()
-			}(r)
-
-		}
-		time.Sleep(5 * time.Second)
-	}
-	bwg.Wait()
-
-	if len(errc) != 0 {
-		// This is not exactly the correct behavior
-		// There may be more than 1 error in the channel
-		// But first let's make it work
-		err := <-errc
-		close(errc)
+// DeployChartsFromRepository deploys a list of Helm charts from a repository in parallel.
+// A chart is deployed only after all charts in the list which it depends on were deployed
+func DeployChartsFromRepository(ctx context.Context, o DeployChartsFromRepositoryOptions) error {
+	out := newSyncWriter(o.Out)
+
+	return runInDependencyOrder(ctx, o.ReleasesToInstall, o.Parallel, false, func(r ReleaseSpec) error {
+		log.Println("deploying chart", r.ChartName, "version", r.ChartVersion)
+		releaseOut, err := NewReleaseOutput(ReleaseOutputOptions{
+			ReleaseName: r.ReleaseName,
+			Out:         out,
+			LogDir:      o.LogDir,
+		})
 		if err != nil {
 			return err
 		}
-	}
-
-	return nil
+		err = DeployChartFromRepository(ctx, DeployChartFromRepositoryOptions{
+			Helm:         o.Helm,
+			ReleaseName:  r.ReleaseName,
+			Name:         r.ChartName,
+			Version:      r.ChartVersion,
+			KubeContext:  o.KubeContext,
+			Namespace:    o.Namespace,
+			Repo:         o.Repo,
+			TLS:          o.TLS,
+			HelmTLSStore: o.HelmTLSStore,
+			PackedValues: o.PackedValues,
+			SetValues:    o.SetValues,
+			IsIsolated:   false,
+			Inject:       o.Inject,
+			Timeout:      o.Timeout,
+			Out:          releaseOut,
+		})
+		logFile := releaseOut.LogFile()
+		releaseOut.Close()
+		if err != nil {
+			log.Println("failed deploying chart", r.ChartName, "version", r.ChartVersion)
+			return &ReleaseError{
+				ReleaseName: r.ReleaseName,
+				Err:         err,
+				LogTail:     releaseOut.Tail(),
+				LogFile:     logFile,
+			}
+		}
+		log.Println("deployed chart", r.ChartName, "version", r.ChartVersion)
+		return nil
+	})
 }
 
 // DeleteReleasesOptions are options passed to DeleteReleases
 type DeleteReleasesOptions struct {
+	Helm             HelmClient
 	ReleasesToDelete []ReleaseSpec
 	KubeContext      string
 	TLS              bool
 	HelmTLSStore     string
 	Parallel         int
 	Timeout          int
+	Out              io.Writer
+	LogDir           string
 }
 
-// DeleteReleases deletes a list of releases in parallel
-func DeleteReleases(o DeleteReleasesOptions) error {
-	releasesToDelete := o.ReleasesToDelete
-	if len(releasesToDelete) == 0 {
-		return nil
-	}
-	parallel := o.Parallel
-
+// DeleteReleases deletes a list of releases in parallel. Releases are deleted in reverse order of their
+// dependencies: a release is deleted only after all releases in the list which depend on it were deleted.
+// When a release fails to delete, the releases it depends on are kept and the other releases are still deleted
+func DeleteReleases(ctx context.Context, o DeleteReleasesOptions) error {
 	print := false
-	totalReleases := len(releasesToDelete)
-	if parallel == 0 {
-		parallel = totalReleases
-	}
-	bwgSize := int(math.Min(float64(parallel), float64(totalReleases))) // Very stingy :)
-	bwg := NewBoundedWaitGroup(bwgSize)
-	errc := make(chan error, 1)
-
-	for _, r := range releasesToDelete {
-		bwg.Add(1)
-		go func(r ReleaseSpec) {
-			defer bwg.Done()
-			log.Println("deleting", r.ReleaseName)
-			if err := DeleteRelease(DeleteReleaseOptions{
-				ReleaseName:  r.ReleaseName,
-				KubeContext:  o.KubeContext,
-				TLS:          o.TLS,
-				HelmTLSStore: o.HelmTLSStore,
-				Timeout:      o.Timeout,
-				Print:        print,
-			}); err != nil {
-				log.Println("failed deleting chart", r.ReleaseName)
-				errc <- err
-				return
-			}
-			log.Println("deleted", r.ReleaseName)
-		}(r)
-	}
-	bwg.Wait()
-
-	if len(errc) != 0 {
-		// This is not exactly the correct behavior
-		// There may be more than 1 error in the channel
-		// But first let's make it work
-		err := <-errc
-		close(errc)
+	out := newSyncWriter(o.Out)
+
+	return runInDependencyOrder(ctx, ReverseDependencies(o.ReleasesToDelete), o.Parallel, true, func(r ReleaseSpec) error {
+		log.Println("deleting", r.ReleaseName)
+		releaseOut, err := NewReleaseOutput(ReleaseOutputOptions{
+			ReleaseName: r.ReleaseName,
+			Out:         out,
+			LogDir:      o.LogDir,
+		})
 		if err != nil {
 			return err
 		}
-	}
-
-	return nil
+		err = o.Helm.DeleteRelease(ctx, DeleteReleaseOptions{
+			ReleaseName:  r.ReleaseName,
+			KubeContext:  o.KubeContext,
+			TLS:          o.TLS,
+			HelmTLSStore: o.HelmTLSStore,
+			Timeout:      o.Timeout,
+			Print:        print,
+			Out:          releaseOut,
+		})
+		logFile := releaseOut.LogFile()
+		releaseOut.Close()
+		if err != nil {
+			log.Println("failed deleting chart", r.ReleaseName)
+			return &ReleaseError{
+				ReleaseName: r.ReleaseName,
+				Err:         err,
+				LogTail:     releaseOut.Tail(),
+				LogFile:     logFile,
+			}
+		}
+		log.Println("deleted", r.ReleaseName)
+		return nil
+	})
 }
 
 // DeployChartFromRepositoryOptions are options passed to DeployChartFromRepository
 type DeployChartFromRepositoryOptions struct {
+	Helm         HelmClient
 	ReleaseName  string
 	Name         string
 	Version      string
@@ -203,10 +152,12 @@ type DeployChartFromRepositoryOptions struct {
 	Inject       bool
 	Timeout      int
 	Validate     bool
+	ClientSet    kubernetes.Interface
+	Out          io.Writer
 }
 
 // DeployChartFromRepository deploys a Helm chart from a chart repository
-func DeployChartFromRepository(o DeployChartFromRepositoryOptions) error {
+func DeployChartFromRepository(ctx context.Context, o DeployChartFromRepositoryOptions) error {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
 		return fmt.Errorf("failed to create tmp dir")
@@ -218,42 +169,44 @@ func DeployChartFromRepository(o DeployChartFromRepositoryOptions) error {
 		o.ReleaseName = o.Name
 	}
 	if o.IsIsolated {
-		if err := AddRepository(AddRepositoryOptions{
+		if err := o.Helm.AddRepository(ctx, AddRepositoryOptions{
 			Repo:  o.Repo,
 			Print: o.IsIsolated,
 		}); err != nil {
 			return err
 		}
-		if err := UpdateRepositories(o.IsIsolated); err != nil {
+		if err := o.Helm.UpdateRepositories(ctx, o.IsIsolated); err != nil {
 			return err
 		}
 	}
-	if err := FetchChart(FetchChartOptions{
+	if err := o.Helm.FetchChart(ctx, FetchChartOptions{
 		Repo:    o.Repo,
 		Name:    o.Name,
 		Version: o.Version,
 		Dir:     tempDir,
 		Print:   o.IsIsolated,
+		Out:     o.Out,
 	}); err != nil {
 		return err
 	}
 
 	path := fmt.Sprintf("%s/%s", tempDir, o.Name)
-	if err := UpdateChartDependencies(UpdateChartDependenciesOptions{
+	if err := o.Helm.UpdateChartDependencies(ctx, UpdateChartDependenciesOptions{
 		Path:  path,
 		Print: o.IsIsolated,
+		Out:   o.Out,
 	}); err != nil {
 		return err
 	}
 	valuesChain := createValuesChain(o.Name, tempDir, o.PackedValues)
 	setChain := createSetChain(o.Name, o.SetValues)
 
-	if err := UpgradeRelease(UpgradeReleaseOptions{
+	if _, err := o.Helm.UpgradeRelease(ctx, UpgradeReleaseOptions{
 		Name:         o.Name,
 		ReleaseName:  o.ReleaseName,
 		KubeContext:  o.KubeContext,
 		Namespace:    o.Namespace,
-		Values:       valuesChain,
+		ValuesFiles:  valuesChain,
 		Set:          setChain,
 		TLS:          o.TLS,
 		HelmTLSStore: o.HelmTLSStore,
@@ -261,6 +214,7 @@ func DeployChartFromRepository(o DeployChartFromRepositoryOptions) error {
 		Print:        o.IsIsolated,
 		Inject:       o.Inject,
 		Timeout:      o.Timeout,
+		Out:          o.Out,
 	}); err != nil {
 		return err
 	}
@@ -268,7 +222,7 @@ func DeployChartFromRepository(o DeployChartFromRepositoryOptions) error {
 	if !o.Validate {
 		return nil
 	}
-	envValid, err := IsEnvValidWithLoopBackOff(o.Namespace, o.KubeContext)
+	envValid, err := IsEnvValidWithLoopBackOff(ctx, o.ClientSet, o.Namespace)
 	if err != nil {
 		return err
 	}
@@ -283,86 +237,287 @@ func DeployChartFromRepository(o DeployChartFromRepositoryOptions) error {
 
 // PushChartToRepositoryOptions are options passed to PushChartToRepository
 type PushChartToRepositoryOptions struct {
+	Helm   HelmClient
 	Path   string
 	Append string
-	Repo   string
-	Lint   bool
-	Print  bool
+	// Edit is applied to the chart (e.g. its appVersion). Append is appended to its version unless the version is set
+	Edit ChartEdit
+	Repo string
+	// Publisher pushes the packaged chart instead of the Helm client (optional)
+	Publisher ChartPublisher
+	// Checker checks if the chart version exists in the repository before pushing it (optional)
+	Checker ChartVersionChecker
+	// SkipExisting skips pushing a chart version which exists in the repository instead of failing
+	SkipExisting bool
+	// Force pushes the chart by the Helm client even if its version exists in the repository (the Publisher is forced by its options)
+	Force bool
+	// PackageOnly packages the chart to Destination without pushing it
+	PackageOnly bool
+	Destination string
+	Lint        bool
+	// Policies configure the policy checks of lint
+	Policies PolicyOptions
+	Print    bool
 }
 
 // PushChartToRepository packages and pushes a Helm chart to a chart repository
-func PushChartToRepository(o PushChartToRepositoryOptions) error {
-	newVersion := UpdateChartVersion(o.Path, o.Append)
-	if o.Lint {
-		if err := Lint(LintOptions{
-			Path:  o.Path,
-			Print: o.Print,
-		}); err != nil {
+func PushChartToRepository(ctx context.Context, o PushChartToRepositoryOptions) error {
+	chart, _, err := readLocalChart(o.Path)
+	if err != nil {
+		return err
+	}
+	// The chart is checked against the repository by its new version before it is edited,
+	// so a chart which is not pushed is left unchanged
+	edit := o.Edit
+	if edit.Version == "" && o.Append != "" {
+		edit.Version = fmt.Sprintf("%s-%s", chart.Version, o.Append)
+	}
+	newVersion := chart.Version
+	if edit.Version != "" {
+		newVersion = edit.Version
+	}
+	if o.Checker != nil {
+		exists, err := o.Checker.Exists(ctx, chart.Name, newVersion)
+		if err != nil {
+			return err
+		}
+		if exists && !o.SkipExisting {
+			return fmt.Errorf("%w: chart %s version %s is in %s", ErrChartVersionExists, chart.Name, newVersion, o.Repo)
+		}
+		if exists {
+			log.Println("chart", chart.Name, "version", newVersion, "already exists in the repository, skipping")
+			fmt.Println(newVersion)
+			return nil
+		}
+	}
+	if edit.Version != "" || edit.AppVersion != "" || len(edit.Dependencies) != 0 || len(edit.Annotations) != 0 {
+		if newVersion, err = EditChart(o.Path, edit); err != nil {
 			return err
 		}
 	}
-	if err := AddRepository(AddRepositoryOptions{
-		Repo:  o.Repo,
-		Print: o.Print,
-	}); err != nil {
+	if err := addChartRepository(ctx, o.Helm, o.Repo, o.Print); err != nil {
 		return err
 	}
-	if err := UpdateChartDependencies(UpdateChartDependenciesOptions{
-		Path:  o.Path,
-		Print: o.Print,
-	}); err != nil {
+	if err := prepareChart(ctx, o.Helm, o.Path, o.Lint, o.Policies, o.Print); err != nil {
 		return err
 	}
-	if err := PushChart(PushChartOptions{
-		Repo:  o.Repo,
-		Path:  o.Path,
-		Print: o.Print,
-	}); err != nil {
+
+	if o.PackageOnly {
+		packaged, err := PackageChart(o.Path, o.Destination)
+		if err != nil {
+			return err
+		}
+		fmt.Println(packaged.Path)
+		fmt.Println(packaged.Digest)
+		return nil
+	}
+	if err := publishChart(ctx, o.Helm, o.Path, o.Repo, o.Publisher, o.Force, o.Print); err != nil {
 		return err
 	}
 	fmt.Println(newVersion)
 	return nil
 }
 
-// LintOptions are options passed to Lint
-type LintOptions struct {
-	Path  string
-	Print bool
+// PushChartsToRepositoryOptions are options passed to PushChartsToRepository
+type PushChartsToRepositoryOptions struct {
+	Helm   HelmClient
+	Charts []LocalChart
+	Append string
+	// Versions are versions to set by chart name, instead of appending to their versions
+	Versions   map[string]string
+	AppVersion string
+	Repo       string
+	// Publisher pushes the packaged charts instead of the Helm client (optional)
+	Publisher ChartPublisher
+	// Checker checks if chart versions exist in the repository, to skip pushing them (optional)
+	Checker ChartVersionChecker
+	Lint    bool
+	// Policies configure the policy checks of lint
+	Policies PolicyOptions
+	Parallel int
+	Print    bool
 }
 
-// Lint takes a path to a chart and runs a series of tests to verify that the chart is well-formed
-func Lint(o LintOptions) error {
-	cmd := []string{"helm", "lint", o.Path}
-	err := PrintExec(cmd, o.Print)
-
-	return err
+// PushedChart is a chart handled by PushChartsToRepository
+type PushedChart struct {
+	Name    string `yaml:"name" json:"name"`
+	Version string `yaml:"version" json:"version"`
+	Path    string `yaml:"path" json:"path"`
+	// Skipped is true if the version already exists in the repository
+	Skipped bool `yaml:"skipped" json:"skipped"`
 }
 
-// AddRepositoryOptions are options passed to AddRepository
-type AddRepositoryOptions struct {
-	Repo  string
-	Print bool
+// PushChartsToRepository versions, lints, packages and pushes local charts to a chart repository in parallel.
+// A chart is pushed only after all charts which it depends on (file:// dependencies) were versioned, and the versions of
+// its local dependencies are set to their new versions. Charts whose version already exists in the repository are skipped
+func PushChartsToRepository(ctx context.Context, o PushChartsToRepositoryOptions) ([]PushedChart, error) {
+	releases := make([]ReleaseSpec, 0, len(o.Charts))
+	charts := map[string]LocalChart{}
+	for _, c := range o.Charts {
+		releases = append(releases, ReleaseSpec{ChartName: c.Name, ChartVersion: c.Version, Dependencies: c.Dependencies})
+		charts[c.Name] = c
+	}
+	ordered := make([]ReleaseSpec, len(releases))
+	for i, r := range releases {
+		ordered[i] = r
+		ordered[i].Dependencies = append([]string{}, r.Dependencies...)
+	}
+	if CheckCircularDependencies(ordered) {
+		return nil, ErrCircularDependency
+	}
+
+	// The repository is added and its index is updated once for all charts
+	if err := addChartRepository(ctx, o.Helm, o.Repo, o.Print); err != nil {
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	versions := map[string]string{}
+	var pushed []PushedChart
+	err := runInDependencyOrder(ctx, releases, o.Parallel, false, func(r ReleaseSpec) error {
+		c := charts[r.ChartName]
+		edit := ChartEdit{Version: o.Versions[c.Name], AppVersion: o.AppVersion, Dependencies: map[string]string{}}
+		if edit.Version == "" && o.Append != "" {
+			edit.Version = fmt.Sprintf("%s-%s", c.Version, o.Append)
+		}
+		mutex.Lock()
+		for _, d := range c.Dependencies {
+			if versions[d] != charts[d].Version {
+				edit.Dependencies[d] = versions[d]
+			}
+		}
+		mutex.Unlock()
+
+		version := c.Version
+		if edit.Version != "" {
+			version = edit.Version
+		}
+		result := PushedChart{Name: c.Name, Version: version, Path: c.Path}
+		record := func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			versions[c.Name] = version
+			pushed = append(pushed, result)
+		}
+
+		if o.Checker != nil {
+			exists, err := o.Checker.Exists(ctx, c.Name, version)
+			if err != nil {
+				return fmt.Errorf("chart %s: %w", c.Name, err)
+			}
+			if exists {
+				log.Println("chart", c.Name, "version", version, "already exists in the repository, skipping")
+				result.Skipped = true
+				record()
+				return nil
+			}
+		}
+		// Charts are edited after they are checked against the repository, so skipped charts are left unchanged
+		if edit.Version != "" || edit.AppVersion != "" || len(edit.Dependencies) != 0 {
+			if _, err := EditChart(c.Path, edit); err != nil {
+				return fmt.Errorf("chart %s: %w", c.Name, err)
+			}
+		}
+
+		log.Println("pushing chart", c.Name, "version", version)
+		if err := prepareChart(ctx, o.Helm, c.Path, o.Lint, o.Policies, o.Print); err != nil {
+			log.Println("failed pushing chart", c.Name, "version", version)
+			return fmt.Errorf("chart %s: %w", c.Name, err)
+		}
+		if err := publishChart(ctx, o.Helm, c.Path, o.Repo, o.Publisher, false, o.Print); err != nil {
+			log.Println("failed pushing chart", c.Name, "version", version)
+			return fmt.Errorf("chart %s: %w", c.Name, err)
+		}
+		log.Println("pushed chart", c.Name, "version", version)
+		record()
+		return nil
+	})
+
+	// Charts are returned in the order they were passed in
+	index := map[string]int{}
+	for i, c := range o.Charts {
+		index[c.Name] = i
+	}
+	sort.Slice(pushed, func(i, j int) bool { return index[pushed[i].Name] < index[pushed[j].Name] })
+	return pushed, err
 }
 
-// AddRepository adds a chart repository to the repositories file
-func AddRepository(o AddRepositoryOptions) error {
-	repoName, repoURL := SplitInTwo(o.Repo, "=")
+// addChartRepository adds a chart repository to update dependencies from it, unless it is not a Helm repository
+func addChartRepository(ctx context.Context, helm HelmClient, repo string, print bool) error {
+	if name, repoURL := SplitRepo(repo); name == "" || strings.HasPrefix(repoURL, ociRepositoryURLPrefix) {
+		return nil
+	}
+	return helm.AddRepository(ctx, AddRepositoryOptions{
+		Repo:  repo,
+		Print: print,
+	})
+}
 
-	cmd := []string{
-		"helm", "repo",
-		"add", repoName, repoURL,
+// prepareChart updates the dependencies of a chart and lints it (if required): helm lint and the policy checks of LintChart
+func prepareChart(ctx context.Context, helm HelmClient, path string, lint bool, policies PolicyOptions, print bool) error {
+	if err := helm.UpdateChartDependencies(ctx, UpdateChartDependenciesOptions{
+		Path:  path,
+		Print: print,
+	}); err != nil {
+		return err
+	}
+	if !lint {
+		return nil
+	}
+	if err := helm.Lint(ctx, LintOptions{
+		Path:  path,
+		Print: print,
+	}); err != nil {
+		return err
 	}
-	err := PrintExec(cmd, o.Print)
+	result, err := LintChart(LintChartOptions{
+		Path:     path,
+		Policies: policies,
+	})
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
 
-	return err
+// publishChart packages and pushes a chart using a publisher, or pushes it using the Helm client if there is no publisher
+func publishChart(ctx context.Context, helm HelmClient, path, repo string, publisher ChartPublisher, force, print bool) error {
+	if publisher == nil {
+		return helm.PushChart(ctx, PushChartOptions{
+			Repo:  repo,
+			Path:  path,
+			Force: force,
+			Print: print,
+		})
+	}
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	packaged, err := PackageChart(path, tempDir)
+	if err != nil {
+		return err
+	}
+	if err := publisher.Publish(ctx, packaged); err != nil {
+		return err
+	}
+	if print {
+		fmt.Printf("Pushed %s (%s) to %s\n", path, packaged.Digest, repo)
+	}
+	return nil
 }
 
-// UpdateRepositories updates helm repositories
-func UpdateRepositories(print bool) error {
-	cmd := []string{"helm", "repo", "update"}
-	err := PrintExec(cmd, print)
+// LintOptions are options passed to Lint
+type LintOptions struct {
+	Path  string
+	Print bool
+}
 
-	return err
+// AddRepositoryOptions are options passed to AddRepository
+type AddRepositoryOptions struct {
+	Repo  string
+	Print bool
 }
 
 // FetchChartOptions are options passed to FetchChart
@@ -372,53 +527,23 @@ type FetchChartOptions struct {
 	Version string
 	Dir     string
 	Print   bool
-}
-
-// FetchChart fetches a chart from chart repository by name and version and untars it in the local directory
-func FetchChart(o FetchChartOptions) error {
-	repoName, _ := SplitInTwo(o.Repo, "=")
-
-	cmd := []string{
-		"helm", "fetch",
-		fmt.Sprintf("%s/%s", repoName, o.Name),
-		"--version", o.Version,
-		"--untar",
-		"-d", o.Dir,
-	}
-	err := PrintExec(cmd, o.Print)
-
-	return err
+	Out     io.Writer
 }
 
 // PushChartOptions are options passed to PushChart
 type PushChartOptions struct {
-	Repo  string
-	Path  string
+	Repo string
+	Path string
+	// Force overwrites the chart version if it already exists in the repository
+	Force bool
 	Print bool
 }
 
-// PushChart pushes a helm chart to a chart repository
-func PushChart(o PushChartOptions) error {
-	repoName, _ := SplitInTwo(o.Repo, "=")
-
-	cmd := []string{"helm", "push", o.Path, repoName}
-	err := PrintExec(cmd, o.Print)
-
-	return err
-}
-
 // UpdateChartDependenciesOptions are options passed to UpdateChartDependencies
 type UpdateChartDependenciesOptions struct {
 	Path  string
 	Print bool
-}
-
-// UpdateChartDependencies performs helm dependency update
-func UpdateChartDependencies(o UpdateChartDependenciesOptions) error {
-	cmd := []string{"helm", "dependency", "update", o.Path}
-	err := PrintExec(cmd, o.Print)
-
-	return err
+	Out   io.Writer
 }
 
 // UpgradeReleaseOptions are options passed to UpgradeRelease
@@ -427,7 +552,7 @@ type UpgradeReleaseOptions struct {
 	ReleaseName  string
 	KubeContext  string
 	Namespace    string
-	Values       []string
+	ValuesFiles  []string
 	Set          []string
 	TLS          bool
 	HelmTLSStore string
@@ -435,30 +560,7 @@ type UpgradeReleaseOptions struct {
 	Print        bool
 	Inject       bool
 	Timeout      int
-}
-
-// UpgradeRelease performs helm upgrade -i
-func UpgradeRelease(o UpgradeReleaseOptions) error {
-	cmd := []string{"helm"}
-	kubeContextFlag := "--kube-context"
-	if o.Inject {
-		kubeContextFlag = "--kubecontext"
-		cmd = append(cmd, "inject")
-	}
-	cmd = append(cmd, "upgrade", "-i", o.ReleaseName, fmt.Sprintf("%s/%s", o.Dir, o.Name))
-	if o.KubeContext != "" {
-		cmd = append(cmd, kubeContextFlag, o.KubeContext)
-	}
-	if o.Namespace != "" {
-		cmd = append(cmd, "--namespace", o.Namespace)
-	}
-	cmd = append(cmd, o.Values...)
-	cmd = append(cmd, o.Set...)
-	cmd = append(cmd, "--timeout", fmt.Sprintf("%d", o.Timeout))
-	cmd = append(cmd, getTLS(o.TLS, o.KubeContext, o.HelmTLSStore)...)
-	err := PrintExec(cmd, o.Print)
-
-	return err
+	Out          io.Writer
 }
 
 // DeleteReleaseOptions are options passed to DeleteRelease
@@ -469,21 +571,7 @@ type DeleteReleaseOptions struct {
 	HelmTLSStore string
 	Timeout      int
 	Print        bool
-}
-
-// DeleteRelease deletes a release from Kubernetes
-func DeleteRelease(o DeleteReleaseOptions) error {
-	cmd := []string{
-		"helm", "delete", o.ReleaseName, "--purge",
-		"--timeout", fmt.Sprintf("%d", o.Timeout),
-	}
-	if o.KubeContext != "" {
-		cmd = append(cmd, "--kube-context", o.KubeContext)
-	}
-	cmd = append(cmd, getTLS(o.TLS, o.KubeContext, o.HelmTLSStore)...)
-	err := PrintExec(cmd, o.Print)
-
-	return err
+	Out          io.Writer
 }
 
 // createValuesChain will create a chain of values files to use
@@ -491,7 +579,7 @@ func createValuesChain(name, dir string, packedValues []string) []string {
 	var values []string
 	fileToTest := fmt.Sprintf("%s/%s/%s", dir, name, "values.yaml")
 	if _, err := os.Stat(fileToTest); err == nil {
-		values = append(values, "-f", fileToTest)
+		values = append(values, fileToTest)
 	} else {
 		log.Printf("WARNING: regular values.yaml file not found in chart\n")
 	}
@@ -501,7 +589,7 @@ func createValuesChain(name, dir string, packedValues []string) []string {
 				continue
 			}
 			log.Printf("INFO: values file %s found in working directory\n", v)
-			values = append(values, "-f", v)
+			values = append(values, v)
 			continue
 		}
 		fileToTest = fmt.Sprintf("%s/%s/%s", dir, name, v)
@@ -510,7 +598,7 @@ func createValuesChain(name, dir string, packedValues []string) []string {
 				continue
 			}
 			log.Printf("INFO: values file %s found in chart\n", fileToTest)
-			values = append(values, "-f", fileToTest)
+			values = append(values, fileToTest)
 			continue
 		}
 		log.Printf("WARNING: values file %s not found in working directory or chart\n", v)
@@ -520,21 +608,6 @@ func createValuesChain(name, dir string, packedValues []string) []string {
 
 // createSetChain will create a chain of sets to use
 func createSetChain(name string, inputSet []string) []string {
-	set := []string{"--set", fmt.Sprintf("fullnameOverride=%s", name)}
-	for _, s := range inputSet {
-		set = append(set, "--set", s)
-	}
-	return set
-}
-
-func getTLS(tls bool, kubeContext, helmTLSStore string) []string {
-	var tlsStr []string
-	if tls == true {
-		tlsStr = []string{
-			"--tls",
-			"--tls-cert", fmt.Sprintf("%s/%s.cert.pem", helmTLSStore, kubeContext),
-			"--tls-key", fmt.Sprintf("%s/%s.key.pem", helmTLSStore, kubeContext),
-		}
-	}
-	return tlsStr
+	set := []string{fmt.Sprintf("fullnameOverride=%s", name)}
+	return append(set, inputSet...)
 }
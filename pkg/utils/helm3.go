@@ -22,7 +22,7 @@ import (
 
 // GetInstalledReleasesOptions are options passed to GetInstalledReleases
 type GetInstalledReleasesOptions struct {
-	KubeContext   string
+	ClientSet     kubernetes.Interface
 	Namespace     string
 	IncludeFailed bool
 }
@@ -30,18 +30,18 @@ type GetInstalledReleasesOptions struct {
 // GetInstalledReleases gets the installed Helm releases in a given namespace
 func GetInstalledReleases(o GetInstalledReleasesOptions) ([]ReleaseSpec, error) {
 
-	tillerNamespace := "kube-system"
+	tillerNamespace := tillerNamespace()
 	labels := "OWNER=TILLER,STATUS in (DEPLOYED,FAILED)"
 	if !o.IncludeFailed {
 		labels = strings.Replace(labels, "FAILED", "", -1)
 	}
-	storage, err := getTillerStorage(o.KubeContext, tillerNamespace)
+	storage, err := getTillerStorage(o.ClientSet, tillerNamespace)
 	if err != nil {
 		return nil, err
 	}
 
 	var releaseSpecs []ReleaseSpec
-	list, err := listReleases(o.KubeContext, o.Namespace, storage, tillerNamespace, labels)
+	list, err := listReleases(o.ClientSet, o.Namespace, storage, tillerNamespace, labels)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +61,7 @@ func GetInstalledReleases(o GetInstalledReleasesOptions) ([]ReleaseSpec, error)
 	}
 
 	if !o.IncludeFailed {
-		return releaseSpecs, nil
+		return withRecordedDependencies(o.ClientSet, o.Namespace, releaseSpecs)
 	}
 
 	for _, releaseData := range list {
@@ -88,14 +88,27 @@ func GetInstalledReleases(o GetInstalledReleasesOptions) ([]ReleaseSpec, error)
 		releaseSpecs = append(releaseSpecs, releaseSpec)
 	}
 
-	return releaseSpecs, nil
+	return withRecordedDependencies(o.ClientSet, o.Namespace, releaseSpecs)
 }
 
-func getTillerStorage(kubeContext, tillerNamespace string) (string, error) {
-	clientset, err := getClientSet(kubeContext)
+// withRecordedDependencies sets the dependencies of releases to the dependencies recorded in the environment
+func withRecordedDependencies(clientset kubernetes.Interface, namespace string, releases []ReleaseSpec) ([]ReleaseSpec, error) {
+	if len(releases) == 0 {
+		return releases, nil
+	}
+	metadata, err := GetEnvironmentMetadata(clientset, namespace)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for i, r := range releases {
+		if deps := metadata.Dependencies[r.ChartName]; len(deps) != 0 {
+			releases[i].Dependencies = append([]string{}, deps...)
+		}
 	}
+	return releases, nil
+}
+
+func getTillerStorage(clientset kubernetes.Interface, tillerNamespace string) (string, error) {
 	coreV1 := clientset.CoreV1()
 	listOptions := metav1.ListOptions{
 		LabelSelector: "name=tiller",
@@ -130,11 +143,7 @@ type releaseData struct {
 	time      time.Time
 }
 
-func listReleases(kubeContext, namespace, storage, tillerNamespace, labels string) ([]releaseData, error) {
-	clientset, err := getClientSet(kubeContext)
-	if err != nil {
-		return nil, err
-	}
+func listReleases(clientset kubernetes.Interface, namespace, storage, tillerNamespace, labels string) ([]releaseData, error) {
 	var releasesData []releaseData
 	coreV1 := clientset.CoreV1()
 	switch storage {
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreateSetChain(t *testing.T) {
+	type args struct {
+		name     string
+		inputSet []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "no additional sets",
+			args: args{"kaa", []string{}},
+			want: []string{"fullnameOverride=kaa"},
+		},
+		{
+			name: "additional sets",
+			args: args{"kaa", []string{"image.tag=1.0.0", "replicas=2"}},
+			want: []string{"fullnameOverride=kaa", "image.tag=1.0.0", "replicas=2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createSetChain(tt.args.name, tt.args.inputSet); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("createSetChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	type args struct {
+		valuesFiles []string
+		set         []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "single values file",
+			args: args{[]string{"testdata/values/base.yaml"}, []string{}},
+			want: "image:\n  repository: nginx\n  tag: \"1.15\"\nreplicas: 1\n",
+		},
+		{
+			name: "values files are merged in order",
+			args: args{[]string{"testdata/values/base.yaml", "testdata/values/override.yaml"}, []string{}},
+			want: "image:\n  repository: nginx\n  tag: \"1.16\"\nreplicas: 1\n",
+		},
+		{
+			name: "set values take precedence",
+			args: args{[]string{"testdata/values/base.yaml"}, []string{"replicas=3", "fullnameOverride=kaa"}},
+			want: "fullnameOverride: kaa\nimage:\n  repository: nginx\n  tag: \"1.15\"\nreplicas: 3\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeValues(tt.args.valuesFiles, tt.args.set)
+			if err != nil {
+				t.Fatalf("mergeValues() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeValues() = %q, want %q", string(got), tt.want)
+			}
+		})
+	}
+}
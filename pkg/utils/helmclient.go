@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// HelmClientSDK is the name of the in-process Helm client
+	HelmClientSDK = "sdk"
+	// HelmClientExec is the name of the Helm client which executes the helm binary
+	HelmClientExec = "exec"
+)
+
+// HelmClient performs Helm operations
+type HelmClient interface {
+	AddRepository(ctx context.Context, o AddRepositoryOptions) error
+	UpdateRepositories(ctx context.Context, print bool) error
+	FetchChart(ctx context.Context, o FetchChartOptions) error
+	UpdateChartDependencies(ctx context.Context, o UpdateChartDependenciesOptions) error
+	UpgradeRelease(ctx context.Context, o UpgradeReleaseOptions) (*HelmRelease, error)
+	DeleteRelease(ctx context.Context, o DeleteReleaseOptions) error
+	Lint(ctx context.Context, o LintOptions) error
+	PushChart(ctx context.Context, o PushChartOptions) error
+}
+
+// NewHelmClient returns a Helm client by its name (sdk, exec)
+func NewHelmClient(name string) (HelmClient, error) {
+	switch name {
+	case HelmClientSDK, "":
+		return NewSDKHelmClient(), nil
+	case HelmClientExec:
+		return NewExecHelmClient(), nil
+	}
+	return nil, fmt.Errorf("unknown helm client \"%s\" (supported: %s, %s)", name, HelmClientSDK, HelmClientExec)
+}
+
+// HelmRelease holds the state of a release as reported by Helm
+type HelmRelease struct {
+	Name         string
+	Namespace    string
+	Revision     int32
+	Status       string
+	ChartName    string
+	ChartVersion string
+}
+
+// HelmError is returned when a Helm operation fails
+type HelmError struct {
+	// Op is the Helm operation which failed (e.g. upgrade)
+	Op string
+	// Target is the release, chart or repository the operation acted on
+	Target string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *HelmError) Error() string {
+	return fmt.Sprintf("helm %s %s failed: %v", e.Op, e.Target, e.Err)
+}
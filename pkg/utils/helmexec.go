@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// execHelmClient performs Helm operations by executing the helm binary
+type execHelmClient struct{}
+
+// NewExecHelmClient returns a Helm client which executes the helm binary (and its plugins)
+func NewExecHelmClient() HelmClient {
+	return &execHelmClient{}
+}
+
+// AddRepository adds a chart repository to the repositories file
+func (c *execHelmClient) AddRepository(ctx context.Context, o AddRepositoryOptions) error {
+	repoName, repoURL, err := SplitInTwo(o.Repo, "=")
+	if err != nil {
+		return &HelmError{Op: "repo add", Target: o.Repo, Err: err}
+	}
+
+	cmd := []string{
+		"helm", "repo",
+		"add", repoName, repoURL,
+	}
+
+	return c.run(ctx, "repo add", repoName, cmd, o.Print, nil)
+}
+
+// UpdateRepositories updates helm repositories
+func (c *execHelmClient) UpdateRepositories(ctx context.Context, print bool) error {
+	cmd := []string{"helm", "repo", "update"}
+
+	return c.run(ctx, "repo update", "repositories", cmd, print, nil)
+}
+
+// FetchChart fetches a chart from chart repository by name and version and untars it in the local directory
+func (c *execHelmClient) FetchChart(ctx context.Context, o FetchChartOptions) error {
+	repoName, _, err := SplitInTwo(o.Repo, "=")
+	if err != nil {
+		return &HelmError{Op: "fetch", Target: o.Name, Err: err}
+	}
+
+	cmd := []string{
+		"helm", "fetch",
+		fmt.Sprintf("%s/%s", repoName, o.Name),
+		"--version", o.Version,
+		"--untar",
+		"-d", o.Dir,
+	}
+
+	return c.run(ctx, "fetch", o.Name, cmd, o.Print, o.Out)
+}
+
+// UpdateChartDependencies performs helm dependency update
+func (c *execHelmClient) UpdateChartDependencies(ctx context.Context, o UpdateChartDependenciesOptions) error {
+	cmd := []string{"helm", "dependency", "update", o.Path}
+
+	return c.run(ctx, "dependency update", o.Path, cmd, o.Print, o.Out)
+}
+
+// UpgradeRelease performs helm upgrade -i
+func (c *execHelmClient) UpgradeRelease(ctx context.Context, o UpgradeReleaseOptions) (*HelmRelease, error) {
+	cmd := []string{"helm"}
+	kubeContextFlag := "--kube-context"
+	if o.Inject {
+		kubeContextFlag = "--kubecontext"
+		cmd = append(cmd, "inject")
+	}
+	cmd = append(cmd, "upgrade", "-i", o.ReleaseName, fmt.Sprintf("%s/%s", o.Dir, o.Name))
+	if o.KubeContext != "" {
+		cmd = append(cmd, kubeContextFlag, o.KubeContext)
+	}
+	if o.Namespace != "" {
+		cmd = append(cmd, "--namespace", o.Namespace)
+	}
+	for _, v := range o.ValuesFiles {
+		cmd = append(cmd, "-f", v)
+	}
+	for _, s := range o.Set {
+		cmd = append(cmd, "--set", s)
+	}
+	cmd = append(cmd, "--timeout", fmt.Sprintf("%d", o.Timeout))
+	cmd = append(cmd, getTLS(o.TLS, o.KubeContext, o.HelmTLSStore)...)
+	if err := c.run(ctx, "upgrade", o.ReleaseName, cmd, o.Print, o.Out); err != nil {
+		return nil, err
+	}
+
+	// The helm binary output is not parsed, report what is known from the request
+	return &HelmRelease{
+		Name:      o.ReleaseName,
+		Namespace: o.Namespace,
+		ChartName: o.Name,
+	}, nil
+}
+
+// DeleteRelease deletes a release from Kubernetes
+func (c *execHelmClient) DeleteRelease(ctx context.Context, o DeleteReleaseOptions) error {
+	cmd := []string{
+		"helm", "delete", o.ReleaseName, "--purge",
+		"--timeout", fmt.Sprintf("%d", o.Timeout),
+	}
+	if o.KubeContext != "" {
+		cmd = append(cmd, "--kube-context", o.KubeContext)
+	}
+	cmd = append(cmd, getTLS(o.TLS, o.KubeContext, o.HelmTLSStore)...)
+
+	return c.run(ctx, "delete", o.ReleaseName, cmd, o.Print, o.Out)
+}
+
+// Lint takes a path to a chart and runs a series of tests to verify that the chart is well-formed
+func (c *execHelmClient) Lint(ctx context.Context, o LintOptions) error {
+	cmd := []string{"helm", "lint", o.Path}
+
+	return c.run(ctx, "lint", o.Path, cmd, o.Print, nil)
+}
+
+// PushChart pushes a helm chart to a chart repository (requires helm push plugin)
+func (c *execHelmClient) PushChart(ctx context.Context, o PushChartOptions) error {
+	repoName, _, err := SplitInTwo(o.Repo, "=")
+	if err != nil {
+		return &HelmError{Op: "push", Target: o.Path, Err: err}
+	}
+
+	cmd := []string{"helm", "push", o.Path, repoName}
+	if o.Force {
+		cmd = append(cmd, "--force")
+	}
+
+	return c.run(ctx, "push", o.Path, cmd, o.Print, nil)
+}
+
+// run executes a helm command. If out is set, the command output is streamed to it
+func (c *execHelmClient) run(ctx context.Context, op, target string, cmd []string, print bool, out io.Writer) error {
+	var err error
+	if out != nil {
+		fmt.Fprintln(out, strings.Join(cmd, " "))
+		err = ExecStream(ctx, cmd, out)
+	} else {
+		err = PrintExec(ctx, cmd, print)
+	}
+	if err != nil {
+		return &HelmError{Op: op, Target: target, Err: err}
+	}
+	return nil
+}
+
+func getTLS(tls bool, kubeContext, helmTLSStore string) []string {
+	var tlsStr []string
+	if tls == true {
+		tlsStr = []string{
+			"--tls",
+			"--tls-cert", fmt.Sprintf("%s/%s.cert.pem", helmTLSStore, kubeContext),
+			"--tls-key", fmt.Sprintf("%s/%s.key.pem", helmTLSStore, kubeContext),
+		}
+	}
+	return tlsStr
+}
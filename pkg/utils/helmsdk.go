@@ -0,0 +1,511 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/downloader"
+	"k8s.io/helm/pkg/getter"
+	"k8s.io/helm/pkg/helm"
+	"k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/helm/helmpath"
+	"k8s.io/helm/pkg/lint"
+	"k8s.io/helm/pkg/lint/support"
+	rspb "k8s.io/helm/pkg/proto/hapi/release"
+	"k8s.io/helm/pkg/repo"
+	"k8s.io/helm/pkg/strvals"
+	"k8s.io/helm/pkg/tlsutil"
+)
+
+const tillerPort = 44134
+
+// sdkHelmClient performs Helm operations in-process using the Helm Go packages
+type sdkHelmClient struct {
+	settings environment.EnvSettings
+	exec     HelmClient
+}
+
+// NewSDKHelmClient returns a Helm client which does not require the helm binary.
+// Operations requiring a Helm plugin (inject) fall back to executing the helm binary.
+func NewSDKHelmClient() HelmClient {
+	return &sdkHelmClient{
+		settings: environment.EnvSettings{
+			Home:            helmpath.Home(GetStringEnvVar("HELM_HOME", environment.DefaultHelmHome)),
+			TillerHost:      os.Getenv("HELM_HOST"),
+			TillerNamespace: tillerNamespace(),
+		},
+		exec: NewExecHelmClient(),
+	}
+}
+
+// AddRepository adds a chart repository to the repositories file
+func (c *sdkHelmClient) AddRepository(ctx context.Context, o AddRepositoryOptions) error {
+	repoName, repoURL, err := SplitInTwo(o.Repo, "=")
+	if err != nil {
+		return &HelmError{Op: "repo add", Target: o.Repo, Err: err}
+	}
+	if err := c.addRepository(repoName, repoURL); err != nil {
+		return &HelmError{Op: "repo add", Target: repoName, Err: err}
+	}
+	if o.Print {
+		fmt.Printf("\"%s\" has been added to your repositories\n", repoName)
+	}
+	return nil
+}
+
+func (c *sdkHelmClient) addRepository(name, url string) error {
+	f, err := c.loadRepositoriesFile()
+	if err != nil {
+		return err
+	}
+	entry := repo.Entry{
+		Name:  name,
+		Cache: c.settings.Home.CacheIndex(name),
+		URL:   url,
+	}
+	r, err := repo.NewChartRepository(&entry, getter.All(c.settings))
+	if err != nil {
+		return err
+	}
+	if err := r.DownloadIndexFile(c.settings.Home.Cache()); err != nil {
+		return fmt.Errorf("\"%s\" is not a valid chart repository or cannot be reached: %v", url, err)
+	}
+	f.Update(&entry)
+
+	return f.WriteFile(c.settings.Home.RepositoryFile(), 0644)
+}
+
+// UpdateRepositories updates helm repositories
+func (c *sdkHelmClient) UpdateRepositories(ctx context.Context, print bool) error {
+	f, err := c.loadRepositoriesFile()
+	if err != nil {
+		return &HelmError{Op: "repo update", Target: "repositories", Err: err}
+	}
+	for _, entry := range f.Repositories {
+		r, err := repo.NewChartRepository(entry, getter.All(c.settings))
+		if err != nil {
+			return &HelmError{Op: "repo update", Target: entry.Name, Err: err}
+		}
+		if err := r.DownloadIndexFile(c.settings.Home.Cache()); err != nil {
+			return &HelmError{Op: "repo update", Target: entry.Name, Err: err}
+		}
+		if print {
+			fmt.Printf("Successfully got an update from the \"%s\" chart repository\n", entry.Name)
+		}
+	}
+	return nil
+}
+
+// FetchChart fetches a chart from chart repository by name and version and untars it in the local directory
+func (c *sdkHelmClient) FetchChart(ctx context.Context, o FetchChartOptions) error {
+	if err := ctx.Err(); err != nil {
+		return &HelmError{Op: "fetch", Target: o.Name, Err: err}
+	}
+	repoName, _, err := SplitInTwo(o.Repo, "=")
+	if err != nil {
+		return &HelmError{Op: "fetch", Target: o.Name, Err: err}
+	}
+	if err := c.ensureHome(); err != nil {
+		return &HelmError{Op: "fetch", Target: o.Name, Err: err}
+	}
+
+	dl := downloader.ChartDownloader{
+		Out:      c.out(o.Print, o.Out),
+		Verify:   downloader.VerifyNever,
+		HelmHome: c.settings.Home,
+		Getters:  getter.All(c.settings),
+	}
+	saved, _, err := dl.DownloadTo(fmt.Sprintf("%s/%s", repoName, o.Name), o.Version, o.Dir)
+	if err != nil {
+		return &HelmError{Op: "fetch", Target: o.Name, Err: err}
+	}
+	defer os.Remove(saved)
+	if err := chartutil.ExpandFile(o.Dir, saved); err != nil {
+		return &HelmError{Op: "fetch", Target: o.Name, Err: err}
+	}
+	return nil
+}
+
+// UpdateChartDependencies performs helm dependency update
+func (c *sdkHelmClient) UpdateChartDependencies(ctx context.Context, o UpdateChartDependenciesOptions) error {
+	if err := ctx.Err(); err != nil {
+		return &HelmError{Op: "dependency update", Target: o.Path, Err: err}
+	}
+	if err := c.ensureHome(); err != nil {
+		return &HelmError{Op: "dependency update", Target: o.Path, Err: err}
+	}
+	man := downloader.Manager{
+		Out:       c.out(o.Print, o.Out),
+		ChartPath: o.Path,
+		HelmHome:  c.settings.Home,
+		Getters:   getter.All(c.settings),
+	}
+	if err := man.Update(); err != nil {
+		return &HelmError{Op: "dependency update", Target: o.Path, Err: err}
+	}
+	return nil
+}
+
+// UpgradeRelease performs helm upgrade -i
+func (c *sdkHelmClient) UpgradeRelease(ctx context.Context, o UpgradeReleaseOptions) (*HelmRelease, error) {
+	if o.Inject {
+		return c.exec.UpgradeRelease(ctx, o)
+	}
+
+	chart, err := chartutil.Load(filepath.Join(o.Dir, o.Name))
+	if err != nil {
+		return nil, &HelmError{Op: "upgrade", Target: o.ReleaseName, Err: err}
+	}
+	rawVals, err := mergeValues(o.ValuesFiles, o.Set)
+	if err != nil {
+		return nil, &HelmError{Op: "upgrade", Target: o.ReleaseName, Err: err}
+	}
+
+	client, closeTunnel, err := c.tillerClient(o.KubeContext, o.TLS, o.HelmTLSStore)
+	if err != nil {
+		return nil, &HelmError{Op: "upgrade", Target: o.ReleaseName, Err: err}
+	}
+	defer closeTunnel()
+
+	out := c.out(o.Print, o.Out)
+	var rel *rspb.Release
+	_, err = client.ReleaseHistory(o.ReleaseName, helm.WithMaxHistory(1))
+	notFound := err != nil && strings.Contains(grpc.ErrorDesc(err), "not found")
+	if err != nil && !notFound {
+		return nil, &HelmError{Op: "upgrade", Target: o.ReleaseName, Err: errors.New(grpc.ErrorDesc(err))}
+	}
+	if notFound {
+		fmt.Fprintf(out, "Release \"%s\" does not exist. Installing it now.\n", o.ReleaseName)
+		// Tiller carries on with the installation if the connection is closed, so it is only waited for gracefully
+		if err := runGracefully(ctx, func() error {
+			res, err := client.InstallReleaseFromChart(
+				chart,
+				o.Namespace,
+				helm.ReleaseName(o.ReleaseName),
+				helm.ValueOverrides(rawVals),
+				helm.InstallTimeout(int64(o.Timeout)),
+			)
+			if err != nil {
+				return errors.New(grpc.ErrorDesc(err))
+			}
+			rel = res.GetRelease()
+			return nil
+		}); err != nil {
+			return nil, &HelmError{Op: "install", Target: o.ReleaseName, Err: err}
+		}
+	} else {
+		if err := runGracefully(ctx, func() error {
+			res, err := client.UpdateReleaseFromChart(
+				o.ReleaseName,
+				chart,
+				helm.UpdateValueOverrides(rawVals),
+				helm.UpgradeTimeout(int64(o.Timeout)),
+			)
+			if err != nil {
+				return errors.New(grpc.ErrorDesc(err))
+			}
+			rel = res.GetRelease()
+			return nil
+		}); err != nil {
+			return nil, &HelmError{Op: "upgrade", Target: o.ReleaseName, Err: err}
+		}
+	}
+
+	release := toHelmRelease(rel)
+	fmt.Fprintf(out, "Release \"%s\" has been upgraded (revision %d, status %s)\n", release.Name, release.Revision, release.Status)
+	if resources := rel.GetInfo().GetStatus().GetResources(); resources != "" {
+		fmt.Fprintf(out, "RESOURCES:\n%s\n", resources)
+	}
+	return release, nil
+}
+
+// DeleteRelease deletes a release from Kubernetes
+func (c *sdkHelmClient) DeleteRelease(ctx context.Context, o DeleteReleaseOptions) error {
+	client, closeTunnel, err := c.tillerClient(o.KubeContext, o.TLS, o.HelmTLSStore)
+	if err != nil {
+		return &HelmError{Op: "delete", Target: o.ReleaseName, Err: err}
+	}
+	defer closeTunnel()
+
+	var info string
+	if err := runGracefully(ctx, func() error {
+		res, err := client.DeleteRelease(
+			o.ReleaseName,
+			helm.DeletePurge(true),
+			helm.DeleteTimeout(int64(o.Timeout)),
+		)
+		if err != nil {
+			return errors.New(grpc.ErrorDesc(err))
+		}
+		info = res.GetInfo()
+		return nil
+	}); err != nil {
+		return &HelmError{Op: "delete", Target: o.ReleaseName, Err: err}
+	}
+	if info != "" {
+		fmt.Fprintln(c.out(o.Print, o.Out), info)
+	}
+	return nil
+}
+
+// Lint takes a path to a chart and runs a series of tests to verify that the chart is well-formed
+func (c *sdkHelmClient) Lint(ctx context.Context, o LintOptions) error {
+	linter := lint.All(o.Path, []byte{}, "", false)
+	if o.Print {
+		for _, msg := range linter.Messages {
+			fmt.Println(msg)
+		}
+	}
+	if linter.HighestSeverity >= support.ErrorSev {
+		var errs []string
+		for _, msg := range linter.Messages {
+			if msg.Severity >= support.ErrorSev {
+				errs = append(errs, msg.Error())
+			}
+		}
+		return &HelmError{Op: "lint", Target: o.Path, Err: fmt.Errorf(strings.Join(errs, "\n"))}
+	}
+	return nil
+}
+
+// PushChart packages a helm chart and uploads it using the ChartMuseum API
+func (c *sdkHelmClient) PushChart(ctx context.Context, o PushChartOptions) error {
+	repoName, repoURL, err := SplitInTwo(o.Repo, "=")
+	if err != nil {
+		return &HelmError{Op: "push", Target: o.Path, Err: err}
+	}
+	if err := c.pushChart(ctx, repoName, repoURL, o.Path, o.Force); err != nil {
+		return &HelmError{Op: "push", Target: o.Path, Err: err}
+	}
+	if o.Print {
+		fmt.Printf("Pushed %s to %s\n", o.Path, repoName)
+	}
+	return nil
+}
+
+func (c *sdkHelmClient) pushChart(ctx context.Context, repoName, repoURL, chartPath string, force bool) error {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	packaged, err := PackageChart(chartPath, tempDir)
+	if err != nil {
+		return err
+	}
+	username, password := c.repoCredentials(repoName)
+	publisher := &chartMuseumPublisher{url: repoURL, username: username, password: password, force: force}
+	if err := publisher.Publish(ctx, packaged); err != nil {
+		// Failures of the Helm client are Helm failures, not request failures
+		return fmt.Errorf("%v", err)
+	}
+	return nil
+}
+
+// repoCredentials returns the credentials of a repository, following the helm push plugin conventions
+func (c *sdkHelmClient) repoCredentials(repoName string) (string, string) {
+	username := os.Getenv("HELM_REPO_USERNAME")
+	password := os.Getenv("HELM_REPO_PASSWORD")
+	if username != "" || password != "" {
+		return username, password
+	}
+	f, err := repo.LoadRepositoriesFile(c.settings.Home.RepositoryFile())
+	if err != nil {
+		return "", ""
+	}
+	for _, entry := range f.Repositories {
+		if entry.Name == repoName {
+			return entry.Username, entry.Password
+		}
+	}
+	return "", ""
+}
+
+// tillerClient returns a client to Tiller and a function to close the connection
+func (c *sdkHelmClient) tillerClient(kubeContext string, tls bool, helmTLSStore string) (*helm.Client, func(), error) {
+	connectTimeout, err := getSecondsEnvVar("HELM_TILLER_CONNECTION_TIMEOUT", 300)
+	if err != nil {
+		return nil, nil, err
+	}
+	host := c.settings.TillerHost
+	closeTunnel := func() {}
+	if host == "" {
+		host, closeTunnel, err = c.tillerTunnel(kubeContext)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	options := []helm.Option{
+		helm.Host(host),
+		helm.ConnectTimeout(int64(connectTimeout)),
+	}
+	if tls {
+		tlsConfig, err := tlsutil.ClientConfig(tlsutil.Options{
+			CertFile:           fmt.Sprintf("%s/%s.cert.pem", helmTLSStore, kubeContext),
+			KeyFile:            fmt.Sprintf("%s/%s.key.pem", helmTLSStore, kubeContext),
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			closeTunnel()
+			return nil, nil, err
+		}
+		options = append(options, helm.WithTLS(tlsConfig))
+	}
+
+	return helm.NewClient(options...), closeTunnel, nil
+}
+
+// tillerTunnel forwards a local port to the Tiller pod and returns the local address
+func (c *sdkHelmClient) tillerTunnel(kubeContext string) (string, func(), error) {
+	config, err := buildConfigFromFlags(kubeContext, getKubeConfigPath())
+	if err != nil {
+		return "", nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", nil, err
+	}
+	pods, err := clientset.CoreV1().Pods(c.settings.TillerNamespace).List(metav1.ListOptions{
+		LabelSelector: "app=helm,name=tiller",
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(pods.Items) == 0 {
+		return "", nil, fmt.Errorf("could not find tiller in namespace \"%s\"", c.settings.TillerNamespace)
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return "", nil, err
+	}
+	u := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(c.settings.TillerNamespace).
+		Name(pods.Items[0].Name).
+		SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", u)
+
+	stopChan, readyChan := make(chan struct{}, 1), make(chan struct{}, 1)
+	pf, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", tillerPort)}, stopChan, readyChan, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return "", nil, err
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- pf.ForwardPorts()
+	}()
+	select {
+	case err := <-errChan:
+		return "", nil, fmt.Errorf("forwarding ports: %v", err)
+	case <-readyChan:
+	}
+	ports, err := pf.GetPorts()
+	if err != nil {
+		close(stopChan)
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), func() { close(stopChan) }, nil
+}
+
+// loadRepositoriesFile loads the repositories file, initializing the Helm home if required
+func (c *sdkHelmClient) loadRepositoriesFile() (*repo.RepoFile, error) {
+	if err := c.ensureHome(); err != nil {
+		return nil, err
+	}
+	return repo.LoadRepositoriesFile(c.settings.Home.RepositoryFile())
+}
+
+// ensureHome creates the Helm home directories and repositories file (helm init --client-only)
+func (c *sdkHelmClient) ensureHome() error {
+	home := c.settings.Home
+	for _, dir := range []string{home.String(), home.Repository(), home.Cache(), home.Plugins()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(home.RepositoryFile()); os.IsNotExist(err) {
+		return repo.NewRepoFile().WriteFile(home.RepositoryFile(), 0644)
+	}
+	return nil
+}
+
+// out returns the writer to report progress to: w if set, stdout if print is set and otherwise nothing
+func (c *sdkHelmClient) out(print bool, w io.Writer) io.Writer {
+	if w != nil {
+		return w
+	}
+	if print {
+		return os.Stdout
+	}
+	return ioutil.Discard
+}
+
+// mergeValues merges values files and set values the same way helm upgrade does
+func mergeValues(valuesFiles, set []string) ([]byte, error) {
+	base := map[string]interface{}{}
+	for _, file := range valuesFiles {
+		current := map[string]interface{}{}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &current); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		base = mergeMaps(base, current)
+	}
+	for _, s := range set {
+		if err := strvals.ParseInto(s, base); err != nil {
+			return nil, fmt.Errorf("failed parsing --set data: %v", err)
+		}
+	}
+	return yaml.Marshal(base)
+}
+
+func mergeMaps(dest, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		nextMap, ok := v.(map[string]interface{})
+		destMap, isMap := dest[k].(map[string]interface{})
+		if !ok || !isMap {
+			dest[k] = v
+			continue
+		}
+		dest[k] = mergeMaps(destMap, nextMap)
+	}
+	return dest
+}
+
+func toHelmRelease(rel *rspb.Release) *HelmRelease {
+	if rel == nil {
+		return &HelmRelease{}
+	}
+	release := &HelmRelease{
+		Name:      rel.GetName(),
+		Namespace: rel.GetNamespace(),
+		Revision:  rel.GetVersion(),
+		Status:    rel.GetInfo().GetStatus().GetCode().String(),
+	}
+	if metadata := rel.GetChart().GetMetadata(); metadata != nil {
+		release.ChartName = metadata.Name
+		release.ChartVersion = metadata.Version
+	}
+	return release
+}
@@ -1,9 +1,9 @@
 package utils
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 )
 
@@ -17,29 +17,37 @@ type PerformRequestOptions struct {
 }
 
 // PerformRequest performs an HTTP request to a given url with an expected status code (to support testing) and returns the body
-func PerformRequest(o PerformRequestOptions) []byte {
+func PerformRequest(o PerformRequestOptions) ([]byte, error) {
 	req, err := http.NewRequest(o.Method, o.URL, o.Data)
 	if err != nil {
-		log.Fatal("NewRequest: ", err)
+		return nil, err
 	}
 	for _, header := range o.Headers {
-		header, value := SplitInTwo(header, ":")
+		header, value, err := SplitInTwo(header, ":")
+		if err != nil {
+			return nil, err
+		}
 		req.Header.Add(header, value)
 	}
 	client := &http.Client{}
 	res, err := client.Do(req)
 	if err != nil {
-		log.Fatal("Do: ", err)
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		log.Fatalln("ReadAll: ", err)
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
 	}
 	if res.StatusCode != o.ExpectedStatusCode {
-		log.Fatal(string(body))
+		return nil, &HTTPError{
+			Method:     o.Method,
+			URL:        o.URL,
+			StatusCode: res.StatusCode,
+			Body:       string(body),
+		}
 	}
 
-	return body
+	return body, nil
 }
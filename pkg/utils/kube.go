@@ -1,12 +1,16 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" //for gcp auth
@@ -15,16 +19,11 @@ import (
 )
 
 // CreateNamespace creates a namespace
-func CreateNamespace(name, kubeContext string, print bool) error {
-	clientset, err := getClientSet(kubeContext)
-	if err != nil {
-		return err
-	}
-
+func CreateNamespace(clientset kubernetes.Interface, name string, print bool) error {
 	nsSpec := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
 		Name: name,
 	}}
-	_, err = clientset.CoreV1().Namespaces().Create(nsSpec)
+	_, err := clientset.CoreV1().Namespaces().Create(nsSpec)
 	if err != nil {
 		return err
 	}
@@ -35,11 +34,7 @@ func CreateNamespace(name, kubeContext string, print bool) error {
 }
 
 // GetNamespace gets a namespace
-func GetNamespace(name, kubeContext string) (*v1.Namespace, error) {
-	clientset, err := getClientSet(kubeContext)
-	if err != nil {
-		return nil, err
-	}
+func GetNamespace(clientset kubernetes.Interface, name string) (*v1.Namespace, error) {
 	getOptions := metav1.GetOptions{}
 	nsSpec, err := clientset.CoreV1().Namespaces().Get(name, getOptions)
 	if err != nil {
@@ -49,28 +44,20 @@ func GetNamespace(name, kubeContext string) (*v1.Namespace, error) {
 }
 
 // UpdateNamespace updates a namespace
-func UpdateNamespace(name, kubeContext string, annotationsToUpdate, labelsToUpdate map[string]string, print bool) error {
+func UpdateNamespace(clientset kubernetes.Interface, name string, annotationsToUpdate, labelsToUpdate map[string]string, print bool) error {
 	if len(annotationsToUpdate) == 0 && len(labelsToUpdate) == 0 {
 		return nil
 	}
 
-	clientset, err := getClientSet(kubeContext)
-	if err != nil {
-		return err
-	}
-
-	ns, err := GetNamespace(name, kubeContext)
+	ns, err := GetNamespace(clientset, name)
 	if err != nil {
 		return err
 	}
 	annotations := overrideAttributes(ns.Annotations, annotationsToUpdate)
 	labels := overrideAttributes(ns.Labels, labelsToUpdate)
-	nsSpec := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
-		Name:        name,
-		Annotations: annotations,
-		Labels:      labels,
-	}}
-	_, err = clientset.CoreV1().Namespaces().Update(nsSpec)
+	ns.Annotations = annotations
+	ns.Labels = labels
+	_, err = clientset.CoreV1().Namespaces().Update(ns)
 	if err != nil {
 		return err
 	}
@@ -98,13 +85,9 @@ func overrideAttributes(currentAttributes, attributesToUpdate map[string]string)
 }
 
 // DeleteNamespace deletes a namespace
-func DeleteNamespace(name, kubeContext string, print bool) error {
-	clientset, err := getClientSet(kubeContext)
-	if err != nil {
-		return err
-	}
+func DeleteNamespace(clientset kubernetes.Interface, name string, print bool) error {
 	deleteOptions := &metav1.DeleteOptions{}
-	err = clientset.CoreV1().Namespaces().Delete(name, deleteOptions)
+	err := clientset.CoreV1().Namespaces().Delete(name, deleteOptions)
 	if err != nil {
 		return err
 	}
@@ -114,13 +97,28 @@ func DeleteNamespace(name, kubeContext string, print bool) error {
 	return nil
 }
 
-// NamespaceExists returns true if the namespace exists
-func NamespaceExists(name, kubeContext string) (bool, error) {
-	clientset, err := getClientSet(kubeContext)
-	if err != nil {
-		return false, err
+// WaitForNamespaceDeletion waits for a namespace to finish terminating, and fails once the timeout is reached
+func WaitForNamespaceDeletion(ctx context.Context, clientset kubernetes.Interface, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := clientset.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for namespace \"%s\" to terminate after %s", name, timeout)
+		}
+		if err := Sleep(ctx, 2*time.Second); err != nil {
+			return err
+		}
 	}
+}
 
+// NamespaceExists returns true if the namespace exists
+func NamespaceExists(clientset kubernetes.Interface, name string) (bool, error) {
 	listOptions := metav1.ListOptions{}
 	namespaces, err := clientset.CoreV1().Namespaces().List(listOptions)
 	if err != nil {
@@ -138,28 +136,71 @@ func NamespaceExists(name, kubeContext string) (bool, error) {
 	return false, nil
 }
 
-// GetPods returns a pods list
-func getPods(namespace, kubeContext string) (*v1.PodList, error) {
-
-	clientset, err := getClientSet(kubeContext)
+// GetReleaseNamesBySelector returns the names of the releases (by the "release" or "app.kubernetes.io/instance" labels)
+// of the workloads, services and pods in a namespace which match a label selector
+func GetReleaseNamesBySelector(clientset kubernetes.Interface, namespace, selector string) ([]string, error) {
+	listOptions := metav1.ListOptions{LabelSelector: selector}
+	var objectsLabels []map[string]string
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(listOptions)
 	if err != nil {
 		return nil, err
 	}
-	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+	for _, o := range deployments.Items {
+		objectsLabels = append(objectsLabels, o.Labels)
+	}
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range statefulSets.Items {
+		objectsLabels = append(objectsLabels, o.Labels)
+	}
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range daemonSets.Items {
+		objectsLabels = append(objectsLabels, o.Labels)
+	}
+	services, err := clientset.CoreV1().Services(namespace).List(listOptions)
 	if err != nil {
 		return nil, err
 	}
+	for _, o := range services.Items {
+		objectsLabels = append(objectsLabels, o.Labels)
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range pods.Items {
+		objectsLabels = append(objectsLabels, o.Labels)
+	}
 
-	return pods, nil
+	var names []string
+	for _, l := range objectsLabels {
+		for _, key := range []string{"release", "app.kubernetes.io/instance"} {
+			if name := l[key]; name != "" && !Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
 }
 
-// getEndpoints returns an endpoints list
-func getEndpoints(namespace, kubeContext string) (*v1.EndpointsList, error) {
-
-	clientset, err := getClientSet(kubeContext)
+// getPods returns a pods list
+func getPods(clientset kubernetes.Interface, namespace string) (*v1.PodList, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
+
+	return pods, nil
+}
+
+// getEndpoints returns an endpoints list
+func getEndpoints(clientset kubernetes.Interface, namespace string) (*v1.EndpointsList, error) {
 	endpoints, err := clientset.CoreV1().Endpoints(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -176,22 +217,26 @@ func buildConfigFromFlags(context, kubeconfigPath string) (*rest.Config, error)
 		}).ClientConfig()
 }
 
-func getClientSet(kubeContext string) (*kubernetes.Clientset, error) {
-	var kubeconfig string
+func getKubeConfigPath() string {
 	if kubeConfigPath := os.Getenv("KUBECONFIG"); kubeConfigPath != "" {
-		kubeconfig = kubeConfigPath
-	} else {
-		kubeconfig = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+		return kubeConfigPath
 	}
+	return filepath.Join(os.Getenv("HOME"), ".kube", "config")
+}
 
-	config, err := buildConfigFromFlags(kubeContext, kubeconfig)
+// GetClientSet returns a Kubernetes clientset for a kube context
+func GetClientSet(kubeContext string) (kubernetes.Interface, error) {
+	config, err := buildConfigFromFlags(kubeContext, getKubeConfigPath())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrClusterUnreachable, err)
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrClusterUnreachable, err)
+	}
+	if _, err := clientset.Discovery().ServerVersion(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrClusterUnreachable, err)
 	}
 
 	return clientset, nil
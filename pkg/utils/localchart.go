@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// localRepositoryPrefix is the prefix of dependencies on charts in the local file system
+const localRepositoryPrefix = "file://"
+
+// LocalChart is a chart in a directory
+type LocalChart struct {
+	Name    string
+	Version string
+	Path    string
+	// Dependencies are the names of discovered charts which the chart depends on (file:// dependencies)
+	Dependencies []string
+}
+
+// localChartMetadata is the part of Chart.yaml and requirements.yaml which is read by DiscoverCharts
+type localChartMetadata struct {
+	Name         string `yaml:"name"`
+	Version      string `yaml:"version"`
+	Dependencies []struct {
+		Name       string `yaml:"name"`
+		Repository string `yaml:"repository"`
+	} `yaml:"dependencies"`
+}
+
+// DiscoverCharts returns the charts in a directory and its subdirectories, sorted by path.
+// Subcharts of discovered charts and hidden directories are skipped
+func DiscoverCharts(dir string) ([]LocalChart, error) {
+	var charts []LocalChart
+	deps := map[string][]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err != nil {
+			return nil
+		}
+		chart, chartDeps, err := readLocalChart(path)
+		if err != nil {
+			return err
+		}
+		charts = append(charts, chart)
+		deps[chart.Path] = chartDeps
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, c := range charts {
+		if other, ok := names[c.Name]; ok {
+			return nil, fmt.Errorf("%w: chart \"%s\" found in both %s and %s", ErrInvalidChart, c.Name, other, c.Path)
+		}
+		names[c.Name] = c.Path
+	}
+	byPath := map[string]string{}
+	for _, c := range charts {
+		abs, err := filepath.Abs(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		byPath[abs] = c.Name
+	}
+	for i, c := range charts {
+		for _, d := range deps[c.Path] {
+			if name, ok := byPath[d]; ok {
+				charts[i].Dependencies = AddIfNotContained(charts[i].Dependencies, name)
+			}
+		}
+	}
+	sort.Slice(charts, func(i, j int) bool { return charts[i].Path < charts[j].Path })
+	return charts, nil
+}
+
+// readLocalChart reads a chart in a directory and returns it along with the absolute paths of its local dependencies
+func readLocalChart(path string) (LocalChart, []string, error) {
+	path = filepath.Clean(path)
+	chartFile := filepath.Join(path, "Chart.yaml")
+	data, err := ioutil.ReadFile(chartFile)
+	if err != nil {
+		return LocalChart{}, nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	var v localChartMetadata
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return LocalChart{}, nil, fmt.Errorf("%w: %s: %v", ErrInvalidChart, chartFile, err)
+	}
+	if v.Name == "" || v.Version == "" {
+		return LocalChart{}, nil, fmt.Errorf("%w: %s: name and version are required", ErrInvalidChart, chartFile)
+	}
+
+	// Dependencies are listed in requirements.yaml if it exists, in Chart.yaml otherwise
+	dependencies := v.Dependencies
+	requirementsFile := filepath.Join(path, "requirements.yaml")
+	if data, err := ioutil.ReadFile(requirementsFile); err == nil {
+		var r localChartMetadata
+		if err := yaml.Unmarshal(data, &r); err != nil {
+			return LocalChart{}, nil, fmt.Errorf("%w: %s: %v", ErrInvalidChart, requirementsFile, err)
+		}
+		dependencies = r.Dependencies
+	}
+	var deps []string
+	for _, d := range dependencies {
+		if !strings.HasPrefix(d.Repository, localRepositoryPrefix) {
+			continue
+		}
+		dep := strings.TrimPrefix(d.Repository, localRepositoryPrefix)
+		if !filepath.IsAbs(dep) {
+			dep = filepath.Join(path, dep)
+		}
+		if dep, err = filepath.Abs(dep); err != nil {
+			return LocalChart{}, nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return LocalChart{Name: v.Name, Version: v.Version, Path: path}, deps, nil
+}
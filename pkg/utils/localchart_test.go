@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverCharts(t *testing.T) {
+	got, err := DiscoverCharts("testdata/charts")
+	if err != nil {
+		t.Fatalf("DiscoverCharts() error = %v", err)
+	}
+	want := []LocalChart{
+		{Name: "api", Version: "0.1.0", Path: filepath.Join("testdata", "charts", "api")},
+		{Name: "common", Version: "0.2.0", Path: filepath.Join("testdata", "charts", "common")},
+		{Name: "web", Version: "1.0.0", Path: filepath.Join("testdata", "charts", "web"), Dependencies: []string{"api", "common"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoverCharts() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverCharts_DuplicateName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orca-charts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, path, "Chart.yaml"), []byte("name: api\nversion: 0.1.0\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := DiscoverCharts(dir); !errors.Is(err, ErrInvalidChart) {
+		t.Errorf("DiscoverCharts() error = %v, want %v", err, ErrInvalidChart)
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// MetadataConfigMapName is the name of the ConfigMap orca records environment metadata in
+	MetadataConfigMapName = "orca-metadata"
+	// MetadataLabel labels the ConfigMap orca records environment metadata in
+	MetadataLabel = "orca.nuvocares.com/metadata"
+
+	metadataChartsFileHashKey = "charts-file-hash"
+	metadataDependenciesKey   = "dependencies.yaml"
+)
+
+// EnvironmentMetadata is metadata orca records in an environment when deploying it
+type EnvironmentMetadata struct {
+	// ChartsFileHash is the hash of the charts file the environment was last deployed from
+	ChartsFileHash string
+	// Dependencies holds the depends_on of each chart in the environment, by chart name
+	Dependencies map[string][]string
+}
+
+// GetEnvironmentMetadata returns the metadata recorded in an environment. If no metadata was recorded, empty metadata is returned
+func GetEnvironmentMetadata(clientset kubernetes.Interface, namespace string) (*EnvironmentMetadata, error) {
+	m := &EnvironmentMetadata{Dependencies: map[string][]string{}}
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(MetadataConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting environment metadata: %v", err)
+	}
+	m.ChartsFileHash = cm.Data[metadataChartsFileHashKey]
+	if err := yaml.Unmarshal([]byte(cm.Data[metadataDependenciesKey]), &m.Dependencies); err != nil {
+		return nil, fmt.Errorf("failed parsing environment metadata: %v", err)
+	}
+	if m.Dependencies == nil {
+		m.Dependencies = map[string][]string{}
+	}
+	return m, nil
+}
+
+// UpdateEnvironmentMetadata records metadata in an environment
+func UpdateEnvironmentMetadata(clientset kubernetes.Interface, namespace string, m *EnvironmentMetadata, print bool) error {
+	dependencies := map[string][]string{}
+	for chart, deps := range m.Dependencies {
+		sorted := append([]string{}, deps...)
+		sort.Strings(sorted)
+		dependencies[chart] = sorted
+	}
+	data, err := yaml.Marshal(dependencies)
+	if err != nil {
+		return err
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   MetadataConfigMapName,
+			Labels: map[string]string{MetadataLabel: "true"},
+		},
+		Data: map[string]string{
+			metadataChartsFileHashKey: m.ChartsFileHash,
+			metadataDependenciesKey:   string(data),
+		},
+	}
+	return applyObject(clientset, namespace, cm, print)
+}
+
+// ChartsFileHash returns the SHA-256 hash of a charts file
+func ChartsFileHash(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidChartsFile, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEnvironmentMetadata(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset()
+
+	got, err := GetEnvironmentMetadata(clientset, "test")
+	if err != nil {
+		t.Fatalf("GetEnvironmentMetadata() error = %v", err)
+	}
+	if want := (&EnvironmentMetadata{Dependencies: map[string][]string{}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEnvironmentMetadata() = %+v, want %+v", got, want)
+	}
+
+	for _, m := range []*EnvironmentMetadata{
+		{ChartsFileHash: "abc", Dependencies: map[string][]string{"kaa": {"mariadb", "cassandra"}, "cassandra": {}}},
+		{ChartsFileHash: "def", Dependencies: map[string][]string{"kaa": {"cassandra"}}},
+	} {
+		if err := UpdateEnvironmentMetadata(clientset, "test", m, false); err != nil {
+			t.Fatalf("UpdateEnvironmentMetadata() error = %v", err)
+		}
+	}
+	got, err = GetEnvironmentMetadata(clientset, "test")
+	if err != nil {
+		t.Fatalf("GetEnvironmentMetadata() error = %v", err)
+	}
+	want := &EnvironmentMetadata{ChartsFileHash: "def", Dependencies: map[string][]string{"kaa": {"cassandra"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEnvironmentMetadata() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// objectClient performs operations on namespaced objects of a single kind
+type objectClient struct {
+	get    func(name string) (runtime.Object, error)
+	create func(obj runtime.Object) error
+	update func(obj runtime.Object) error
+	list   func(opts metav1.ListOptions) (runtime.Object, error)
+	delete func(name string) error
+}
+
+// objectKind returns the kind of an object orca knows how to apply
+func objectKind(obj runtime.Object) (string, error) {
+	switch obj.(type) {
+	case *v1.ResourceQuota:
+		return "ResourceQuota", nil
+	case *v1.LimitRange:
+		return "LimitRange", nil
+	case *networkingv1.NetworkPolicy:
+		return "NetworkPolicy", nil
+	case *rbacv1.Role:
+		return "Role", nil
+	case *rbacv1.RoleBinding:
+		return "RoleBinding", nil
+	case *v1.Secret:
+		return "Secret", nil
+	case *v1.ConfigMap:
+		return "ConfigMap", nil
+	}
+	return "", fmt.Errorf("unsupported kind %s", reflect.TypeOf(obj))
+}
+
+// newObjectClient returns a client for namespaced objects of a kind
+func newObjectClient(clientset kubernetes.Interface, namespace, kind string) *objectClient {
+	c := &objectClient{}
+	switch kind {
+	case "ResourceQuota":
+		i := clientset.CoreV1().ResourceQuotas(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*v1.ResourceQuota)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*v1.ResourceQuota)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "LimitRange":
+		i := clientset.CoreV1().LimitRanges(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*v1.LimitRange)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*v1.LimitRange)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "NetworkPolicy":
+		i := clientset.NetworkingV1().NetworkPolicies(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*networkingv1.NetworkPolicy)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*networkingv1.NetworkPolicy)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "Role":
+		i := clientset.RbacV1().Roles(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*rbacv1.Role)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*rbacv1.Role)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "RoleBinding":
+		i := clientset.RbacV1().RoleBindings(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*rbacv1.RoleBinding)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*rbacv1.RoleBinding)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "Secret":
+		i := clientset.CoreV1().Secrets(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*v1.Secret)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*v1.Secret)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "ConfigMap":
+		i := clientset.CoreV1().ConfigMaps(namespace)
+		c.get = func(name string) (runtime.Object, error) { return i.Get(name, metav1.GetOptions{}) }
+		c.create = func(obj runtime.Object) error { _, err := i.Create(obj.(*v1.ConfigMap)); return err }
+		c.update = func(obj runtime.Object) error { _, err := i.Update(obj.(*v1.ConfigMap)); return err }
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	}
+	return c
+}
+
+// applyObject creates an object in a namespace, or updates it if it already exists
+func applyObject(clientset kubernetes.Interface, namespace string, obj runtime.Object, print bool) error {
+	kind, err := objectKind(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	accessor.SetNamespace(namespace)
+	c := newObjectClient(clientset, namespace, kind)
+
+	existing, err := c.get(accessor.GetName())
+	if apierrors.IsNotFound(err) {
+		if err := c.create(obj); err != nil {
+			return fmt.Errorf("failed creating %s \"%s\": %v", kind, accessor.GetName(), err)
+		}
+		if print {
+			log.Printf("created %s \"%s\" in environment \"%s\"", kind, accessor.GetName(), namespace)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	existingAccessor, err := meta.Accessor(existing)
+	if err != nil {
+		return err
+	}
+	accessor.SetResourceVersion(existingAccessor.GetResourceVersion())
+	if err := c.update(obj); err != nil {
+		return fmt.Errorf("failed updating %s \"%s\": %v", kind, accessor.GetName(), err)
+	}
+	if print {
+		log.Printf("updated %s \"%s\" in environment \"%s\"", kind, accessor.GetName(), namespace)
+	}
+	return nil
+}
+
+// pruneObjects deletes objects of a kind in a namespace which match a label selector and are not in keep, and returns their names
+func pruneObjects(clientset kubernetes.Interface, namespace, kind, selector string, keep map[string]bool, print bool) ([]string, error) {
+	c := newObjectClient(clientset, namespace, kind)
+	list, err := c.list(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	pruned := []string{}
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		if keep[accessor.GetName()] {
+			continue
+		}
+		if err := c.delete(accessor.GetName()); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed deleting %s \"%s\": %v", kind, accessor.GetName(), err)
+		}
+		if print {
+			log.Printf("deleted %s \"%s\" from environment \"%s\"", kind, accessor.GetName(), namespace)
+		}
+		pruned = append(pruned, accessor.GetName())
+	}
+	return pruned, nil
+}
+
+// orphanKinds are the cluster-scoped kinds which are left behind when the releases which created them are deleted
+var orphanKinds = []string{
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"PersistentVolume",
+	"StorageClass",
+	"PodSecurityPolicy",
+	"PriorityClass",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// newClusterObjectClient returns a client for cluster-scoped objects of a kind which can list and delete them
+func newClusterObjectClient(clientset kubernetes.Interface, kind string) *objectClient {
+	c := &objectClient{}
+	switch kind {
+	case "ClusterRole":
+		i := clientset.RbacV1().ClusterRoles()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "ClusterRoleBinding":
+		i := clientset.RbacV1().ClusterRoleBindings()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "PersistentVolume":
+		i := clientset.CoreV1().PersistentVolumes()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "StorageClass":
+		i := clientset.StorageV1().StorageClasses()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "PodSecurityPolicy":
+		i := clientset.PolicyV1beta1().PodSecurityPolicies()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "PriorityClass":
+		i := clientset.SchedulingV1beta1().PriorityClasses()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "MutatingWebhookConfiguration":
+		i := clientset.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	case "ValidatingWebhookConfiguration":
+		i := clientset.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+		c.delete = func(name string) error { return i.Delete(name, &metav1.DeleteOptions{}) }
+		c.list = func(opts metav1.ListOptions) (runtime.Object, error) { return i.List(opts) }
+	}
+	return c
+}
+
+// DeleteOrphanedObjects deletes cluster-scoped objects (including PersistentVolumes) which are labelled
+// with the names of releases (by the "release" or "app.kubernetes.io/instance" labels)
+func DeleteOrphanedObjects(clientset kubernetes.Interface, releaseNames []string, print bool) error {
+	if len(releaseNames) == 0 {
+		return nil
+	}
+	names := strings.Join(releaseNames, ",")
+	selectors := []string{
+		fmt.Sprintf("release in (%s)", names),
+		fmt.Sprintf("app.kubernetes.io/instance in (%s)", names),
+	}
+	for _, kind := range orphanKinds {
+		c := newClusterObjectClient(clientset, kind)
+		deleted := map[string]bool{}
+		for _, selector := range selectors {
+			list, err := c.list(metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				return fmt.Errorf("failed listing %s objects: %v", kind, err)
+			}
+			objs, err := meta.ExtractList(list)
+			if err != nil {
+				return err
+			}
+			for _, obj := range objs {
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return err
+				}
+				if deleted[accessor.GetName()] {
+					continue
+				}
+				if err := c.delete(accessor.GetName()); err != nil && !apierrors.IsNotFound(err) {
+					return fmt.Errorf("failed deleting %s \"%s\": %v", kind, accessor.GetName(), err)
+				}
+				deleted[accessor.GetName()] = true
+				if print {
+					log.Printf("deleted orphaned %s \"%s\"", kind, accessor.GetName())
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const defaultTailLines = 20
+
+// ReleaseOutputOptions are options passed to NewReleaseOutput
+type ReleaseOutputOptions struct {
+	// ReleaseName is used to prefix streamed lines and to name the log file
+	ReleaseName string
+	// Out is where prefixed lines are streamed to (optional)
+	Out io.Writer
+	// LogDir is a directory to write the release log file to (optional)
+	LogDir string
+	// TailLines is the number of last lines to keep (defaults to 20)
+	TailLines int
+}
+
+// ReleaseOutput collects the output of operations on a single release.
+// Complete lines are streamed with a [release] prefix, appended to a log file and kept in a tail buffer
+type ReleaseOutput struct {
+	prefix    string
+	out       io.Writer
+	file      *os.File
+	tailLines int
+
+	mutex   sync.Mutex
+	partial []byte
+	tail    []string
+}
+
+// NewReleaseOutput returns a ReleaseOutput, creating the release log file if a log directory is set
+func NewReleaseOutput(o ReleaseOutputOptions) (*ReleaseOutput, error) {
+	r := &ReleaseOutput{
+		prefix:    fmt.Sprintf("[%s] ", o.ReleaseName),
+		out:       o.Out,
+		tailLines: o.TailLines,
+	}
+	if r.tailLines == 0 {
+		r.tailLines = defaultTailLines
+	}
+	if o.LogDir != "" {
+		if err := os.MkdirAll(o.LogDir, 0755); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(filepath.Join(o.LogDir, o.ReleaseName+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		r.file = file
+	}
+	return r, nil
+}
+
+// Write implements io.Writer, it is safe for concurrent use
+func (r *ReleaseOutput) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i == -1 {
+			break
+		}
+		if err := r.writeLine(string(r.partial[:i])); err != nil {
+			return 0, err
+		}
+		r.partial = r.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Tail returns the last lines written
+func (r *ReleaseOutput) Tail() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string{}, r.tail...)
+}
+
+// LogFile returns the path of the release log file, or an empty string if there is none
+func (r *ReleaseOutput) LogFile() string {
+	if r.file == nil {
+		return ""
+	}
+	return r.file.Name()
+}
+
+// Close flushes an incomplete last line and closes the log file
+func (r *ReleaseOutput) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.partial) != 0 {
+		r.writeLine(string(r.partial))
+		r.partial = nil
+	}
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *ReleaseOutput) writeLine(line string) error {
+	line = strings.TrimSuffix(line, "\r")
+	r.tail = append(r.tail, line)
+	if len(r.tail) > r.tailLines {
+		r.tail = r.tail[len(r.tail)-r.tailLines:]
+	}
+	if r.out != nil {
+		if _, err := io.WriteString(r.out, r.prefix+line+"\n"); err != nil {
+			return err
+		}
+	}
+	if r.file != nil {
+		if _, err := io.WriteString(r.file, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncWriter serializes writes to a writer shared by concurrent release outputs
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func newSyncWriter(w io.Writer) io.Writer {
+	if w == nil {
+		return nil
+	}
+	return &syncWriter{w: w}
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.w.Write(p)
+}
+
+// ReleaseError is returned when an operation on a release fails, along with the tail of its output
+type ReleaseError struct {
+	ReleaseName string
+	Err         error
+	LogTail     []string
+	LogFile     string
+}
+
+func (e *ReleaseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "release %s failed: %v", e.ReleaseName, e.Err)
+	if len(e.LogTail) != 0 {
+		fmt.Fprintf(&b, "\nlast %d lines of output", len(e.LogTail))
+		if e.LogFile != "" {
+			fmt.Fprintf(&b, " (full log: %s)", e.LogFile)
+		}
+		b.WriteString(":")
+		for _, line := range e.LogTail {
+			fmt.Fprintf(&b, "\n    %s", line)
+		}
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *ReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// ReleaseErrors is returned when operations on more than one release fail
+type ReleaseErrors []error
+
+func (e ReleaseErrors) Error() string {
+	msgs := []string{fmt.Sprintf("%d releases failed:", len(e))}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of the errors of the failed releases matches target
+func (e ReleaseErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the failed releases which matches target, and if so, sets target to it
+func (e ReleaseErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectErrors drains a closed channel of errors into a single error
+func collectErrors(errc chan error) error {
+	var errs ReleaseErrors
+	for err := range errc {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errs
+}
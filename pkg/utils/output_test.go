@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReleaseOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []string
+		tailLines int
+		wantOut   string
+		wantTail  []string
+	}{
+		{
+			name:     "complete lines",
+			writes:   []string{"line 1\nline 2\n"},
+			wantOut:  "[test-kaa] line 1\n[test-kaa] line 2\n",
+			wantTail: []string{"line 1", "line 2"},
+		},
+		{
+			name:     "lines split across writes",
+			writes:   []string{"li", "ne 1\nline", " 2\r\n"},
+			wantOut:  "[test-kaa] line 1\n[test-kaa] line 2\n",
+			wantTail: []string{"line 1", "line 2"},
+		},
+		{
+			name:     "incomplete last line is flushed on close",
+			writes:   []string{"line 1\nline 2"},
+			wantOut:  "[test-kaa] line 1\n[test-kaa] line 2\n",
+			wantTail: []string{"line 1", "line 2"},
+		},
+		{
+			name:      "tail is limited",
+			writes:    []string{"line 1\nline 2\nline 3\n"},
+			tailLines: 2,
+			wantOut:   "[test-kaa] line 1\n[test-kaa] line 2\n[test-kaa] line 3\n",
+			wantTail:  []string{"line 2", "line 3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logDir, err := ioutil.TempDir("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(logDir)
+
+			var out bytes.Buffer
+			r, err := NewReleaseOutput(ReleaseOutputOptions{
+				ReleaseName: "test-kaa",
+				Out:         &out,
+				LogDir:      logDir,
+				TailLines:   tt.tailLines,
+			})
+			if err != nil {
+				t.Fatalf("NewReleaseOutput() error = %v", err)
+			}
+			for _, w := range tt.writes {
+				r.Write([]byte(w))
+			}
+			r.Close()
+
+			if out.String() != tt.wantOut {
+				t.Errorf("ReleaseOutput streamed %q, want %q", out.String(), tt.wantOut)
+			}
+			if got := r.Tail(); !reflect.DeepEqual(got, tt.wantTail) {
+				t.Errorf("ReleaseOutput.Tail() = %v, want %v", got, tt.wantTail)
+			}
+			log, err := ioutil.ReadFile(filepath.Join(logDir, "test-kaa.log"))
+			if err != nil {
+				t.Fatalf("failed reading log file: %v", err)
+			}
+			if want := strings.Replace(tt.wantOut, "[test-kaa] ", "", -1); string(log) != want {
+				t.Errorf("ReleaseOutput logged %q, want %q", string(log), want)
+			}
+		})
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	errA := &ReleaseError{ReleaseName: "test-a", Err: errors.New("timed out"), LogTail: []string{"Error: timed out"}}
+	errB := &ReleaseError{ReleaseName: "test-b", Err: errors.New("not found")}
+
+	tests := []struct {
+		name string
+		errs []error
+		want string
+	}{
+		{
+			name: "no errors",
+			errs: []error{},
+			want: "",
+		},
+		{
+			name: "single error with tail",
+			errs: []error{errA},
+			want: "release test-a failed: timed out\nlast 1 lines of output:\n    Error: timed out",
+		},
+		{
+			name: "multiple errors",
+			errs: []error{errA, errB},
+			want: "2 releases failed:\nrelease test-a failed: timed out\nlast 1 lines of output:\n    Error: timed out\nrelease test-b failed: not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errc := make(chan error, len(tt.errs))
+			for _, err := range tt.errs {
+				errc <- err
+			}
+			close(errc)
+
+			got := ""
+			if err := collectErrors(errc); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("collectErrors() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// Chart publishers, which push packaged charts to chart repositories
+const (
+	// PublisherHelm pushes charts with the Helm client (the exec client requires the helm push plugin)
+	PublisherHelm = "helm"
+	// PublisherChartMuseum uploads charts using the ChartMuseum API
+	PublisherChartMuseum = "chartmuseum"
+	// PublisherArtifactory uploads charts to an Artifactory Helm repository with a PUT of the packaged chart
+	PublisherArtifactory = "artifactory"
+	// PublisherOCI pushes charts to an OCI registry (oci://registry/namespace)
+	PublisherOCI = "oci"
+)
+
+// Media types of Helm charts in OCI registries
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	ociHelmConfigMediaType  = "application/vnd.cncf.helm.config.v1+json"
+	ociHelmContentMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+// ociRepositoryURLPrefix is the prefix of OCI chart repositories
+const ociRepositoryURLPrefix = "oci://"
+
+// bearerChallengePattern matches the parameters of a bearer WWW-Authenticate challenge
+var bearerChallengePattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// PackagedChart is a chart packaged to a .tgz file
+type PackagedChart struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Path    string `yaml:"path"`
+	// Digest is the sha256 digest of the packaged chart (sha256:hex)
+	Digest string `yaml:"digest"`
+	// metadata is the Chart.yaml of the chart as json
+	metadata []byte
+}
+
+// PackageChart packages a chart to a .tgz file in a directory
+func PackageChart(chartPath, dir string) (*PackagedChart, error) {
+	chart, err := chartutil.LoadDir(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	packaged, err := chartutil.Save(chart, dir)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := fileDigest(packaged)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := json.Marshal(chart.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &PackagedChart{
+		Name:     chart.Metadata.Name,
+		Version:  chart.Metadata.Version,
+		Path:     packaged,
+		Digest:   digest,
+		metadata: metadata,
+	}, nil
+}
+
+// fileDigest returns the sha256 digest of a file
+func fileDigest(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ChartVersionChecker checks if chart versions exist in a chart repository
+type ChartVersionChecker interface {
+	Exists(ctx context.Context, name, version string) (bool, error)
+}
+
+// ChartPublisher publishes packaged charts to a chart repository
+type ChartPublisher interface {
+	ChartVersionChecker
+	Publish(ctx context.Context, chart *PackagedChart) error
+}
+
+// ChartPublisherOptions are options passed to NewChartPublisher
+type ChartPublisherOptions struct {
+	// Type is the type of the publisher (chartmuseum, artifactory, oci)
+	Type string
+	// Repo is the chart repository (name=url or url)
+	Repo     string
+	Username string
+	Password string
+	// Token is an Artifactory API key
+	Token string
+	// PlainHTTP uses http instead of https for OCI registries
+	PlainHTTP bool
+	// Force overwrites existing chart versions (chartmuseum, which rejects them otherwise)
+	Force bool
+}
+
+// NewChartPublisher returns a chart publisher by its type
+func NewChartPublisher(o ChartPublisherOptions) (ChartPublisher, error) {
+	_, repoURL := SplitRepo(o.Repo)
+	if repoURL == "" {
+		return nil, fmt.Errorf("%w: chart repository can not be empty", ErrInvalidKeyValue)
+	}
+	switch o.Type {
+	case PublisherChartMuseum:
+		username, password := o.Username, o.Password
+		if username == "" && password == "" {
+			username, password = os.Getenv("HELM_REPO_USERNAME"), os.Getenv("HELM_REPO_PASSWORD")
+		}
+		return &chartMuseumPublisher{
+			url:      repoURL,
+			username: username,
+			password: password,
+			force:    o.Force,
+			index:    newChartRepositoryIndex(repoURL, basicAuthHeaders(username, password)),
+		}, nil
+	case PublisherArtifactory:
+		headers := basicAuthHeaders(o.Username, o.Password)
+		if o.Token != "" {
+			headers = []string{"X-JFrog-Art-Api:" + o.Token}
+		}
+		return &artifactoryPublisher{url: repoURL, headers: headers, index: newChartRepositoryIndex(repoURL, headers)}, nil
+	case PublisherOCI:
+		if !strings.HasPrefix(repoURL, ociRepositoryURLPrefix) {
+			return nil, fmt.Errorf("%w: OCI repository \"%s\" should be of the form oci://registry/namespace", ErrInvalidKeyValue, repoURL)
+		}
+		scheme := "https"
+		if o.PlainHTTP {
+			scheme = "http"
+		}
+		registry, namespace, _ := SplitInTwo(strings.TrimPrefix(repoURL, ociRepositoryURLPrefix)+"/", "/")
+		return &ociPublisher{
+			registry:  scheme + "://" + registry,
+			namespace: strings.Trim(namespace, "/"),
+			username:  o.Username,
+			password:  o.Password,
+			client:    http.DefaultClient,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown chart publisher \"%s\" (supported: %s, %s, %s, %s)", o.Type, PublisherHelm, PublisherChartMuseum, PublisherArtifactory, PublisherOCI)
+}
+
+// NewChartVersionChecker returns a checker of chart versions in the repository a publisher pushes to.
+// Versions in Helm repositories which charts are pushed to by the Helm client are checked using the repository index
+func NewChartVersionChecker(o ChartPublisherOptions) (ChartVersionChecker, error) {
+	if o.Type != PublisherHelm && o.Type != "" {
+		return NewChartPublisher(o)
+	}
+	_, repoURL := SplitRepo(o.Repo)
+	if repoURL == "" {
+		return nil, fmt.Errorf("%w: chart repository can not be empty", ErrInvalidKeyValue)
+	}
+	username, password := o.Username, o.Password
+	if username == "" && password == "" {
+		username, password = os.Getenv("HELM_REPO_USERNAME"), os.Getenv("HELM_REPO_PASSWORD")
+	}
+	return newChartRepositoryIndex(repoURL, basicAuthHeaders(username, password)), nil
+}
+
+// basicAuthHeaders returns the headers of basic authentication, if there are credentials
+func basicAuthHeaders(username, password string) []string {
+	if username == "" && password == "" {
+		return nil
+	}
+	return []string{"Authorization:Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))}
+}
+
+// chartRepositoryIndex is the index.yaml of a Helm chart repository, which is fetched once
+type chartRepositoryIndex struct {
+	url     string
+	headers []string
+
+	once     sync.Once
+	versions map[string][]string
+	err      error
+}
+
+func newChartRepositoryIndex(repoURL string, headers []string) *chartRepositoryIndex {
+	return &chartRepositoryIndex{url: repoURL, headers: headers}
+}
+
+// Exists returns true if the index of the repository contains a chart version.
+// A repository without an index is empty
+func (i *chartRepositoryIndex) Exists(ctx context.Context, name, version string) (bool, error) {
+	i.once.Do(func() {
+		body, err := PerformRequest(PerformRequestOptions{
+			Method:             "GET",
+			URL:                strings.TrimSuffix(i.url, "/") + "/index.yaml",
+			Headers:            i.headers,
+			ExpectedStatusCode: http.StatusOK,
+		})
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			return
+		}
+		if err != nil {
+			i.err = err
+			return
+		}
+		var index struct {
+			Entries map[string][]struct {
+				Version string `yaml:"version"`
+			} `yaml:"entries"`
+		}
+		if err := yaml.Unmarshal(body, &index); err != nil {
+			i.err = fmt.Errorf("%w: invalid index of chart repository %s: %v", ErrRequestFailed, i.url, err)
+			return
+		}
+		i.versions = map[string][]string{}
+		for chart, entries := range index.Entries {
+			for _, e := range entries {
+				i.versions[chart] = append(i.versions[chart], e.Version)
+			}
+		}
+	})
+	if i.err != nil {
+		return false, i.err
+	}
+	return Contains(i.versions[name], version), nil
+}
+
+// SplitRepo splits a chart repository of the form name=url to its name and url. A repository without a name is a url
+func SplitRepo(repo string) (string, string) {
+	name, repoURL, err := SplitInTwo(repo, "=")
+	if err != nil || strings.Contains(name, "/") {
+		return "", repo
+	}
+	return name, repoURL
+}
+
+// chartMuseumPublisher uploads charts using the ChartMuseum API
+type chartMuseumPublisher struct {
+	url      string
+	username string
+	password string
+	// force overwrites an existing chart version instead of failing with a conflict
+	force bool
+	index *chartRepositoryIndex
+}
+
+// Exists returns true if a chart version exists in the index of the ChartMuseum repository
+func (p *chartMuseumPublisher) Exists(ctx context.Context, name, version string) (bool, error) {
+	return p.index.Exists(ctx, name, version)
+}
+
+// Publish uploads a packaged chart to ChartMuseum
+func (p *chartMuseumPublisher) Publish(ctx context.Context, chart *PackagedChart) error {
+	data, err := os.Open(chart.Path)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	u, err := url.Parse(p.url)
+	if err != nil {
+		return err
+	}
+	// ChartMuseum serves multitenant repositories under /api/<repo path>/charts
+	u.Path = path.Join("/api", strings.Trim(u.Path, "/"), "charts")
+	if p.force {
+		u.RawQuery = "force"
+	}
+
+	req, err := http.NewRequest("POST", u.String(), data)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if p.username != "" || p.password != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(res.Body)
+		return &HTTPError{Method: "POST", URL: u.String(), StatusCode: res.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// artifactoryPublisher uploads charts to an Artifactory Helm repository
+type artifactoryPublisher struct {
+	url     string
+	headers []string
+	index   *chartRepositoryIndex
+}
+
+// Exists returns true if a chart version exists in the index of the Artifactory Helm repository
+func (p *artifactoryPublisher) Exists(ctx context.Context, name, version string) (bool, error) {
+	return p.index.Exists(ctx, name, version)
+}
+
+// Publish deploys a packaged chart to Artifactory, which reindexes the Helm repository
+func (p *artifactoryPublisher) Publish(ctx context.Context, chart *PackagedChart) error {
+	data, err := os.Open(chart.Path)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	_, err = PerformRequest(PerformRequestOptions{
+		Method:             "PUT",
+		URL:                strings.TrimSuffix(p.url, "/") + "/" + path.Base(chart.Path),
+		Headers:            p.headers,
+		ExpectedStatusCode: 201,
+		Data:               data,
+	})
+	return err
+}
+
+// ociPublisher pushes charts to an OCI registry using the registry HTTP API
+type ociPublisher struct {
+	registry  string
+	namespace string
+	username  string
+	password  string
+	client    *http.Client
+
+	// token is a bearer token of the registry, once it is required. Charts may be pushed in parallel,
+	// and a token of another repository is replaced once the registry challenges it
+	token      string
+	tokenMutex sync.Mutex
+}
+
+// ociDescriptor describes a blob in an OCI manifest
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Exists returns true if the registry has a manifest of a chart version
+func (p *ociPublisher) Exists(ctx context.Context, name, version string) (bool, error) {
+	repository := strings.TrimPrefix(p.namespace+"/"+name, "/")
+	_, err := p.do(ctx, "HEAD", fmt.Sprintf("%s/v2/%s/manifests/%s", p.registry, repository, ociTag(version)), "", nil, http.StatusOK)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ociTag returns the tag of a chart version. Build metadata (+) is not allowed in OCI tags
+func ociTag(version string) string {
+	return strings.Replace(version, "+", "_", -1)
+}
+
+// Publish pushes a packaged chart to an OCI registry: the chart metadata as the config blob,
+// the packaged chart as the only layer, and a manifest tagged with the chart version
+func (p *ociPublisher) Publish(ctx context.Context, chart *PackagedChart) error {
+	content, err := ioutil.ReadFile(chart.Path)
+	if err != nil {
+		return err
+	}
+	repository := strings.TrimPrefix(p.namespace+"/"+chart.Name, "/")
+
+	config, err := p.pushBlob(ctx, repository, ociHelmConfigMediaType, chart.metadata)
+	if err != nil {
+		return err
+	}
+	layer, err := p.pushBlob(ctx, repository, ociHelmContentMediaType, content)
+	if err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		MediaType     string          `json:"mediaType"`
+		Config        ociDescriptor   `json:"config"`
+		Layers        []ociDescriptor `json:"layers"`
+	}{2, ociManifestMediaType, config, []ociDescriptor{layer}})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.do(ctx, "PUT", fmt.Sprintf("%s/v2/%s/manifests/%s", p.registry, repository, ociTag(chart.Version)), ociManifestMediaType, manifest, http.StatusCreated)
+	return err
+}
+
+// pushBlob uploads a blob to a repository, unless it already exists
+func (p *ociPublisher) pushBlob(ctx context.Context, repository, mediaType string, data []byte) (ociDescriptor, error) {
+	sum := sha256.Sum256(data)
+	d := ociDescriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+
+	if _, err := p.do(ctx, "HEAD", fmt.Sprintf("%s/v2/%s/blobs/%s", p.registry, repository, d.Digest), "", nil, http.StatusOK); err == nil {
+		return d, nil
+	}
+	res, err := p.do(ctx, "POST", fmt.Sprintf("%s/v2/%s/blobs/uploads/", p.registry, repository), "", nil, http.StatusAccepted)
+	if err != nil {
+		return d, err
+	}
+	location, err := url.Parse(p.registry)
+	if err == nil {
+		location, err = location.Parse(res.Header.Get("Location"))
+	}
+	if err != nil {
+		return d, err
+	}
+	q := location.Query()
+	q.Set("digest", d.Digest)
+	location.RawQuery = q.Encode()
+	_, err = p.do(ctx, "PUT", location.String(), "application/octet-stream", data, http.StatusCreated)
+	return d, err
+}
+
+// do performs a request to the registry and returns the response if it has the expected status code.
+// If the registry requires a bearer token, a token is requested with the credentials and the request is retried
+func (p *ociPublisher) do(ctx context.Context, method, u, contentType string, data []byte, expectedStatusCode int) (*http.Response, error) {
+	p.tokenMutex.Lock()
+	token := p.token
+	p.tokenMutex.Unlock()
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, u, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Accept", ociManifestMediaType)
+		switch {
+		case token != "":
+			req.Header.Set("Authorization", "Bearer "+token)
+		case p.username != "" || p.password != "":
+			req.SetBasicAuth(p.username, p.password)
+		}
+		res, err := p.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+		}
+
+		challenge := res.Header.Get("WWW-Authenticate")
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 && strings.HasPrefix(challenge, "Bearer ") {
+			if token, err = p.requestToken(ctx, challenge); err != nil {
+				return nil, err
+			}
+			p.tokenMutex.Lock()
+			p.token = token
+			p.tokenMutex.Unlock()
+			continue
+		}
+		if res.StatusCode != expectedStatusCode {
+			return nil, &HTTPError{Method: method, URL: u, StatusCode: res.StatusCode, Body: strings.TrimSpace(string(body))}
+		}
+		return res, nil
+	}
+}
+
+// requestToken requests a bearer token from the realm of a WWW-Authenticate challenge
+func (p *ociPublisher) requestToken(ctx context.Context, challenge string) (string, error) {
+	params := map[string]string{}
+	for _, m := range bearerChallengePattern.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("%w: invalid authentication challenge \"%s\"", ErrRequestFailed, challenge)
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	if p.username != "" || p.password != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", &HTTPError{Method: "GET", URL: realm.String(), StatusCode: res.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return token.Token, nil
+}
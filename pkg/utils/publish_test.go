@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSplitRepo(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     string
+		wantName string
+		wantURL  string
+	}{
+		{name: "name and url", repo: "nuvo=https://charts.nuvo.com", wantName: "nuvo", wantURL: "https://charts.nuvo.com"},
+		{name: "url", repo: "https://charts.nuvo.com/artifactory/helm", wantURL: "https://charts.nuvo.com/artifactory/helm"},
+		{name: "url with query", repo: "https://charts.nuvo.com/helm?a=b", wantURL: "https://charts.nuvo.com/helm?a=b"},
+		{name: "oci", repo: "oci://registry.nuvo.com/charts", wantURL: "oci://registry.nuvo.com/charts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, url := SplitRepo(tt.repo)
+			if name != tt.wantName || url != tt.wantURL {
+				t.Errorf("SplitRepo() = %v, %v, want %v, %v", name, url, tt.wantName, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestPackageChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orca-package")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chart, err := PackageChart("testdata/charts/api", filepath.Join(dir, "dist"))
+	if err != nil {
+		t.Fatalf("PackageChart() error = %v", err)
+	}
+	if chart.Name != "api" || chart.Version != "0.1.0" {
+		t.Errorf("PackageChart() = %v-%v, want api-0.1.0", chart.Name, chart.Version)
+	}
+	if chart.Path != filepath.Join(dir, "dist", "api-0.1.0.tgz") {
+		t.Errorf("PackageChart() path = %v", chart.Path)
+	}
+	digest, err := fileDigest(chart.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chart.Digest != digest || !strings.HasPrefix(digest, "sha256:") {
+		t.Errorf("PackageChart() digest = %v, want %v", chart.Digest, digest)
+	}
+
+	if _, err := PackageChart("testdata/charts/missing", dir); !errors.Is(err, ErrInvalidChart) {
+		t.Errorf("PackageChart() error = %v, want %v", err, ErrInvalidChart)
+	}
+}
+
+// recordedRequest is a request received by a fake chart repository
+type recordedRequest struct {
+	method string
+	// path is the path and query of the request
+	path string
+	auth string
+}
+
+// fakeRepository is a chart repository server which records requests and responds with handle
+func fakeRepository(handle func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *[]recordedRequest) {
+	var mu sync.Mutex
+	requests := []recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			auth = r.Header.Get("X-JFrog-Art-Api")
+		}
+		requests = append(requests, recordedRequest{r.Method, r.URL.RequestURI(), auth})
+		mu.Unlock()
+		handle(w, r)
+	}))
+	return server, &requests
+}
+
+func TestChartPublishers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orca-publish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	chart, err := PackageChart("testdata/charts/api", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// registry is a minimal OCI registry which requires a bearer token
+	var blobs sync.Map
+	registry := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if user, pass, _ := r.BasicAuth(); user != "ci" || pass != "secret" || r.URL.Query().Get("scope") != "repository:charts/api:push,pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token":"t0ken"}`))
+		case r.Header.Get("Authorization") != "Bearer t0ken":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+r.Host+`/token",service="registry",scope="repository:charts/api:push,pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == "HEAD":
+			if _, ok := blobs.Load(filepath.Base(r.URL.Path)); !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == "POST":
+			w.Header().Set("Location", "/v2/charts/api/blobs/uploads/1234?state=x")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == "PUT" && strings.Contains(r.URL.Path, "/blobs/uploads/"):
+			blobs.Store(r.URL.Query().Get("digest"), true)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/manifests/0.1.0"):
+			if r.Header.Get("Content-Type") != ociManifestMediaType {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		options      ChartPublisherOptions
+		repoPath     string
+		status       int
+		handle       func(w http.ResponseWriter, r *http.Request)
+		wantRequests int
+		wantLast     recordedRequest
+		wantErr      bool
+	}{
+		{
+			name:         "chartmuseum",
+			options:      ChartPublisherOptions{Type: PublisherChartMuseum, Username: "ci", Password: "secret"},
+			repoPath:     "/nuvo",
+			status:       http.StatusCreated,
+			wantRequests: 1,
+			wantLast:     recordedRequest{"POST", "/api/nuvo/charts", "Basic Y2k6c2VjcmV0"},
+		},
+		{
+			name:         "chartmuseum forced",
+			options:      ChartPublisherOptions{Type: PublisherChartMuseum, Force: true},
+			status:       http.StatusCreated,
+			wantRequests: 1,
+			wantLast:     recordedRequest{"POST", "/api/charts?force", ""},
+		},
+		{
+			name:         "chartmuseum conflict",
+			options:      ChartPublisherOptions{Type: PublisherChartMuseum},
+			status:       http.StatusConflict,
+			wantRequests: 1,
+			wantLast:     recordedRequest{"POST", "/api/charts", ""},
+			wantErr:      true,
+		},
+		{
+			name:         "artifactory with token",
+			options:      ChartPublisherOptions{Type: PublisherArtifactory, Token: "key"},
+			repoPath:     "/artifactory/helm/",
+			status:       http.StatusCreated,
+			wantRequests: 1,
+			wantLast:     recordedRequest{"PUT", "/artifactory/helm/api-0.1.0.tgz", "key"},
+		},
+		{
+			name:    "oci",
+			options: ChartPublisherOptions{Type: PublisherOCI, Username: "ci", Password: "secret", PlainHTTP: true},
+			handle:  registry,
+			// challenge, token, and HEAD, POST and PUT of the config and the chart blobs
+			wantRequests: 9,
+			wantLast:     recordedRequest{"PUT", "/v2/charts/api/manifests/0.1.0", "Bearer t0ken"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := tt.handle
+			if handle == nil {
+				handle = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(tt.status) }
+			}
+			server, requests := fakeRepository(handle)
+			defer server.Close()
+
+			tt.options.Repo = server.URL + tt.repoPath
+			if tt.options.Type == PublisherOCI {
+				tt.options.Repo = "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts"
+			}
+			publisher, err := NewChartPublisher(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = publisher.Publish(context.Background(), chart)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var httpErr *HTTPError
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+					t.Errorf("Publish() error = %v, want HTTPError %v", err, tt.status)
+				}
+			}
+			if len(*requests) != tt.wantRequests {
+				t.Fatalf("Publish() requests = %v, want %d requests", *requests, tt.wantRequests)
+			}
+			if last := (*requests)[len(*requests)-1]; last != tt.wantLast {
+				t.Errorf("Publish() last request = %v, want %v", last, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestChartVersionChecker(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+  api:
+  - name: api
+    version: 0.1.0
+  - name: api
+    version: 0.2.0
+`
+	tests := []struct {
+		name     string
+		options  ChartPublisherOptions
+		handle   func(w http.ResponseWriter, r *http.Request)
+		chart    string
+		version  string
+		want     bool
+		wantErr  bool
+		wantLast recordedRequest
+	}{
+		{
+			name:     "version in index",
+			options:  ChartPublisherOptions{Type: PublisherHelm},
+			handle:   func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(index)) },
+			chart:    "api",
+			version:  "0.2.0",
+			want:     true,
+			wantLast: recordedRequest{"GET", "/index.yaml", ""},
+		},
+		{
+			name:     "version not in index",
+			options:  ChartPublisherOptions{Type: PublisherArtifactory, Token: "key"},
+			handle:   func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(index)) },
+			chart:    "api",
+			version:  "0.3.0",
+			wantLast: recordedRequest{"GET", "/index.yaml", "key"},
+		},
+		{
+			name:     "repository without index",
+			options:  ChartPublisherOptions{Type: PublisherChartMuseum},
+			handle:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			chart:    "api",
+			version:  "0.1.0",
+			wantLast: recordedRequest{"GET", "/index.yaml", ""},
+		},
+		{
+			name:     "index request fails",
+			options:  ChartPublisherOptions{Type: PublisherHelm},
+			handle:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			chart:    "api",
+			version:  "0.1.0",
+			wantErr:  true,
+			wantLast: recordedRequest{"GET", "/index.yaml", ""},
+		},
+		{
+			name:     "oci manifest exists",
+			options:  ChartPublisherOptions{Type: PublisherOCI, PlainHTTP: true},
+			handle:   func(w http.ResponseWriter, r *http.Request) {},
+			chart:    "api",
+			version:  "0.1.0+build.1",
+			want:     true,
+			wantLast: recordedRequest{"HEAD", "/v2/charts/api/manifests/0.1.0_build.1", ""},
+		},
+		{
+			name:     "oci manifest does not exist",
+			options:  ChartPublisherOptions{Type: PublisherOCI, PlainHTTP: true},
+			handle:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			chart:    "api",
+			version:  "0.1.0",
+			wantLast: recordedRequest{"HEAD", "/v2/charts/api/manifests/0.1.0", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := fakeRepository(tt.handle)
+			defer server.Close()
+
+			tt.options.Repo = "repo=" + server.URL
+			switch tt.options.Type {
+			case PublisherArtifactory:
+				tt.options.Repo = server.URL
+			case PublisherOCI:
+				tt.options.Repo = "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts"
+			}
+			checker, err := NewChartVersionChecker(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := checker.Exists(context.Background(), tt.chart, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Exists() = %v, want %v", got, tt.want)
+			}
+			if last := (*requests)[len(*requests)-1]; last != tt.wantLast {
+				t.Errorf("Exists() last request = %v, want %v", last, tt.wantLast)
+			}
+		})
+	}
+}
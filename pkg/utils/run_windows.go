@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs a command in a separate process group, so that console signals are handled by orca
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateProcessGroup terminates a command. Windows has no SIGTERM, so the command is killed
+func terminateProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// killProcessGroup kills a command
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
@@ -1,17 +1,20 @@
 package utils
 
 import (
+	"context"
 	"log"
 	"time"
+
+	"k8s.io/client-go/kubernetes"
 )
 
 // IsEnvValidWithLoopBackOff validates the state of a namespace with back off loop
-func IsEnvValidWithLoopBackOff(name, kubeContext string) (bool, error) {
+func IsEnvValidWithLoopBackOff(ctx context.Context, clientset kubernetes.Interface, name string) (bool, error) {
 	log.Printf("validating environment \"%s\"", name)
 	envValid := false
 	maxAttempts := 30
 	for i := 1; i <= maxAttempts; i++ {
-		envValid, err := IsEnvValid(name, kubeContext)
+		envValid, err := IsEnvValid(clientset, name)
 		if err != nil {
 			return envValid, err
 		}
@@ -20,21 +23,23 @@ func IsEnvValidWithLoopBackOff(name, kubeContext string) (bool, error) {
 		}
 		if i < maxAttempts {
 			log.Printf("environment \"%s\" validation failed, will retry in 30 seconds (attempt %d/%d)", name, i, maxAttempts)
-			time.Sleep(30 * time.Second)
+			if err := Sleep(ctx, 30*time.Second); err != nil {
+				return false, err
+			}
 		}
 	}
 	return envValid, nil
 }
 
 // IsEnvValid validates the state of a namespace
-func IsEnvValid(name, kubeContext string) (bool, error) {
+func IsEnvValid(clientset kubernetes.Interface, name string) (bool, error) {
 	envValid := true
-	envValid, err := validatePods(name, kubeContext, envValid)
+	envValid, err := validatePods(clientset, name, envValid)
 	if err != nil {
 		return envValid, err
 	}
 
-	envValid, err = validateEndpoints(name, kubeContext, envValid)
+	envValid, err = validateEndpoints(clientset, name, envValid)
 	if err != nil {
 		return envValid, err
 	}
@@ -42,9 +47,9 @@ func IsEnvValid(name, kubeContext string) (bool, error) {
 	return envValid, nil
 }
 
-func validatePods(name, kubeContext string, envValid bool) (bool, error) {
+func validatePods(clientset kubernetes.Interface, name string, envValid bool) (bool, error) {
 	log.Println("validating pods")
-	pods, err := getPods(name, kubeContext)
+	pods, err := getPods(clientset, name)
 	if err != nil {
 		return envValid, err
 	}
@@ -69,7 +74,7 @@ func validatePods(name, kubeContext string, envValid bool) (bool, error) {
 			if status.Ready {
 				continue
 			}
-			if pod.OwnerReferences[0].Kind == "Job" {
+			if len(pod.OwnerReferences) != 0 && pod.OwnerReferences[0].Kind == "Job" {
 				continue
 			}
 			log.Printf("container %s/%s is not in \"Ready\" status", pod.Name, status.Name)
@@ -80,9 +85,9 @@ func validatePods(name, kubeContext string, envValid bool) (bool, error) {
 	return envValid, nil
 }
 
-func validateEndpoints(name, kubeContext string, envValid bool) (bool, error) {
+func validateEndpoints(clientset kubernetes.Interface, name string, envValid bool) (bool, error) {
 	log.Println("validating endpoints")
-	endpoints, err := getEndpoints(name, kubeContext)
+	endpoints, err := getEndpoints(clientset, name)
 	if err != nil {
 		return envValid, err
 	}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Version bumps, determined by conventional commits
+const (
+	// VersionBumpMajor is the bump of breaking changes (a ! after the type, or a BREAKING CHANGE footer)
+	VersionBumpMajor = "major"
+	// VersionBumpMinor is the bump of features (feat)
+	VersionBumpMinor = "minor"
+	// VersionBumpPatch is the bump of any other commit
+	VersionBumpPatch = "patch"
+	// VersionBumpNone is the bump when there are no commits since the last version tag
+	VersionBumpNone = "none"
+)
+
+// CalculateVersionOptions are options passed to CalculateVersion
+type CalculateVersionOptions struct {
+	// RepoPath is a path in the repository (defaults to the current directory)
+	RepoPath string
+	// Path restricts the commits which are counted to those changing it (all commits count if empty)
+	Path string
+	// TagPrefix is the prefix of version tags (e.g. v for v1.2.3)
+	TagPrefix string
+	// BaseVersion is the version to bump if there is no version tag in the history of HEAD (defaults to 0.0.0)
+	BaseVersion string
+	// Ref is the name of the reference (branch) which is versioned (defaults to the branch of HEAD)
+	Ref string
+	// MainRef is the name of the main line and ReleaseRef is a release reference name (or regex), which are versioned
+	// as is (empty ones match no reference). Pre-release identifiers of the reference, commits count and short SHA are
+	// added on any other reference
+	MainRef    string
+	ReleaseRef string
+}
+
+// CalculatedVersion is a version calculated from the git history
+type CalculatedVersion struct {
+	Version         string `json:"version" yaml:"version"`
+	PreviousVersion string `json:"previousVersion" yaml:"previousVersion"`
+	PreviousTag     string `json:"previousTag,omitempty" yaml:"previousTag,omitempty"`
+	Bump            string `json:"bump" yaml:"bump"`
+	Commits         int    `json:"commits" yaml:"commits"`
+	Commit          string `json:"commit" yaml:"commit"`
+	Prerelease      string `json:"prerelease,omitempty" yaml:"prerelease,omitempty"`
+}
+
+// conventionalCommitPattern matches the header of a conventional commit: type(scope)!: description
+var conventionalCommitPattern = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?(!)?: `)
+
+// breakingChangePattern matches a breaking change footer of a conventional commit
+var breakingChangePattern = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE: `)
+
+// invalidIdentifierChars matches characters which are not allowed in semver pre-release identifiers
+var invalidIdentifierChars = regexp.MustCompile(`[^0-9A-Za-z-]+`)
+
+// CalculateVersion calculates the next version from the git history: the latest version tag reachable from HEAD
+// is bumped by the conventional commits since it. Pre-release identifiers of the form ref.commits.gSHA are added
+// unless the reference is the main line or a release reference. If HEAD is tagged with a version, that version is
+// returned as is. If a path is set, only commits which changed it are counted, as by git log -- path
+func CalculateVersion(o CalculateVersionOptions) (*CalculatedVersion, error) {
+	if o.RepoPath == "" {
+		o.RepoPath = "."
+	}
+	r, err := git.PlainOpenWithOptions(o.RepoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+	headRef, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	previous, tag, tagCommit, err := latestVersionTag(r, head, o.TagPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" {
+		if o.BaseVersion == "" {
+			o.BaseVersion = "0.0.0"
+		}
+		if previous, err = semver.NewVersion(o.BaseVersion); err != nil {
+			return nil, fmt.Errorf("%w: base version \"%s\" is not a semantic version: %v", ErrInvalidKeyValue, o.BaseVersion, err)
+		}
+	}
+
+	path, err := repoRelativePath(r, o.Path)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := commitsSince(r, head, tagCommit, path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &CalculatedVersion{
+		PreviousVersion: previous.String(),
+		PreviousTag:     tag,
+		Bump:            VersionBumpNone,
+		Commits:         len(commits),
+		Commit:          head.Hash.String()[:7],
+	}
+	next := *previous
+	if len(commits) != 0 {
+		v.Bump = GetVersionBump(commits)
+		switch v.Bump {
+		case VersionBumpMajor:
+			next = next.IncMajor()
+		case VersionBumpMinor:
+			next = next.IncMinor()
+		default:
+			next = next.IncPatch()
+		}
+	}
+
+	ref := o.Ref
+	if ref == "" && headRef.Name().IsBranch() {
+		ref = headRef.Name().Short()
+	}
+	versionedAsIs, err := isVersionedAsIs(ref, o.MainRef, o.ReleaseRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) != 0 && !versionedAsIs {
+		identifiers := []string{}
+		if id := PrereleaseIdentifier(ref); id != "" {
+			identifiers = append(identifiers, id)
+		}
+		identifiers = append(identifiers, fmt.Sprint(len(commits)), "g"+v.Commit)
+		v.Prerelease = strings.Join(identifiers, ".")
+		if next, err = next.SetPrerelease(v.Prerelease); err != nil {
+			return nil, err
+		}
+	}
+	v.Version = next.String()
+	return v, nil
+}
+
+// isVersionedAsIs reports whether a reference is the main line or a release reference. Unlike IsMainlineOrReleaseRef,
+// an empty main line or release reference matches no reference
+func isVersionedAsIs(ref, mainRef, releaseRef string) (bool, error) {
+	if mainRef != "" && ref == mainRef {
+		return true, nil
+	}
+	if releaseRef == "" {
+		return false, nil
+	}
+	re, err := regexp.Compile(releaseRef)
+	if err != nil {
+		return false, fmt.Errorf("invalid release reference \"%s\": %v", releaseRef, err)
+	}
+	return re.MatchString(ref), nil
+}
+
+// GetVersionBump returns the version bump of commit messages by the conventional commits specification
+func GetVersionBump(messages []string) string {
+	bump := VersionBumpNone
+	for _, m := range messages {
+		header := strings.SplitN(m, "\n", 2)[0]
+		match := conventionalCommitPattern.FindStringSubmatch(header)
+		switch {
+		case (match != nil && match[3] == "!") || breakingChangePattern.MatchString(m):
+			return VersionBumpMajor
+		case match != nil && strings.ToLower(match[1]) == "feat":
+			bump = VersionBumpMinor
+		case bump == VersionBumpNone:
+			bump = VersionBumpPatch
+		}
+	}
+	return bump
+}
+
+// PrereleaseIdentifier converts a reference name to a semver pre-release identifier (e.g. feature/Login to feature-login)
+func PrereleaseIdentifier(ref string) string {
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	return strings.Trim(invalidIdentifierChars.ReplaceAllString(strings.ToLower(ref), "-"), "-")
+}
+
+// latestVersionTag returns the highest version tagged on the most recent commit reachable from head which has a
+// version tag, along with the tag and the commit. Pre-release tags are ignored. If there is no version tag,
+// an empty tag is returned
+func latestVersionTag(r *git.Repository, head *object.Commit, prefix string) (*semver.Version, string, *object.Commit, error) {
+	tagged, err := tagsByCommit(r)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var version *semver.Version
+	var tag string
+	var commit *object.Commit
+	missing, err := walkHistory(r, head, func(c *object.Commit) bool {
+		for _, t := range tagged[c.Hash] {
+			if !strings.HasPrefix(t, prefix) {
+				continue
+			}
+			v, err := semver.NewVersion(strings.TrimPrefix(t, prefix))
+			if err != nil || v.Prerelease() != "" {
+				continue
+			}
+			if version == nil || v.GreaterThan(version) {
+				version, tag, commit = v, t, c
+			}
+		}
+		return version != nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if version == nil && missing {
+		return nil, "", nil, errors.New("no version tag found in the history of HEAD, history is incomplete" + shallowHint(r))
+	}
+	return version, tag, commit, nil
+}
+
+// commitsSince returns the messages of the commits reachable from head and not from since (all commits if since is nil)
+// which changed the path (any path if empty)
+func commitsSince(r *git.Repository, head, since *object.Commit, path string) ([]string, error) {
+	excluded := map[plumbing.Hash]bool{}
+	if since != nil {
+		var err error
+		if excluded, _, err = ancestors(r, since); err != nil {
+			return nil, err
+		}
+	}
+	messages := []string{}
+	var changeErr error
+	_, err := walkHistory(r, head, func(c *object.Commit) bool {
+		if excluded[c.Hash] {
+			return false
+		}
+		changed := true
+		if path != "" {
+			if changed, changeErr = changesPath(r, c, path); changeErr != nil {
+				return true
+			}
+		}
+		if changed {
+			messages = append(messages, c.Message)
+		}
+		return false
+	})
+	if changeErr != nil {
+		return nil, changeErr
+	}
+	return messages, err
+}
+
+// repoRelativePath returns a path relative to the root of the worktree of the repository, in which git paths are.
+// The root itself, like an empty path, is returned as an empty path
+func repoRelativePath(r *git.Repository, path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+	root := w.Filesystem.Root()
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", nil
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is not in the repository %s", path, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// changesPath reports whether a commit changed a path: the path differs from every parent whose objects are
+// available, or exists in a commit without parents (like git log -- path)
+func changesPath(r *git.Repository, c *object.Commit, path string) (bool, error) {
+	hash, err := pathHash(c, path)
+	if err != nil {
+		return false, err
+	}
+	if len(c.ParentHashes) == 0 {
+		return !hash.IsZero(), nil
+	}
+	for _, p := range c.ParentHashes {
+		parent, err := r.CommitObject(p)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		parentHash, err := pathHash(parent, path)
+		if err != nil {
+			return false, err
+		}
+		if parentHash == hash {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// pathHash returns the hash of the file or directory at a path in a commit, or the zero hash if it does not exist
+func pathHash(c *object.Commit, path string) (plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entry, err := tree.FindEntry(path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return entry.Hash, nil
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	git "gopkg.in/src-d/go-git.v4"
+)
+
+func TestCalculateVersion(t *testing.T) {
+	tr := newTestRepo(t)
+	defer os.RemoveAll(tr.dir)
+
+	// master: c1 (v1.0.0) - c2 - c4 - fix - feat (v1.1.0-rc.1)
+	// feature: c3 - breaking (v2.0.0, tagged) - docs
+	tr.checkout(t, "master", false)
+	tr.commit(t, "fix: handle empty values", nil, "src/a.go")
+	tr.commit(t, "feat(api): add users endpoint", nil, "src/users.go")
+	if _, err := tr.repo.CreateTag("v1.1.0-rc.1", tr.commits["feat(api): add users endpoint"], nil); err != nil {
+		t.Fatal(err)
+	}
+	tr.checkout(t, "feature", false)
+	tr.commit(t, "refactor: rename config\n\nBREAKING CHANGE: config is renamed", nil, "src/config.go")
+	tr.checkout(t, "tagged", true)
+	if _, err := tr.repo.CreateTag("v2.0.0", tr.commits["refactor: rename config\n\nBREAKING CHANGE: config is renamed"], &git.CreateTagOptions{Tagger: tr.signature(), Message: "v2.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	tr.checkout(t, "feature", false)
+	tr.commit(t, "docs: update readme", nil, "README.md")
+
+	tests := []struct {
+		name     string
+		branch   string
+		options  CalculateVersionOptions
+		want     string
+		wantBump string
+	}{
+		{
+			name:     "feature since last tag",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master", ReleaseRef: "^release/.*$"},
+			want:     "1.1.0",
+			wantBump: VersionBumpMinor,
+		},
+		{
+			name:     "feature with pre-release identifiers",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "v", Ref: "feature/Users API", MainRef: "master", ReleaseRef: "^release/.*$"},
+			want:     "1.1.0-feature-users-api.4.g",
+			wantBump: VersionBumpMinor,
+		},
+		{
+			name:     "tags without prefix are ignored",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "release-", BaseVersion: "0.3.1", MainRef: "master"},
+			want:     "0.4.0",
+			wantBump: VersionBumpMinor,
+		},
+		{
+			name:     "breaking change",
+			branch:   "tagged",
+			options:  CalculateVersionOptions{TagPrefix: "release-", BaseVersion: "0.3.1", MainRef: "tagged"},
+			want:     "1.0.0",
+			wantBump: VersionBumpMajor,
+		},
+		{
+			name:     "tagged HEAD",
+			branch:   "tagged",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master", ReleaseRef: "^release/.*$"},
+			want:     "2.0.0",
+			wantBump: VersionBumpNone,
+		},
+		{
+			name:     "branch of HEAD as pre-release identifier",
+			branch:   "feature",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master", ReleaseRef: "^release/.*$"},
+			want:     "2.0.1-feature.1.g",
+			wantBump: VersionBumpPatch,
+		},
+		{
+			name:     "no release reference",
+			branch:   "feature",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master"},
+			want:     "2.0.1-feature.1.g",
+			wantBump: VersionBumpPatch,
+		},
+		{
+			name:     "no main line or release reference",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "v"},
+			want:     "1.1.0-master.4.g",
+			wantBump: VersionBumpMinor,
+		},
+		{
+			name:     "commits which changed the path",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "v", Ref: "feature/x", MainRef: "master", Path: "src"},
+			want:     "1.1.0-feature-x.3.g",
+			wantBump: VersionBumpMinor,
+		},
+		{
+			name:     "feature which did not change the path",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master", Path: "docs"},
+			want:     "1.0.1",
+			wantBump: VersionBumpPatch,
+		},
+		{
+			name:     "no commits changed the path",
+			branch:   "master",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master", Path: "chart"},
+			want:     "1.0.0",
+			wantBump: VersionBumpNone,
+		},
+		{
+			name:     "path of a file",
+			branch:   "feature",
+			options:  CalculateVersionOptions{TagPrefix: "v", MainRef: "master", Path: "README.md"},
+			want:     "2.0.1-feature.1.g",
+			wantBump: VersionBumpPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr.checkout(t, tt.branch, false)
+			tt.options.RepoPath = tr.dir
+			if tt.options.Path != "" {
+				tt.options.Path = filepath.Join(tr.dir, tt.options.Path)
+			}
+			got, err := CalculateVersion(tt.options)
+			if err != nil {
+				t.Fatalf("CalculateVersion() error = %v", err)
+			}
+			want := tt.want
+			if strings.HasSuffix(want, ".g") {
+				want += got.Commit
+			}
+			if got.Version != want {
+				t.Errorf("CalculateVersion() version = %v, want %v", got.Version, want)
+			}
+			if got.Bump != tt.wantBump {
+				t.Errorf("CalculateVersion() bump = %v, want %v", got.Bump, tt.wantBump)
+			}
+		})
+	}
+}
+
+func TestGetVersionBump(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		want     string
+	}{
+		{name: "no commits", messages: []string{}, want: VersionBumpNone},
+		{name: "fix", messages: []string{"fix: typo"}, want: VersionBumpPatch},
+		{name: "not conventional", messages: []string{"update readme"}, want: VersionBumpPatch},
+		{name: "feature", messages: []string{"fix: typo", "feat(ui): dark mode"}, want: VersionBumpMinor},
+		{name: "breaking type", messages: []string{"feat: a", "fix!: drop v1 api"}, want: VersionBumpMajor},
+		{name: "breaking footer", messages: []string{"feat: new config\n\nBREAKING-CHANGE: old config is removed"}, want: VersionBumpMajor},
+		{name: "breaking in description", messages: []string{"fix: mention BREAKING CHANGE: in docs"}, want: VersionBumpPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetVersionBump(tt.messages); got != tt.want {
+				t.Errorf("GetVersionBump() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrereleaseIdentifier(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "feature/login", want: "feature-login"},
+		{ref: "refs/heads/Fix_Bug#12", want: "fix-bug-12"},
+		{ref: "--", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := PrereleaseIdentifier(tt.ref); got != tt.want {
+				t.Errorf("PrereleaseIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package test
 
 import (
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/nuvo/orca/pkg/utils"
@@ -14,7 +16,10 @@ func TestOverrideReleases_WithOverride(t *testing.T) {
 
 	releases := []utils.ReleaseSpec{rel0, rel1, rel2}
 
-	overrideReleases := utils.OverrideReleases(releases, []string{"kaa=7.1.0"}, "test")
+	overrideReleases, err := utils.OverrideReleases(releases, []string{"kaa=7.1.0"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !overrideReleases[2].Equals(rel2override) {
 		t.Errorf("Expected: true, Actual: false")
@@ -29,7 +34,10 @@ func TestOverrideReleases_WithNewOverride(t *testing.T) {
 
 	releases := []utils.ReleaseSpec{rel0, rel1, rel2}
 
-	overrideReleases := utils.OverrideReleases(releases, []string{"example=3.3.3"}, "test")
+	overrideReleases, err := utils.OverrideReleases(releases, []string{"example=3.3.3"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !overrideReleases[3].Equals(rel2override) {
 		t.Errorf("Expected: true, Actual: false")
@@ -43,7 +51,10 @@ func TestOverrideReleases_WithoutOverride(t *testing.T) {
 
 	releases := []utils.ReleaseSpec{rel0, rel1, rel2}
 
-	overrideReleases := utils.OverrideReleases(releases, []string{}, "test")
+	overrideReleases, err := utils.OverrideReleases(releases, []string{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !overrideReleases[0].Equals(rel0) {
 		t.Errorf("Expected: true, Actual: false")
@@ -57,7 +68,10 @@ func TestOverrideReleases_WithoutOverride(t *testing.T) {
 }
 func TestRemoveChartFromDependencies(t *testing.T) {
 	file := "data/charts.yaml"
-	releases := utils.InitReleasesFromChartsFile(file, "test")
+	releases, err := utils.InitReleasesFromChartsFile(file, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
 	releases = utils.RemoveChartFromDependencies(releases, "mariadb")
 
 	if len(releases[2].Dependencies) != 1 {
@@ -72,7 +86,10 @@ func TestRemoveChartFromCharts(t *testing.T) {
 	rel1 := utils.ReleaseSpec{ChartName: "mariadb", ChartVersion: "0.5.4", ReleaseName: "test-mariadb"}
 	rel0 := utils.ReleaseSpec{ChartName: "kaa", ChartVersion: "0.1.7", ReleaseName: "test-kaa"}
 	file := "data/charts.yaml"
-	releases := utils.InitReleasesFromChartsFile(file, "test")
+	releases, err := utils.InitReleasesFromChartsFile(file, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
 	index := utils.GetChartIndex(releases, "cassandra")
 	releases = utils.RemoveChartFromCharts(releases, index)
 
@@ -87,11 +104,42 @@ func TestRemoveChartFromCharts(t *testing.T) {
 	}
 }
 func TestUpdateChartVersion(t *testing.T) {
-	newVersion := utils.UpdateChartVersion("data/", "1234")
+	newVersion, err := utils.UpdateChartVersion("data/", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if newVersion != "0.1.1-1234" {
 		t.Errorf("Expected: 0.1.1-1234, Actual: " + newVersion)
 	}
 
-	utils.ResetChartVersion("data/", "0.1.1")
+	if err := utils.ResetChartVersion("data/", "0.1.1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEditChart(t *testing.T) {
+	original, err := ioutil.ReadFile("data/Chart.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ioutil.WriteFile("data/Chart.yaml", original, 0644)
+
+	newVersion, err := utils.EditChart("data", utils.ChartEdit{Version: "0.2.0-feature.1.gabc1234", AppVersion: "abc1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if newVersion != "0.2.0-feature.1.gabc1234" {
+		t.Errorf("Expected: 0.2.0-feature.1.gabc1234, Actual: " + newVersion)
+	}
+
+	data, err := ioutil.ReadFile("data/Chart.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(strings.Replace(string(original), `appVersion: "1.0"`, `appVersion: "abc1234"`, 1), "version: 0.1.1", "version: 0.2.0-feature.1.gabc1234", 1)
+	if string(data) != expected {
+		t.Errorf("Expected: %s, Actual: %s", expected, data)
+	}
 }